@@ -0,0 +1,128 @@
+package mosaic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/fogleman/gg"
+	"image"
+	"image/color"
+	"image/draw"
+	"time"
+)
+
+// ErrNoFrames is returned when rendering an animation without frames.
+var ErrNoFrames = errors.New("animation needs at least one frame")
+
+// A Frame is a composition shown by an Animation.
+type Frame struct {
+	Composer ComposerInfo
+	// Options may be nil to use the defaults of the composer.
+	Options Options
+	Images  []image.Image
+}
+
+// FocusFrames returns a frame for every image. Each frame rotates the
+// images so that a different one comes first, which composers like
+// tiles-focused show most prominently.
+func FocusFrames(composer ComposerInfo, opts Options, images []image.Image) []Frame {
+	frames := make([]Frame, len(images))
+	for i := range images {
+		rotated := make([]image.Image, 0, len(images))
+		rotated = append(rotated, images[i:]...)
+		rotated = append(rotated, images[:i]...)
+
+		frames[i] = Frame{Composer: composer, Options: opts, Images: rotated}
+	}
+
+	return frames
+}
+
+// ComposerFrames returns a frame for each of the composers which can use
+// some of the images. The frames use the default options.
+func ComposerFrames(composers []ComposerInfo, images []image.Image) []Frame {
+	var frames []Frame
+	for _, composer := range composers {
+		if composer.RecommendImageCount(len(images)) > 0 {
+			frames = append(frames, Frame{Composer: composer, Images: images})
+		}
+	}
+
+	return frames
+}
+
+// An Animation shows a sequence of compositions one after another. The
+// last composition fades back into the first one so the animation can
+// loop.
+type Animation struct {
+	Frames []Frame
+
+	// FrameDuration is how long each composition is shown.
+	FrameDuration time.Duration
+	// Crossfade is the amount of intermediate frames blending a
+	// composition into the next one. 0 switches without a transition.
+	Crossfade int
+	// CrossfadeDuration is the duration of the whole crossfade.
+	CrossfadeDuration time.Duration
+}
+
+// An AnimationFrame is a rendered frame of an Animation.
+type AnimationFrame struct {
+	Image image.Image
+	// Delay is how long the frame is shown.
+	Delay time.Duration
+}
+
+// blend returns the image a faded into b by t, which ranges from 0 to 1.
+func blend(a, b image.Image, t float64) image.Image {
+	dst := image.NewRGBA(a.Bounds())
+	draw.Draw(dst, dst.Bounds(), a, a.Bounds().Min, draw.Src)
+
+	mask := image.NewUniform(color.Alpha{A: uint8(t*0xff + .5)})
+	draw.DrawMask(dst, dst.Bounds(), b, b.Bounds().Min, mask, image.ZP, draw.Over)
+	return dst
+}
+
+// Render composes every frame on a canvas of the given size and returns
+// them together with the crossfades between them.
+func (a Animation) Render(ctx context.Context, width, height int) ([]AnimationFrame, error) {
+	if len(a.Frames) == 0 {
+		return nil, ErrNoFrames
+	}
+
+	compositions := make([]image.Image, len(a.Frames))
+	for i, frame := range a.Frames {
+		imgCount := frame.Composer.RecommendImageCount(len(frame.Images))
+		if imgCount == 0 {
+			return nil, fmt.Errorf("composer %q can't use %d images", frame.Composer.Id, len(frame.Images))
+		}
+
+		dc := gg.NewContext(width, height)
+		if err := frame.Composer.ComposeContext(ctx, dc, frame.Options, frame.Images[:imgCount]...); err != nil {
+			return nil, err
+		}
+
+		compositions[i] = dc.Image()
+	}
+
+	crossfade := a.Crossfade
+	if len(compositions) < 2 {
+		crossfade = 0
+	}
+
+	rendered := make([]AnimationFrame, 0, len(compositions)*(crossfade+1))
+	for i, composition := range compositions {
+		rendered = append(rendered, AnimationFrame{Image: composition, Delay: a.FrameDuration})
+
+		next := compositions[(i+1)%len(compositions)]
+		for step := 1; step <= crossfade; step++ {
+			t := float64(step) / float64(crossfade+1)
+			rendered = append(rendered, AnimationFrame{
+				Image: blend(composition, next, t),
+				Delay: a.CrossfadeDuration / time.Duration(crossfade),
+			})
+		}
+	}
+
+	return rendered, nil
+}
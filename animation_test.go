@@ -0,0 +1,76 @@
+package mosaic
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+	"time"
+)
+
+func uniformImage(c color.Color) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.ZP, draw.Src)
+	return img
+}
+
+func TestFocusFrames(t *testing.T) {
+	images := []image.Image{
+		uniformImage(color.Black),
+		uniformImage(color.White),
+		uniformImage(color.Gray{Y: 0x80}),
+	}
+
+	frames := FocusFrames(ComposerInfo{Id: "x"}, nil, images)
+	if assert.Len(t, frames, 3) {
+		assert.Equal(t, []image.Image{images[1], images[2], images[0]}, frames[1].Images)
+		assert.Equal(t, []image.Image{images[2], images[0], images[1]}, frames[2].Images)
+	}
+}
+
+func TestAnimation_Render(t *testing.T) {
+	single, ok := GetComposer("tiles-perfect")
+	if !assert.True(t, ok) {
+		return
+	}
+
+	black, white := uniformImage(color.Black), uniformImage(color.White)
+	animation := Animation{
+		Frames: []Frame{
+			{Composer: single, Images: []image.Image{black}},
+			{Composer: single, Images: []image.Image{white}},
+		},
+		FrameDuration:     time.Second,
+		Crossfade:         3,
+		CrossfadeDuration: 300 * time.Millisecond,
+	}
+
+	frames, err := animation.Render(context.Background(), 16, 16)
+	if !assert.NoError(t, err) || !assert.Len(t, frames, 8) {
+		return
+	}
+
+	assert.Equal(t, time.Second, frames[0].Delay)
+	assert.Equal(t, 100*time.Millisecond, frames[1].Delay)
+
+	gray := func(i int) uint8 {
+		return color.GrayModel.Convert(frames[i].Image.At(8, 8)).(color.Gray).Y
+	}
+
+	assert.Equal(t, uint8(0), gray(0))
+	assert.InDelta(t, 0x80, gray(2), 2)
+	assert.Equal(t, uint8(0xff), gray(4))
+	// the last frame fades back to the first one
+	assert.True(t, gray(5) > gray(7))
+
+	animation.Frames = animation.Frames[:1]
+	frames, err = animation.Render(context.Background(), 16, 16)
+	if assert.NoError(t, err) {
+		assert.Len(t, frames, 1)
+	}
+
+	_, err = Animation{}.Render(context.Background(), 16, 16)
+	assert.Equal(t, ErrNoFrames, err)
+}
@@ -0,0 +1,91 @@
+package mosaic
+
+import (
+	"fmt"
+	"github.com/disintegration/imaging"
+	"github.com/fogleman/gg"
+	"github.com/gieseladev/mosaic/pkg/palette"
+	"image"
+	"math"
+)
+
+// Backgrounds which can be selected using the "background" option.
+const (
+	// BackgroundNone leaves the canvas untouched.
+	BackgroundNone = "none"
+	// BackgroundSolid fills the canvas with the dominant color of the
+	// images.
+	BackgroundSolid = "solid"
+	// BackgroundGradient fills the canvas with a diagonal gradient between
+	// the two most dominant colors of the images.
+	BackgroundGradient = "gradient"
+	// BackgroundBlur fills the canvas with a blurred copy of the first
+	// image.
+	BackgroundBlur = "blur"
+)
+
+// Backgrounds contains the names of all backgrounds.
+var Backgrounds = []string{BackgroundNone, BackgroundSolid, BackgroundGradient, BackgroundBlur}
+
+// blurScale is the factor by which the first image is scaled down before
+// it's blurred for BackgroundBlur.
+const blurScale = 8
+
+func checkBackground(value interface{}) error {
+	for _, name := range Backgrounds {
+		if value == name {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown background %q", value)
+}
+
+// hasBackground checks whether the options select a background.
+func hasBackground(opts Options) bool {
+	name := opts.Str("background")
+	return name != "" && name != BackgroundNone
+}
+
+// DrawBackground fills the canvas with the given background derived from
+// the images.
+func DrawBackground(dc *gg.Context, background string, images ...image.Image) error {
+	if err := checkBackground(background); err != nil {
+		return err
+	}
+
+	if background == BackgroundNone || len(images) == 0 {
+		return nil
+	}
+
+	w, h := dc.Width(), dc.Height()
+
+	switch background {
+	case BackgroundSolid, BackgroundGradient:
+		colors := palette.Dominant(2, images...)
+		if len(colors) == 0 {
+			return nil
+		}
+
+		if background == BackgroundSolid || len(colors) == 1 {
+			dc.SetColor(colors[0])
+		} else {
+			gradient := gg.NewLinearGradient(0, 0, float64(w), float64(h))
+			gradient.AddColorStop(0, colors[0])
+			gradient.AddColorStop(1, colors[1])
+			dc.SetFillStyle(gradient)
+		}
+
+		dc.DrawRectangle(0, 0, float64(w), float64(h))
+		dc.Fill()
+	case BackgroundBlur:
+		smallW := int(math.Max(1, float64(w)/blurScale))
+		smallH := int(math.Max(1, float64(h)/blurScale))
+
+		small := imaging.Fill(images[0], smallW, smallH, imaging.Center, imaging.Linear)
+		blurred := imaging.Blur(small, 2)
+		dc.DrawImage(imaging.Resize(blurred, w, h, imaging.Linear), 0, 0)
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package mosaic
+
+import (
+	"github.com/fogleman/gg"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestDrawBackground(t *testing.T) {
+	red := color.NRGBA{255, 0, 0, 255}
+	blue := color.NRGBA{0, 0, 255, 255}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	draw.Draw(img, img.Bounds(), image.NewUniform(red), image.ZP, draw.Src)
+	draw.Draw(img, image.Rect(30, 0, 40, 40), image.NewUniform(blue), image.ZP, draw.Src)
+
+	dc := gg.NewContext(20, 10)
+	assert.NoError(t, DrawBackground(dc, BackgroundNone, img))
+	assert.Equal(t, color.RGBA{}, dc.Image().At(0, 0))
+
+	assert.NoError(t, DrawBackground(dc, BackgroundSolid, img))
+	assert.Equal(t, color.RGBA{255, 0, 0, 255}, dc.Image().At(0, 0))
+	assert.Equal(t, color.RGBA{255, 0, 0, 255}, dc.Image().At(19, 9))
+
+	assert.NoError(t, DrawBackground(dc, BackgroundGradient, img))
+	r, _, b, _ := dc.Image().At(0, 0).RGBA()
+	assert.True(t, r > b, "gradient should start with the dominant color")
+	r, _, b, _ = dc.Image().At(19, 9).RGBA()
+	assert.True(t, b > r, "gradient should end with the second color")
+
+	dc = gg.NewContext(20, 10)
+	assert.NoError(t, DrawBackground(dc, BackgroundBlur, img))
+	for _, p := range []image.Point{{0, 0}, {19, 9}} {
+		_, _, _, a := dc.Image().At(p.X, p.Y).RGBA()
+		assert.Equal(t, uint32(0xffff), a)
+	}
+
+	assert.Error(t, DrawBackground(dc, "stripes", img))
+}
+
+func TestBackgroundOption(t *testing.T) {
+	composer, ok := GetComposer("circles-pie")
+	if !assert.True(t, ok) {
+		return
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.NRGBA{0, 255, 0, 255}), image.ZP, draw.Src)
+
+	dc := gg.NewContext(20, 10)
+	assert.NoError(t, composer.Compose(dc, Options{"background": BackgroundSolid}, img))
+
+	// the corner isn't covered by the pie
+	assert.Equal(t, color.RGBA{0, 255, 0, 255}, dc.Image().At(0, 0))
+}
+
+func TestBackgroundOption_Gutters(t *testing.T) {
+	composer, ok := GetComposer("tiles-perfect")
+	if !assert.True(t, ok) {
+		return
+	}
+
+	red := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	draw.Draw(red, red.Bounds(), image.NewUniform(color.NRGBA{255, 0, 0, 255}), image.ZP, draw.Src)
+	transparent := image.NewNRGBA(red.Bounds())
+
+	dc := gg.NewContext(100, 100)
+	err := composer.Compose(dc, Options{
+		"background":   BackgroundSolid,
+		"gutter":       10,
+		"gutter-color": "#00ff00",
+	}, transparent, red, red, red)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// the gutters use their color, but the transparent tile shows the
+	// background instead
+	assert.Equal(t, color.RGBA{0, 255, 0, 255}, dc.Image().At(50, 25))
+	assert.Equal(t, color.RGBA{255, 0, 0, 255}, dc.Image().At(25, 25))
+}
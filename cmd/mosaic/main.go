@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/fogleman/gg"
 	"github.com/gieseladev/mosaic"
@@ -8,40 +9,176 @@ import (
 	"gopkg.in/urfave/cli.v2"
 	"image"
 	"os"
+	"os/signal"
+	"strings"
+	"time"
 )
 
-func loadImages(c *cli.Context) ([]image.Image, error) {
-	if c.NArg() == 0 {
+// getLocations returns the locations of the images given as arguments and
+// in the lists given by the list flag with directories and glob patterns
+// expanded.
+func getLocations(c *cli.Context) ([]string, error) {
+	locations := c.Args().Slice()
+	for _, path := range c.StringSlice("list") {
+		listed, err := mosaicc.LoadLocationList(path)
+		if err != nil {
+			return nil, cli.Exit(err.Error(), 1)
+		}
+
+		locations = append(locations, listed...)
+	}
+
+	locations, err := mosaicc.ExpandLocations(locations)
+	if err != nil {
+		return nil, cli.Exit(err.Error(), 1)
+	}
+
+	if len(locations) == 0 {
 		return nil, cli.Exit("at least one input image required", 1)
 	}
 
+	return locations, nil
+}
+
+// loadImages loads the images given by the arguments and returns them
+// together with their locations. If the downscale flag is set, the images
+// are downscaled to cover the target size.
+func loadImages(c *cli.Context, loader *mosaicc.Loader, target image.Point) ([]image.Image, []string, error) {
 	outputPath := c.String("output")
 	if outputPath == "" {
-		return nil, cli.Exit("output path required", 1)
+		return nil, nil, cli.Exit("output path required", 1)
+	}
+
+	locations, err := getLocations(c)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return mosaicc.LoadImages(c.Args().Slice())
+	return loadLocations(c, loader, locations, target)
 }
 
-func getComposer(c *cli.Context, count int) (composer mosaic.ComposerInfo, err error) {
-	id := c.String("composer")
+// loadLocations loads the images at the locations using a copy of the
+// loader configured by the loader flags and returns them together with the
+// locations which could be loaded.
+func loadLocations(c *cli.Context, base *mosaicc.Loader, locations []string, target image.Point) ([]image.Image, []string, error) {
+	var err error
 
-	switch id {
-	case "":
-		fallthrough
-	case "random":
-		composer = mosaic.RecommendComposers(count)[0]
+	l := *base
+	loader := &l
+	loader.Policy = c.String("on-error")
+	if err := mosaicc.CheckFailurePolicy(loader.Policy); err != nil {
+		return nil, nil, cli.Exit(err.Error(), 1)
+	}
 
-	default:
-		var ok bool
-		composer, ok = mosaic.GetComposer(id)
-		if !ok {
-			err = cli.Exit(fmt.Sprintf("no composer %q found", id), 1)
-			return
+	if path := c.String("placeholder"); path != "" {
+		if loader.Placeholder, err = mosaicc.LoadImage(path); err != nil {
+			return nil, nil, cli.Exit(err.Error(), 1)
 		}
 	}
 
-	return
+	loader.Workers = c.Int("workers")
+	loader.MaxPerHost = c.Int("max-per-host")
+	if c.Bool("downscale") {
+		loader.TargetSize = target
+	}
+
+	failed := make(map[string]bool)
+	loader.OnFailure = func(err *mosaicc.LoadError) {
+		_, _ = fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		failed[err.Location] = true
+	}
+
+	if c.Bool("progress") {
+		loader.OnProgress = func(p mosaicc.Progress) {
+			_, _ = fmt.Fprintf(os.Stderr, "loaded %d/%d images\n", p.Done, p.Total)
+		}
+	}
+
+	// stop outstanding downloads on interrupt
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	images, err := loader.LoadAll(ctx, locations)
+	if ctx.Err() != nil {
+		return nil, nil, cli.Exit("interrupted", 130)
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	if loader.Policy == mosaicc.PolicySkip {
+		loaded := locations[:0]
+		for _, location := range locations {
+			if !failed[location] {
+				loaded = append(loaded, location)
+			}
+		}
+
+		locations = loaded
+	}
+
+	return images, locations, nil
+}
+
+// registerLayouts registers the layout files given by the layout flag.
+func registerLayouts(c *cli.Context) ([]mosaic.ComposerInfo, error) {
+	layouts, err := mosaicc.RegisterLayouts(c.StringSlice("layout"))
+	if err != nil {
+		return nil, cli.Exit(err.Error(), 1)
+	}
+
+	return layouts, nil
+}
+
+// getComposer returns the composer selected by the composer flag. If it
+// isn't set and a single layout file was loaded, the layout is used.
+// Otherwise a random composer is chosen using the seed flag, which defaults
+// to a seed derived from the images.
+func getComposer(c *cli.Context, layouts []mosaic.ComposerInfo, images []image.Image) (mosaic.ComposerInfo, error) {
+	id := c.String("composer")
+	if id == "" && len(layouts) == 1 {
+		id = layouts[0].Id
+	}
+
+	seed := c.Int64("seed")
+	if !c.IsSet("seed") {
+		seed = mosaic.ImageSeed(images...)
+	}
+
+	composer, err := mosaicc.FindComposer(id, len(images), seed)
+	if err != nil {
+		return composer, cli.Exit(err.Error(), 1)
+	}
+
+	return composer, nil
+}
+
+func getOptions(c *cli.Context, composer mosaic.ComposerInfo) (mosaic.Options, error) {
+	raw, err := mosaicc.ParseOptionArgs(c.StringSlice("opt"))
+	if err != nil {
+		return nil, cli.Exit(err.Error(), 1)
+	}
+
+	if c.IsSet("crop") {
+		raw["crop"] = c.String("crop")
+	}
+
+	opts, err := composer.ParseOptions(raw)
+	if err != nil {
+		return nil, cli.Exit(err.Error(), 1)
+	}
+
+	return opts, nil
 }
 
 func getDimensions(c *cli.Context) (int, int) {
@@ -59,12 +196,460 @@ func getDimensions(c *cli.Context) (int, int) {
 	return width, height
 }
 
+// getEncoder returns the encoder for the output format given by the format
+// flag or the extension of the output path.
+func getEncoder(c *cli.Context) (mosaicc.Encoder, mosaicc.EncodeOptions, error) {
+	opts := mosaicc.EncodeOptions{
+		Quality:  c.Int("quality"),
+		Colors:   c.Int("colors"),
+		Quantize: c.Bool("quantize"),
+	}
+
+	if opts.Quality < 1 || opts.Quality > 100 {
+		return mosaicc.Encoder{}, opts, cli.Exit("quality must be between 1 and 100", 1)
+	}
+
+	if opts.Colors < 2 || opts.Colors > 256 {
+		return mosaicc.Encoder{}, opts, cli.Exit("colors must be between 2 and 256", 1)
+	}
+
+	encoder, err := mosaicc.ResolveEncoder(c.String("format"), c.String("output"))
+	if err != nil {
+		return encoder, opts, cli.Exit(err.Error(), 1)
+	}
+
+	return encoder, opts, nil
+}
+
+// getPrintOptions returns the physical size of PDF output given by the
+// print flags. Without a paper size the width and height are printed at
+// the DPI.
+func getPrintOptions(c *cli.Context) (mosaicc.PrintOptions, error) {
+	print := mosaicc.PrintOptions{
+		DPI:       c.Float64("dpi"),
+		Bleed:     c.Float64("bleed"),
+		CropMarks: c.Bool("crop-marks"),
+	}
+
+	if print.DPI <= 0 {
+		return print, cli.Exit("dpi must be positive", 1)
+	}
+
+	if size := c.String("size"); size != "" {
+		w, h, err := mosaicc.ParsePaperSize(size)
+		if err != nil {
+			return print, cli.Exit(err.Error(), 1)
+		}
+
+		print.Width, print.Height = w, h
+	} else {
+		w, h := getDimensions(c)
+		print.Width = float64(w) / print.DPI * mosaicc.MillimetresPerInch
+		print.Height = float64(h) / print.DPI * mosaicc.MillimetresPerInch
+	}
+
+	if err := print.Validate(); err != nil {
+		return print, cli.Exit(err.Error(), 1)
+	}
+
+	return print, nil
+}
+
+// getTextOverlay returns the text overlay described by the text flags.
+func getTextOverlay(c *cli.Context) (mosaic.TextOverlay, error) {
+	overlay := mosaic.TextOverlay{
+		Title:     c.String("title"),
+		Caption:   c.String("caption"),
+		Placement: c.String("text-placement"),
+		Style:     c.String("text-style"),
+	}
+
+	if s := c.String("text-color"); s != "" {
+		textColor, err := mosaic.ParseColor(s)
+		if err != nil {
+			return overlay, cli.Exit(err.Error(), 1)
+		}
+
+		overlay.Color = textColor
+	}
+
+	if path := c.String("font"); path != "" {
+		f, err := mosaic.LoadFont(path)
+		if err != nil {
+			return overlay, cli.Exit(fmt.Sprintf("couldn't load font: %v", err), 1)
+		}
+
+		overlay.TitleFont, overlay.CaptionFont = f, f
+	}
+
+	if err := overlay.Validate(); err != nil {
+		return overlay, cli.Exit(err.Error(), 1)
+	}
+
+	return overlay, nil
+}
+
+// getShowcaseOptions returns the showcase options described by the flags.
+func getShowcaseOptions(c *cli.Context) (mosaicc.ShowcaseOptions, error) {
+	opts := mosaicc.ShowcaseOptions{
+		PanelSize: c.Int("panel-size"),
+		Columns:   c.Int("columns"),
+		Margin:    c.Int("margin"),
+		Labels:    c.Bool("labels"),
+		Details:   c.Bool("details"),
+		Composers: c.StringSlice("composer"),
+	}
+
+	var err error
+	if opts.Background, err = mosaic.ParseColor(c.String("background")); err != nil {
+		return opts, cli.Exit(err.Error(), 1)
+	}
+
+	if opts.LabelColor, err = mosaic.ParseColor(c.String("label-color")); err != nil {
+		return opts, cli.Exit(err.Error(), 1)
+	}
+
+	return opts, nil
+}
+
+// getCache returns the cache selected by the cache flags or nil if caching
+// is disabled.
+func getCache(c *cli.Context, always bool) (*mosaicc.Cache, error) {
+	if !always && !c.Bool("cache") && !c.IsSet("cache-dir") {
+		return nil, nil
+	}
+
+	dir := c.String("cache-dir")
+	if dir == "" {
+		var err error
+		if dir, err = mosaicc.DefaultCacheDir(); err != nil {
+			return nil, cli.Exit(fmt.Sprintf("couldn't determine cache directory: %v", err), 1)
+		}
+	}
+
+	return mosaicc.NewCache(dir, c.Int64("cache-size")<<20), nil
+}
+
+// getLoader returns a copy of the DefaultLoader which uses the cache
+// selected by the cache flags.
+func getLoader(c *cli.Context) (*mosaicc.Loader, error) {
+	cache, err := getCache(c, false)
+	if err != nil {
+		return nil, err
+	}
+
+	loader := *mosaicc.DefaultLoader
+	loader.Cache = cache
+	return &loader, nil
+}
+
+// getAnimation returns the animation described by the animation flags.
+func getAnimation(c *cli.Context, images []image.Image) (mosaic.Animation, error) {
+	animation := mosaic.Animation{
+		FrameDuration:     c.Duration("frame-duration"),
+		Crossfade:         c.Int("crossfade"),
+		CrossfadeDuration: c.Duration("crossfade-duration"),
+	}
+
+	if animation.Crossfade < 0 {
+		return animation, cli.Exit("crossfade must not be negative", 1)
+	}
+
+	ids := c.StringSlice("composer")
+
+	switch cycle := c.String("cycle"); cycle {
+	case "focus":
+		if len(ids) > 1 {
+			return animation, cli.Exit("focus cycle uses a single composer", 1)
+		}
+
+		var id string
+		if len(ids) == 1 {
+			id = ids[0]
+		}
+
+		seed := c.Int64("seed")
+		if !c.IsSet("seed") {
+			seed = mosaic.ImageSeed(images...)
+		}
+
+		composer, err := mosaicc.FindComposer(id, len(images), seed)
+		if err != nil {
+			return animation, cli.Exit(err.Error(), 1)
+		}
+
+		opts, err := getOptions(c, composer)
+		if err != nil {
+			return animation, err
+		}
+
+		animation.Frames = mosaic.FocusFrames(composer, opts, images)
+	case "composers":
+		composers := mosaic.GetComposers()
+		if len(ids) > 0 {
+			composers = make([]mosaic.ComposerInfo, len(ids))
+			for i, id := range ids {
+				composer, ok := mosaic.GetComposer(id)
+				if !ok {
+					return animation, cli.Exit(fmt.Sprintf("no composer %q found", id), 1)
+				}
+
+				composers[i] = composer
+			}
+		}
+
+		animation.Frames = mosaic.ComposerFrames(composers, images)
+		for i := range animation.Frames {
+			opts, err := getOptions(c, animation.Frames[i].Composer)
+			if err != nil {
+				return animation, err
+			}
+
+			animation.Frames[i].Options = opts
+		}
+	default:
+		return animation, cli.Exit(fmt.Sprintf("unknown cycle %q", cycle), 1)
+	}
+
+	return animation, nil
+}
+
+// transitionSide is one of the compositions of a transition.
+type transitionSide struct {
+	Layout  mosaic.Layout
+	Images  []image.Image
+	Options mosaic.Options
+}
+
+// getTransitionSide chooses a composer for the images and returns its
+// layout.
+func getTransitionSide(c *cli.Context, id string, images []image.Image) (transitionSide, error) {
+	seed := mosaic.ImageSeed(images...)
+	if c.IsSet("seed") {
+		seed = c.Int64("seed")
+	}
+
+	composer, err := mosaicc.FindComposer(id, len(images), seed)
+	if err != nil {
+		return transitionSide{}, cli.Exit(err.Error(), 1)
+	}
+
+	opts, err := getOptions(c, composer)
+	if err != nil {
+		return transitionSide{}, err
+	}
+
+	images = images[:composer.RecommendImageCount(len(images))]
+
+	width, height := getDimensions(c)
+	layout, err := composer.Layout(width, height, opts, len(images))
+	if err != nil {
+		return transitionSide{}, cli.Exit(fmt.Sprintf("composer %q: %v", composer.Id, err), 1)
+	}
+
+	return transitionSide{Layout: layout, Images: images, Options: opts}, nil
+}
+
+// getTransition loads the images given by the from flag and the arguments
+// and returns the transition between their compositions. Images given on
+// both sides are loaded once, so their regions move. Like for generate, the
+// composition transitioned to uses the layout if it's the only one.
+func getTransition(c *cli.Context, loader *mosaicc.Loader, layouts []mosaic.ComposerInfo) (mosaic.Transition, error) {
+	from, err := mosaicc.ExpandLocations(c.StringSlice("from"))
+	if err != nil {
+		return mosaic.Transition{}, cli.Exit(err.Error(), 1)
+	}
+
+	if len(from) == 0 {
+		return mosaic.Transition{}, cli.Exit("at least one image to transition from required", 1)
+	}
+
+	to, err := getLocations(c)
+	if err != nil {
+		return mosaic.Transition{}, err
+	}
+
+	var unique []string
+	seen := make(map[string]bool)
+	for _, location := range append(append([]string{}, from...), to...) {
+		if !seen[location] {
+			seen[location] = true
+			unique = append(unique, location)
+		}
+	}
+
+	images, locations, err := loadLocations(c, loader, unique, image.Pt(getDimensions(c)))
+	if err != nil {
+		return mosaic.Transition{}, err
+	}
+
+	byLocation := make(map[string]image.Image, len(locations))
+	for i, location := range locations {
+		byLocation[location] = images[i]
+	}
+
+	sides := make([]transitionSide, 2)
+	for i, side := range [][]string{from, to} {
+		var sideImages []image.Image
+		for _, location := range side {
+			if img, ok := byLocation[location]; ok {
+				sideImages = append(sideImages, img)
+			}
+		}
+
+		if len(sideImages) == 0 {
+			return mosaic.Transition{}, cli.Exit("no images left to compose", 1)
+		}
+
+		id := c.String([]string{"from-composer", "composer"}[i])
+		if id == "" && i == 1 && len(layouts) == 1 {
+			id = layouts[0].Id
+		}
+
+		if sides[i], err = getTransitionSide(c, id, sideImages); err != nil {
+			return mosaic.Transition{}, err
+		}
+	}
+
+	return mosaic.Transition{
+		From:        sides[0].Layout,
+		FromImages:  sides[0].Images,
+		FromOptions: sides[0].Options,
+		To:          sides[1].Layout,
+		ToImages:    sides[1].Images,
+		ToOptions:   sides[1].Options,
+	}, nil
+}
+
+// writeImageMap writes an HTML image map for the layout of the composition
+// linking each region to the location of its image.
+func writeImageMap(path string, composer mosaic.ComposerInfo, width, height int, opts mosaic.Options, locations []string) error {
+	layout, err := composer.Layout(width, height, opts, len(locations))
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := mosaicc.WriteImageMap(f, "mosaic", layout, locations); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
 func main() {
+	layoutFlag := &cli.StringSliceFlag{
+		Name:  "layout",
+		Usage: "load a layout file (JSON or YAML) or a directory of layout files as composers",
+	}
+
+	composerFlag := &cli.StringFlag{
+		Name:        "composer",
+		Aliases:     []string{"c"},
+		Usage:       "composer of the composition, see the composers command",
+		DefaultText: "random, or the layout if a single layout file is given",
+	}
+	seedFlag := &cli.Int64Flag{
+		Name:        "seed",
+		Usage:       "seed used to choose random composers",
+		DefaultText: "derived from the images",
+	}
+	optFlag := &cli.StringSliceFlag{
+		Name:  "opt",
+		Usage: "set a composer option (key=value), see the composers command",
+	}
+	cropFlag := &cli.StringFlag{
+		Name:  "crop",
+		Usage: fmt.Sprintf("cropper deciding which part of the images is kept (%s)", strings.Join(mosaic.GetCropperNames(), ", ")),
+		Value: "center",
+	}
+	timeoutFlag := &cli.DurationFlag{
+		Name:  "timeout",
+		Usage: "abort rendering if it takes longer than this",
+	}
+
+	downscaleFlag := &cli.BoolFlag{
+		Name:  "downscale",
+		Usage: "downscale large images to the size needed for the output while loading them",
+	}
+	cacheFlag := &cli.BoolFlag{
+		Name:  "cache",
+		Usage: "cache remote images on disk, implied by --cache-dir",
+	}
+	cacheDirFlag := &cli.StringFlag{
+		Name:        "cache-dir",
+		Usage:       "directory of the cache for remote images",
+		DefaultText: "user cache directory",
+	}
+	cacheSizeFlag := &cli.Int64Flag{
+		Name:  "cache-size",
+		Usage: "maximum size of the cache in MiB",
+		Value: 256,
+	}
+
 	flags := []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "list",
+			Usage: "read image locations from a file, one per line",
+		},
+		&cli.StringFlag{
+			Name:  "on-error",
+			Usage: fmt.Sprintf("what to do with images which can't be loaded (%s)", strings.Join(mosaicc.FailurePolicies, ", ")),
+			Value: mosaicc.PolicyFail,
+		},
+		&cli.StringFlag{
+			Name:        "placeholder",
+			Usage:       "image used in place of images which can't be loaded with --on-error placeholder",
+			DefaultText: "dominant color of the other images",
+		},
+		&cli.IntFlag{
+			Name:  "workers",
+			Usage: "maximum amount of images loaded at once",
+			Value: mosaicc.DefaultLoader.Workers,
+		},
+		&cli.IntFlag{
+			Name:  "max-per-host",
+			Usage: "maximum amount of images loaded from the same host at once, 0 for no limit",
+			Value: mosaicc.DefaultLoader.MaxPerHost,
+		},
+		&cli.BoolFlag{
+			Name:  "progress",
+			Usage: "report the progress of loading the images",
+		},
+		downscaleFlag,
+		cacheFlag,
+		cacheDirFlag,
+		cacheSizeFlag,
 		&cli.StringFlag{
 			Name:    "output",
 			Aliases: []string{"o"},
-			Usage:   "path to write output image to",
+			Usage:   "path to write output image to, - for stdout",
+		},
+		&cli.StringFlag{
+			Name:    "format",
+			Aliases: []string{"f"},
+			Usage:   fmt.Sprintf("output format (%s), generate also supports %s and %s", strings.Join(mosaicc.GetEncoderNames(), ", "), mosaicc.SVGFormat, mosaicc.PDFFormat),
+
+			DefaultText: "extension of output path, png for stdout",
+		},
+		&cli.IntFlag{
+			Name:  "quality",
+			Usage: "quality of JPEG output (1-100)",
+			Value: mosaicc.DefaultEncodeOptions.Quality,
+		},
+		&cli.IntFlag{
+			Name:  "colors",
+			Usage: "maximum amount of colors in GIF output",
+			Value: mosaicc.DefaultEncodeOptions.Colors,
+		},
+		&cli.BoolFlag{
+			Name:  "quantize",
+			Usage: "derive the GIF palette from the image instead of using a fixed palette",
 		},
 		&cli.IntFlag{
 			Name:  "width",
@@ -93,12 +678,324 @@ func main() {
 				ArgsUsage: "<image>...",
 
 				Flags: append([]cli.Flag{
+					composerFlag,
+					seedFlag,
+					layoutFlag,
+					optFlag,
+					cropFlag,
+					timeoutFlag,
+					&cli.StringFlag{
+						Name:  "image-map",
+						Usage: "path to write an HTML image map linking the regions to their images to",
+					},
+					&cli.StringFlag{
+						Name:  "title",
+						Usage: "title drawn on top of the composition",
+					},
+					&cli.StringFlag{
+						Name:  "caption",
+						Usage: "caption drawn below the title",
+					},
+					&cli.StringFlag{
+						Name:  "text-placement",
+						Usage: fmt.Sprintf("placement of the title and caption (%s)", strings.Join(mosaic.TextPlacements, ", ")),
+						Value: mosaic.PlacementBanner,
+					},
+					&cli.StringFlag{
+						Name:  "text-style",
+						Usage: fmt.Sprintf("style of the title and caption (%s)", strings.Join(mosaic.TextStyles, ", ")),
+						Value: mosaic.TextStyleShadow,
+					},
+					&cli.StringFlag{
+						Name:        "text-color",
+						Usage:       "color of the title and caption",
+						DefaultText: "black or white, whichever contrasts more",
+					},
 					&cli.StringFlag{
+						Name:        "font",
+						Usage:       "path to a TrueType font used for the title and caption",
+						DefaultText: "embedded Go font",
+					},
+					&cli.StringFlag{
+						Name:        "size",
+						Usage:       fmt.Sprintf("paper size of PDF output (%s) or WIDTHxHEIGHT in millimetres", strings.Join(mosaicc.GetPaperSizeNames(), ", ")),
+						DefaultText: "width and height printed at the DPI",
+					},
+					&cli.Float64Flag{
+						Name:  "dpi",
+						Usage: "resolution of PDF output",
+						Value: 300,
+					},
+					&cli.Float64Flag{
+						Name:  "bleed",
+						Usage: "how far PDF output extends beyond the paper size in millimetres",
+					},
+					&cli.BoolFlag{
+						Name:  "crop-marks",
+						Usage: "add marks showing where to trim PDF output",
+					},
+				}, flags...),
+
+				Action: func(c *cli.Context) error {
+					outputPath := c.String("output")
+					if outputPath == "" {
+						return cli.Exit("output path required", 1)
+					}
+
+					// SVGs and PDFs are written from the layout of the composition
+					var vector string
+					switch format := c.String("format"); {
+					case mosaicc.IsSVG(format, outputPath):
+						vector = mosaicc.SVGFormat
+					case mosaicc.IsPDF(format, outputPath):
+						vector = mosaicc.PDFFormat
+					}
+
+					var encoder mosaicc.Encoder
+					var encodeOpts mosaicc.EncodeOptions
+					if vector == "" {
+						var err error
+						encoder, encodeOpts, err = getEncoder(c)
+						if err != nil {
+							return err
+						}
+					}
+
+					width, height := getDimensions(c)
+
+					var print mosaicc.PrintOptions
+					if vector == mosaicc.PDFFormat {
+						var err error
+						if print, err = getPrintOptions(c); err != nil {
+							return err
+						}
+
+						width, height = print.PixelSize()
+					}
+
+					overlay, err := getTextOverlay(c)
+					if err != nil {
+						return err
+					}
+
+					if vector != "" && (overlay.Title != "" || overlay.Caption != "") {
+						return cli.Exit(fmt.Sprintf("title and caption aren't supported for %s output", vector), 1)
+					}
+
+					layouts, err := registerLayouts(c)
+					if err != nil {
+						return err
+					}
+
+					loader, err := getLoader(c)
+					if err != nil {
+						return err
+					}
+
+					images, locations, err := loadImages(c, loader, image.Pt(width, height))
+					if err != nil {
+						return err
+					}
+
+					composer, err := getComposer(c, layouts, images)
+					if err != nil {
+						return err
+					}
+
+					opts, err := getOptions(c, composer)
+					if err != nil {
+						return err
+					}
+
+					ctx := context.Background()
+					if timeout := c.Duration("timeout"); timeout > 0 {
+						var cancel context.CancelFunc
+						ctx, cancel = context.WithTimeout(ctx, timeout)
+						defer cancel()
+					}
+
+					imgCount := composer.RecommendImageCount(len(images))
+					if vector != "" {
+						if mapPath := c.String("image-map"); mapPath != "" {
+							err = writeImageMap(mapPath, composer, width, height, opts, locations[:imgCount])
+							if err != nil {
+								return err
+							}
+						}
+
+						if vector == mosaicc.SVGFormat {
+							err = mosaicc.SaveSVG(ctx, outputPath, composer, width, height, opts, images[:imgCount]...)
+						} else {
+							err = mosaicc.SavePDF(ctx, outputPath, composer, print, opts, images[:imgCount]...)
+						}
+
+						if err == mosaic.ErrNoLayout {
+							return cli.Exit(fmt.Sprintf("composer %q doesn't support %s output", composer.Id, vector), 1)
+						}
+
+						return err
+					}
+
+					dc := gg.NewContext(width, height)
+					err = composer.ComposeContext(ctx, dc, opts, images[:imgCount]...)
+					if err != nil {
+						return err
+					}
+
+					if err := overlay.Draw(dc); err != nil {
+						return err
+					}
+
+					if mapPath := c.String("image-map"); mapPath != "" {
+						err = writeImageMap(mapPath, composer, width, height, opts, locations[:imgCount])
+						if err != nil {
+							return err
+						}
+					}
+
+					return mosaicc.SaveImage(outputPath, encoder, dc.Image(), encodeOpts)
+				},
+			},
+			{
+				Name:      "animate",
+				Usage:     "generate an animation cycling through compositions",
+				ArgsUsage: "<image>...",
+
+				Flags: append([]cli.Flag{
+					&cli.StringSliceFlag{
 						Name:        "composer",
 						Aliases:     []string{"c"},
-						Usage:       "use specific composer",
+						Usage:       "composer to use, can be given multiple times to cycle through composers",
+						DefaultText: "random, or all composers when cycling through them",
+					},
+					&cli.StringFlag{
+						Name:  "cycle",
+						Usage: "what changes between frames: focus rotates the images, composers switches the composer",
+						Value: "focus",
+					},
+					seedFlag,
+					layoutFlag,
+					optFlag,
+					cropFlag,
+					timeoutFlag,
+					&cli.DurationFlag{
+						Name:  "frame-duration",
+						Usage: "how long each composition is shown",
+						Value: 2 * time.Second,
+					},
+					&cli.IntFlag{
+						Name:  "crossfade",
+						Usage: "amount of frames blending a composition into the next one",
+						Value: 8,
+					},
+					&cli.DurationFlag{
+						Name:  "crossfade-duration",
+						Usage: "duration of the crossfade",
+						Value: 500 * time.Millisecond,
+					},
+					&cli.IntFlag{
+						Name:        "loops",
+						Usage:       "how often the animation is played",
+						DefaultText: "forever",
+					},
+				}, flags...),
+
+				Action: func(c *cli.Context) error {
+					outputPath := c.String("output")
+					if outputPath == "" {
+						return cli.Exit("output path required", 1)
+					}
+
+					encoder, encodeOpts, err := getEncoder(c)
+					if err != nil {
+						return err
+					}
+
+					if encoder.EncodeAnimation == nil {
+						return cli.Exit(fmt.Sprintf("format %q doesn't support animations, use gif or png", encoder.Name), 1)
+					}
+
+					encodeOpts.Loops = c.Int("loops")
+
+					if _, err := registerLayouts(c); err != nil {
+						return err
+					}
+
+					loader, err := getLoader(c)
+					if err != nil {
+						return err
+					}
+
+					images, _, err := loadImages(c, loader, image.Pt(getDimensions(c)))
+					if err != nil {
+						return err
+					}
+
+					animation, err := getAnimation(c, images)
+					if err != nil {
+						return err
+					}
+
+					ctx := context.Background()
+					if timeout := c.Duration("timeout"); timeout > 0 {
+						var cancel context.CancelFunc
+						ctx, cancel = context.WithTimeout(ctx, timeout)
+						defer cancel()
+					}
+
+					width, height := getDimensions(c)
+					frames, err := animation.Render(ctx, width, height)
+					if err != nil {
+						return err
+					}
+
+					return mosaicc.SaveAnimation(outputPath, encoder, frames, encodeOpts)
+				},
+			},
+			{
+				Name:      "transition",
+				Usage:     "generate an animation morphing one composition into another",
+				ArgsUsage: "<image>...",
+				Description: "The regions of images used by both compositions slide into their new place,\n" +
+					"the others fade out or in. The output path may contain a verb like %03d to\n" +
+					"write every frame to a separate file.",
+
+				Flags: append([]cli.Flag{
+					&cli.StringSliceFlag{
+						Name:  "from",
+						Usage: "image of the composition to transition from, can be given multiple times",
+					},
+					&cli.StringFlag{
+						Name:        "from-composer",
+						Usage:       "composer of the composition to transition from",
 						DefaultText: "random",
 					},
+					composerFlag,
+					seedFlag,
+					layoutFlag,
+					optFlag,
+					cropFlag,
+					timeoutFlag,
+					&cli.IntFlag{
+						Name:  "frames",
+						Usage: "amount of frames including the first and the last composition",
+						Value: 12,
+					},
+					&cli.DurationFlag{
+						Name:  "duration",
+						Usage: "duration of the transition",
+						Value: time.Second,
+					},
+					&cli.DurationFlag{
+						Name:  "hold",
+						Usage: "how long the first and the last composition are shown",
+						Value: time.Second,
+					},
+					&cli.IntFlag{
+						Name:        "loops",
+						Usage:       "how often the animation is played",
+						DefaultText: "forever",
+					},
 				}, flags...),
 
 				Action: func(c *cli.Context) error {
@@ -107,32 +1004,246 @@ func main() {
 						return cli.Exit("output path required", 1)
 					}
 
-					composer, err := getComposer(c, c.NArg())
+					encoder, encodeOpts, err := getEncoder(c)
 					if err != nil {
 						return err
 					}
 
-					dc := gg.NewContext(getDimensions(c))
+					sequence := mosaicc.IsFramePattern(outputPath)
+					if !sequence && encoder.EncodeAnimation == nil {
+						return cli.Exit(fmt.Sprintf("format %q doesn't support animations, use gif, png or a frame pattern like frame-%%03d.png", encoder.Name), 1)
+					}
+
+					encodeOpts.Loops = c.Int("loops")
 
-					images, err := loadImages(c)
+					layouts, err := registerLayouts(c)
 					if err != nil {
 						return err
 					}
 
-					imgCount := composer.RecommendImageCount(len(images))
-					err = composer.Compose(dc, images[:imgCount]...)
+					loader, err := getLoader(c)
 					if err != nil {
 						return err
 					}
 
-					return dc.SavePNG(outputPath)
+					transition, err := getTransition(c, loader, layouts)
+					if err != nil {
+						return err
+					}
+
+					ctx := context.Background()
+					if timeout := c.Duration("timeout"); timeout > 0 {
+						var cancel context.CancelFunc
+						ctx, cancel = context.WithTimeout(ctx, timeout)
+						defer cancel()
+					}
+
+					frames, err := transition.Frames(ctx, c.Int("frames"), c.Duration("duration"))
+					if err != nil {
+						return cli.Exit(err.Error(), 1)
+					}
+
+					if sequence {
+						return mosaicc.SaveFrames(outputPath, encoder, frames, encodeOpts)
+					}
+
+					frames[0].Delay = c.Duration("hold")
+					frames[len(frames)-1].Delay = c.Duration("hold")
+
+					return mosaicc.SaveAnimation(outputPath, encoder, frames, encodeOpts)
+				},
+			},
+			{
+				Name:  "composers",
+				Usage: "list the available composers and their options",
+
+				Flags: []cli.Flag{layoutFlag},
+
+				Action: func(c *cli.Context) error {
+					if _, err := registerLayouts(c); err != nil {
+						return err
+					}
+
+					return mosaicc.WriteComposers(os.Stdout, mosaic.GetComposers())
+				},
+			},
+			{
+				Name:  "serve",
+				Usage: "serve compositions over HTTP",
+
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "addr",
+						Usage: "address to listen on",
+						Value: ":8080",
+					},
+					layoutFlag,
+					downscaleFlag,
+					cacheFlag,
+					cacheDirFlag,
+					cacheSizeFlag,
+					&cli.StringSliceFlag{
+						Name:  "allow-network",
+						Usage: "private network in CIDR notation the server may fetch images from, like 10.0.0.0/8",
+					},
+				},
+
+				Action: func(c *cli.Context) error {
+					if _, err := registerLayouts(c); err != nil {
+						return err
+					}
+
+					cache, err := getCache(c, false)
+					if err != nil {
+						return err
+					}
+
+					allowed, err := mosaicc.ParseNetworks(c.StringSlice("allow-network"))
+					if err != nil {
+						return err
+					}
+
+					server := mosaicc.NewServer()
+					server.Loader.Cache = cache
+					server.Loader.Client = mosaicc.NewPublicClient(allowed...)
+					server.Downscale = c.Bool("downscale")
+
+					addr := c.String("addr")
+					fmt.Printf("listening on %s\n", addr)
+					return server.HTTPServer(addr).ListenAndServe()
+				},
+			},
+			{
+				Name:  "cache",
+				Usage: "inspect and prune the cache of remote images",
+
+				Subcommands: []*cli.Command{
+					{
+						Name:  "info",
+						Usage: "show the location and size of the cache",
+						Flags: []cli.Flag{cacheDirFlag, cacheSizeFlag},
+
+						Action: func(c *cli.Context) error {
+							cache, err := getCache(c, true)
+							if err != nil {
+								return err
+							}
+
+							entries, err := cache.Entries()
+							if err != nil {
+								return err
+							}
+
+							var size int64
+							for _, entry := range entries {
+								size += entry.Size
+							}
+
+							fmt.Printf("directory: %s\nentries: %d\nsize: %.1f MiB of %d MiB\n",
+								cache.Dir, len(entries), float64(size)/(1<<20), cache.MaxSize>>20)
+							return nil
+						},
+					},
+					{
+						Name:  "list",
+						Usage: "list the cached images from the least to the most recently used",
+						Flags: []cli.Flag{cacheDirFlag},
+
+						Action: func(c *cli.Context) error {
+							cache, err := getCache(c, true)
+							if err != nil {
+								return err
+							}
+
+							entries, err := cache.Entries()
+							if err != nil {
+								return err
+							}
+
+							return mosaicc.WriteCacheEntries(os.Stdout, entries)
+						},
+					},
+					{
+						Name:  "prune",
+						Usage: "remove the least recently used images until the cache fits into --cache-size",
+						Flags: []cli.Flag{cacheDirFlag, cacheSizeFlag},
+
+						Action: func(c *cli.Context) error {
+							cache, err := getCache(c, true)
+							if err != nil {
+								return err
+							}
+
+							removed, err := cache.Prune(cache.MaxSize)
+							fmt.Printf("removed %d entries\n", removed)
+							return err
+						},
+					},
+					{
+						Name:  "clear",
+						Usage: "remove all cached images",
+						Flags: []cli.Flag{cacheDirFlag},
+
+						Action: func(c *cli.Context) error {
+							cache, err := getCache(c, true)
+							if err != nil {
+								return err
+							}
+
+							removed, err := cache.Prune(0)
+							fmt.Printf("removed %d entries\n", removed)
+							return err
+						},
+					},
 				},
 			},
 			{
 				Name:  "showcase-gen",
 				Usage: "generate the example image showing all composers",
 
-				Flags: flags,
+				Flags: append([]cli.Flag{
+					&cli.StringSliceFlag{
+						Name:        "composer",
+						Aliases:     []string{"c"},
+						Usage:       "only show the given composers",
+						DefaultText: "all",
+					},
+					layoutFlag,
+					&cli.IntFlag{
+						Name:  "panel-size",
+						Usage: "width and height of the composition of each panel",
+						Value: mosaicc.DefaultShowcaseOptions.PanelSize,
+					},
+					&cli.IntFlag{
+						Name:        "columns",
+						Usage:       "amount of panels per row",
+						DefaultText: "balanced grid",
+					},
+					&cli.IntFlag{
+						Name:  "margin",
+						Usage: "space between the panels",
+						Value: mosaicc.DefaultShowcaseOptions.Margin,
+					},
+					&cli.StringFlag{
+						Name:  "background",
+						Usage: "background color of the showcase",
+						Value: "transparent",
+					},
+					&cli.BoolFlag{
+						Name:  "labels",
+						Usage: "show the name of the composers, use --labels=false to hide them",
+						Value: mosaicc.DefaultShowcaseOptions.Labels,
+					},
+					&cli.BoolFlag{
+						Name:  "details",
+						Usage: "show the description and image count of the composers",
+					},
+					&cli.StringFlag{
+						Name:  "label-color",
+						Usage: "color of the labels",
+						Value: "black",
+					},
+				}, flags...),
 
 				Action: func(c *cli.Context) error {
 					outputPath := c.String("output")
@@ -140,17 +1251,36 @@ func main() {
 						return cli.Exit("output path required", 1)
 					}
 
-					images, err := loadImages(c)
+					encoder, encodeOpts, err := getEncoder(c)
 					if err != nil {
 						return err
 					}
 
-					generated, err := mosaicc.GenerateComposerShowcase(images)
+					if _, err := registerLayouts(c); err != nil {
+						return err
+					}
+
+					showcaseOpts, err := getShowcaseOptions(c)
+					if err != nil {
+						return err
+					}
+
+					loader, err := getLoader(c)
 					if err != nil {
 						return err
 					}
 
-					return gg.SavePNG(outputPath, generated)
+					images, _, err := loadImages(c, loader, image.Pt(showcaseOpts.PanelSize, showcaseOpts.PanelSize))
+					if err != nil {
+						return err
+					}
+
+					generated, err := mosaicc.GenerateComposerShowcase(images, showcaseOpts)
+					if err != nil {
+						return cli.Exit(err.Error(), 1)
+					}
+
+					return mosaicc.SaveImage(outputPath, encoder, generated, encodeOpts)
 				},
 			},
 		},
@@ -158,6 +1288,6 @@ func main() {
 
 	err := app.Run(os.Args)
 	if err != nil {
-		fmt.Println(err)
+		_, _ = fmt.Fprintln(os.Stderr, err)
 	}
 }
@@ -0,0 +1,53 @@
+package mosaic
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+var namedColors = map[string]color.Color{
+	"transparent": color.Transparent,
+	"black":       color.Black,
+	"white":       color.White,
+}
+
+// ParseColor parses a color given in hex notation (#rgb, #rgba, #rrggbb or
+// #rrggbbaa) or one of the names "transparent", "black" and "white".
+func ParseColor(s string) (color.Color, error) {
+	if c, ok := namedColors[strings.ToLower(s)]; ok {
+		return c, nil
+	}
+
+	hex := strings.TrimPrefix(s, "#")
+	switch len(hex) {
+	case 3, 4:
+		var expanded strings.Builder
+		for _, r := range hex {
+			expanded.WriteRune(r)
+			expanded.WriteRune(r)
+		}
+		hex = expanded.String()
+	case 6, 8:
+	default:
+		return nil, fmt.Errorf("invalid color %q", s)
+	}
+
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid color %q", s)
+	}
+
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// FormatColor returns the hex notation (#rrggbbaa) of the color.
+func FormatColor(c color.Color) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("#%02x%02x%02x%02x", n.R, n.G, n.B, n.A)
+}
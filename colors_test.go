@@ -0,0 +1,35 @@
+package mosaic
+
+import (
+	"github.com/stretchr/testify/assert"
+	"image/color"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	c, err := ParseColor("#ff8000")
+	assert.NoError(t, err)
+	assert.Equal(t, color.NRGBA{R: 255, G: 128, A: 255}, c)
+
+	c, err = ParseColor("f08")
+	assert.NoError(t, err)
+	assert.Equal(t, color.NRGBA{R: 255, B: 136, A: 255}, c)
+
+	c, err = ParseColor("#00000080")
+	assert.NoError(t, err)
+	assert.Equal(t, color.NRGBA{A: 128}, c)
+
+	c, err = ParseColor("Transparent")
+	assert.NoError(t, err)
+	assert.Equal(t, color.Transparent, c)
+
+	_, err = ParseColor("#12345")
+	assert.Error(t, err)
+
+	_, err = ParseColor("#gggggg")
+	assert.Error(t, err)
+}
+
+func TestFormatColor(t *testing.T) {
+	assert.Equal(t, "#ff8000ff", FormatColor(color.NRGBA{R: 255, G: 128, A: 255}))
+}
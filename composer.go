@@ -1,23 +1,74 @@
 package mosaic
 
 import (
+	"context"
+	"fmt"
 	"github.com/fogleman/gg"
 	"image"
-	"sort"
 )
 
 // A Composer creates image compositions
 type Composer interface {
-	// Compose draws the images to the drawing context.
-	Compose(dc *gg.Context, images ...image.Image) error
+	// Compose draws the images to the drawing context using the given
+	// option values.
+	Compose(dc *gg.Context, opts Options, images ...image.Image) error
 }
 
 // A ComposerFunc is a Composer which itself is a function.
-type ComposerFunc func(dc *gg.Context, images ...image.Image) error
+type ComposerFunc func(dc *gg.Context, opts Options, images ...image.Image) error
 
 // Compose calls the underlying function with the given arguments.
-func (f ComposerFunc) Compose(dc *gg.Context, images ...image.Image) error {
-	return f(dc, images...)
+func (f ComposerFunc) Compose(dc *gg.Context, opts Options, images ...image.Image) error {
+	return f(dc, opts, images...)
+}
+
+// A ContextComposer is a Composer which can be cancelled.
+type ContextComposer interface {
+	Composer
+
+	// ComposeContext is like Compose, but stops drawing and returns
+	// ctx.Err() as soon as the context is done.
+	ComposeContext(ctx context.Context, dc *gg.Context, opts Options, images ...image.Image) error
+}
+
+// A ContextComposerFunc is a ContextComposer which itself is a function.
+type ContextComposerFunc func(ctx context.Context, dc *gg.Context, opts Options, images ...image.Image) error
+
+// Compose calls the underlying function with a background context.
+func (f ContextComposerFunc) Compose(dc *gg.Context, opts Options, images ...image.Image) error {
+	return f(context.Background(), dc, opts, images...)
+}
+
+// ComposeContext calls the underlying function with the given arguments.
+func (f ContextComposerFunc) ComposeContext(ctx context.Context, dc *gg.Context, opts Options, images ...image.Image) error {
+	return f(ctx, dc, opts, images...)
+}
+
+type contextAdapter struct {
+	Composer
+}
+
+func (a contextAdapter) ComposeContext(ctx context.Context, dc *gg.Context, opts Options, images ...image.Image) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := a.Compose(dc, opts, images...); err != nil {
+		return err
+	}
+
+	return ctx.Err()
+}
+
+// WithContext returns the given composer as a ContextComposer.
+// Composers which don't support cancellation themselves are wrapped such
+// that the context is checked before and after the composition.
+func WithContext(c Composer) ContextComposer {
+	if cc, ok := c.(ContextComposer); ok {
+		return cc
+	}
+
+	return contextAdapter{c}
 }
 
 // ComposerInfo is a Composer with additional information.
@@ -32,94 +83,145 @@ type ComposerInfo struct {
 	CheckImageCount func(count int) bool
 
 	RecommendedImageCounts []int
+
+	// Options declares the options which can be passed to the composer.
+	Options []Option
 }
 
-// RecommendImageCount recommends a suitable amount of images to use
-// which is guaranteed to be less or equal to the amount provided.
-func (ci ComposerInfo) RecommendImageCount(imageCount int) int {
-	var currentBest int
-	for _, c := range ci.RecommendedImageCounts {
-		if c > currentBest && c <= imageCount {
-			currentBest = c
+// AllOptions returns the options of the composer including the
+// CommonOptions.
+func (ci ComposerInfo) AllOptions() []Option {
+	options := make([]Option, 0, len(CommonOptions)+len(ci.Options))
+	options = append(options, CommonOptions...)
+	return append(options, ci.Options...)
+}
+
+// Option returns the option with the given name.
+func (ci ComposerInfo) Option(name string) (Option, bool) {
+	for _, o := range ci.AllOptions() {
+		if o.Name == name {
+			return o, true
 		}
 	}
 
-	// found a recommended count
-	if currentBest > 0 {
-		return currentBest
-	}
+	return Option{}, false
+}
 
-	// no check provided, assume all values are possible
-	if ci.CheckImageCount == nil {
-		return imageCount
+// ValidateOptions checks the given option values against the options of
+// the composer and returns a new set of values containing the defaults for
+// all values which weren't provided.
+func (ci ComposerInfo) ValidateOptions(opts Options) (Options, error) {
+	for name := range opts {
+		if _, ok := ci.Option(name); !ok {
+			return nil, &OptionError{Name: name, Reason: fmt.Sprintf("unknown option for composer %q", ci.Id)}
+		}
 	}
 
-	// check all counts and take the first that works
-	for i := imageCount; i > 0; i-- {
-		if ci.CheckImageCount(i) {
-			return i
+	options := ci.AllOptions()
+	validated := make(Options, len(options))
+	for _, o := range options {
+		value, ok := opts[o.Name]
+		if !ok {
+			value = o.Default
+		}
+
+		value, err := o.Validate(value)
+		if err != nil {
+			return nil, err
 		}
+
+		validated[o.Name] = value
 	}
 
-	return 0
+	return validated, nil
 }
 
-var registeredComposers []ComposerInfo
+// ParseOptions parses the textual representation of option values and
+// validates them.
+func (ci ComposerInfo) ParseOptions(raw map[string]string) (Options, error) {
+	opts := make(Options, len(raw))
+	for name, s := range raw {
+		o, ok := ci.Option(name)
+		if !ok {
+			return nil, &OptionError{Name: name, Reason: fmt.Sprintf("unknown option for composer %q", ci.Id)}
+		}
+
+		value, err := o.Parse(s)
+		if err != nil {
+			return nil, err
+		}
 
-// RegisterComposer registers the given
-func RegisterComposer(comps ...ComposerInfo) error {
-	for _, c := range comps {
-		registeredComposers = append(registeredComposers, c)
+		opts[name] = value
 	}
 
-	return nil
+	return ci.ValidateOptions(opts)
 }
 
-// GetComposer returns the composer with the given id.
-func GetComposer(id string) (ComposerInfo, bool) {
-	for _, composer := range registeredComposers {
-		if composer.Id == id {
-			return composer, true
-		}
+// Compose validates the option values and passes them on to the composer.
+// Options which aren't provided use their default value, so opts may be
+// nil.
+func (ci ComposerInfo) Compose(dc *gg.Context, opts Options, images ...image.Image) error {
+	return ci.ComposeContext(context.Background(), dc, opts, images...)
+}
+
+// ComposeContext is like Compose, but stops as soon as the context is
+// done. See WithContext for composers which don't support cancellation.
+func (ci ComposerInfo) ComposeContext(ctx context.Context, dc *gg.Context, opts Options, images ...image.Image) error {
+	opts, err := ci.ValidateOptions(opts)
+	if err != nil {
+		return err
 	}
 
-	return ComposerInfo{}, false
-}
+	if err := DrawBackground(dc, opts.Str("background"), images...); err != nil {
+		return err
+	}
 
-// GetComposers returns a slice containing all composers.
-func GetComposers() []ComposerInfo {
-	return registeredComposers
+	return WithContext(ci.Composer).ComposeContext(ctx, dc, opts, images...)
 }
 
-// RecommendComposers returns a slice of composers which are suitable
-// for the given image count.
-func RecommendComposers(count int) []ComposerInfo {
-	type ComposerComp struct {
-		C             ComposerInfo
-		RecImageCount int
+// Layout validates the option values and returns the layout the composer
+// would use for a composition. ErrNoLayout is returned if the composer
+// isn't a LayoutComposer.
+func (ci ComposerInfo) Layout(width, height int, opts Options, imageCount int) (Layout, error) {
+	lc, ok := ci.Composer.(LayoutComposer)
+	if !ok {
+		return Layout{}, ErrNoLayout
 	}
 
-	composerComparisons := make([]ComposerComp, 0)
+	opts, err := ci.ValidateOptions(opts)
+	if err != nil {
+		return Layout{}, err
+	}
 
-	for _, composer := range registeredComposers {
-		recommended := composer.RecommendImageCount(count)
-		if recommended != 0 {
-			composerComparisons = append(composerComparisons, ComposerComp{
-				composer,
-				recommended,
-			})
+	return lc.Layout(width, height, opts, imageCount)
+}
+
+// RecommendImageCount recommends a suitable amount of images to use
+// which is guaranteed to be less or equal to the amount provided.
+func (ci ComposerInfo) RecommendImageCount(imageCount int) int {
+	var currentBest int
+	for _, c := range ci.RecommendedImageCounts {
+		if c > currentBest && c <= imageCount {
+			currentBest = c
 		}
+	}
 
+	// found a recommended count
+	if currentBest > 0 {
+		return currentBest
 	}
 
-	sort.Slice(composerComparisons, func(i, j int) bool {
-		return composerComparisons[i].RecImageCount < composerComparisons[j].RecImageCount
-	})
+	// no check provided, assume all values are possible
+	if ci.CheckImageCount == nil {
+		return imageCount
+	}
 
-	composers := make([]ComposerInfo, len(composerComparisons))
-	for i, cc := range composerComparisons {
-		composers[i] = cc.C
+	// check all counts and take the first that works
+	for i := imageCount; i > 0; i-- {
+		if ci.CheckImageCount(i) {
+			return i
+		}
 	}
 
-	return composers
+	return 0
 }
@@ -3,12 +3,9 @@ package mosaic
 import (
 	"errors"
 	"fmt"
-	"github.com/disintegration/imaging"
-	"github.com/fogleman/gg"
 	"github.com/gieseladev/mosaic/pkg/geom"
 	"image"
 	"math"
-	"sync"
 )
 
 var (
@@ -19,90 +16,86 @@ var (
 
 var (
 	circleCornerAngles = []float64{0, geom.HalfPi, math.Pi, 3 * geom.HalfPi}
-	circleCornerPoints = []geom.Point{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+	circleCornerPoints = []geom.Point{geom.Pt(1, 0), geom.Pt(0, 1), geom.Pt(-1, 0), geom.Pt(0, -1)}
 )
 
-func CirclesPie(dc *gg.Context, images ...image.Image) error {
-	w := dc.Width()
-	h := dc.Height()
+func CirclesPie(width, height int, opts Options, imageCount int) (Layout, error) {
+	w := float64(width)
+	h := float64(height)
 
-	var s int
-	if w > h {
-		s = h
-	} else {
-		s = w
-	}
+	s := math.Min(w, h)
+
+	angle := geom.TwoPi / float64(imageCount)
 
-	angle := geom.TwoPi / float64(len(images))
+	radius := geom.InnerSquareRadius(s)
+	centerPoint := geom.Pt(w/2, h/2)
 
-	maskDC := gg.NewContext(w, h)
-	radius := geom.InnerSquareRadius(float64(s))
-	centerPoint := geom.Pt(float64(w)/2, float64(h)/2)
+	layout := Layout{Width: width, Height: height}
 
-	for i, img := range images {
-		maskDC.Clear()
+	for i := 0; i < imageCount; i++ {
 		startAngle := float64(i) * angle
-		endAngle := startAngle + angle
-		drawSlice(maskDC, centerPoint.X, centerPoint.Y, radius, startAngle, endAngle)
-		maskDC.Fill()
-
-		_ = dc.SetMask(maskDC.AsMask())
-
-		rect := geom.RectContainingPoints(
-			centerPoint,
-			geom.PtFromPolar(radius, startAngle).Add(centerPoint),
-			geom.PtFromPolar(radius, endAngle).Add(centerPoint),
-		)
-
-		// ensure the rect covers all of the circle
-		for i, angle := range circleCornerAngles {
-			if geom.AngleStrictlyBetween(angle, startAngle, endAngle) {
-				pp := circleCornerPoints[i].Mul(radius).Add(centerPoint)
-				rect = rect.GrowToContain(pp)
-			}
+		slice := SliceShape{
+			Center: centerPoint,
+			Radius: radius,
+			Start:  startAngle,
+			End:    startAngle + angle,
 		}
 
-		img = imaging.Fill(img, int(math.Ceil(rect.Width())), int(math.Ceil(rect.Height())),
-			imaging.Center, imaging.Lanczos)
-		dc.DrawImage(img, int(rect.Min.X), int(rect.Min.Y))
+		layout.Regions = append(layout.Regions, Region{
+			Image: i,
+			Shape: slice,
+			Rect:  slice.Bounds(),
+		})
 	}
 
-	return nil
+	return layout, nil
 }
 
-func TilesPerfect(dc *gg.Context, images ...image.Image) error {
-	w := dc.Width()
-	h := dc.Height()
+func TilesPerfect(width, height int, opts Options, imageCount int) (Layout, error) {
+	if imageCount < 1 {
+		return Layout{}, ErrInvalidImageCount
+	}
+
+	tiles := newTileLayout(width, height, opts)
+	bounds := tiles.Bounds()
 
-	nH, nV := geom.FindBalancedFactors(len(images))
-	imgW := w / nH
-	imgH := h / nV
+	nH, nV := geom.FindBalancedFactors(imageCount)
+	imgW := int(bounds.Width()) / nH
+	imgH := int(bounds.Height()) / nV
 
-	for i, img := range images {
+	for i := 0; i < imageCount; i++ {
 		column := i % nH
 		row := i / nH
-		img = imaging.Fill(img, imgW, imgH, imaging.Center, imaging.Lanczos)
-		dc.DrawImage(img, column*imgW, row*imgH)
+		tiles.Add(i, tiles.Tile(column*imgW, row*imgH, imgW, imgH))
 	}
 
-	return nil
+	return tiles.Layout(), nil
 }
 
-func TilesFocused(dc *gg.Context, images ...image.Image) error {
-	if len(images) < 2 {
-		return ErrInvalidImageCount
+func TilesFocused(width, height int, opts Options, imageCount int) (Layout, error) {
+	if imageCount < 2 {
+		return Layout{}, ErrInvalidImageCount
 	}
 
-	w, h := dc.Width(), dc.Height()
+	tiles := newTileLayout(width, height, opts)
+	bounds := tiles.Bounds()
+	w, h := int(bounds.Width()), int(bounds.Height())
 
-	totalSize := geom.Pt(float64(w), float64(h))
+	totalSize := geom.Pt(bounds.Width(), bounds.Height())
 
-	evenDiff := len(images) % 2
-	evenImages := len(images) - evenDiff
-	unevenImages := len(images) - (1 - evenDiff)
+	evenDiff := imageCount % 2
+	evenImages := imageCount - evenDiff
+	unevenImages := imageCount - (1 - evenDiff)
 
-	horizontalRatio := float64(unevenImages-1) / float64(unevenImages+1)
-	verticalRatio := float64(evenImages-2) / float64(evenImages)
+	horizontalRatio := opts.Float("focus-width")
+	if horizontalRatio == 0 {
+		horizontalRatio = float64(unevenImages-1) / float64(unevenImages+1)
+	}
+
+	verticalRatio := opts.Float("focus-height")
+	if verticalRatio == 0 {
+		verticalRatio = float64(evenImages-2) / float64(evenImages)
+	}
 
 	focusSize := totalSize.Scale(geom.Pt(
 		horizontalRatio,
@@ -113,138 +106,217 @@ func TilesFocused(dc *gg.Context, images ...image.Image) error {
 	otherSize := totalSize.Sub(focusSize)
 	otherX, otherY := int(otherSize.X), int(otherSize.Y)
 
-	focusImg := imaging.Fill(images[0], focusX, focusY, imaging.Center, imaging.Lanczos)
-	dc.DrawImage(focusImg, 0, h-focusY)
-
-	trImg := imaging.Fill(images[1], otherX, otherY, imaging.Center, imaging.Lanczos)
-	dc.DrawImage(trImg, focusX, 0)
+	tiles.Add(0, tiles.Tile(0, h-focusY, focusX, focusY))
+	tiles.Add(1, tiles.Tile(focusX, 0, otherX, otherY))
 
 	i := 1
-	for imgI := 2; imgI < len(images); imgI += 2 {
-		topImg := imaging.Fill(images[imgI], otherX, otherY, imaging.Center, imaging.Lanczos)
-		dc.DrawImageAnchored(topImg, w-i*otherX, 0, 1, 0)
+	for imgI := 2; imgI < imageCount; imgI += 2 {
+		tiles.Add(imgI, tiles.Tile(w-(i+1)*otherX, 0, otherX, otherY))
 
 		rightI := imgI + 1
-		if rightI < len(images) {
-			rightImg := imaging.Fill(images[rightI], otherX, otherY, imaging.Center, imaging.Lanczos)
-			dc.DrawImage(rightImg, focusX, i*otherY)
+		if rightI < imageCount {
+			tiles.Add(rightI, tiles.Tile(focusX, i*otherY, otherX, otherY))
 		}
 
 		i++
 	}
 
-	return nil
+	return tiles.Layout(), nil
 }
 
-func TilesDiamond(dc *gg.Context, images ...image.Image) error {
-	if len(images) < 1 {
-		return ErrInvalidImageCount
+// defaultDiamondScale is the scale of the centre diamond of TilesDiamond
+// which makes all diamonds the same size.
+const defaultDiamondScale = 3 * math.Sqrt2 / (13 + math.Sqrt2)
+
+func TilesDiamond(width, height int, opts Options, imageCount int) (Layout, error) {
+	if imageCount < 1 {
+		return Layout{}, ErrInvalidImageCount
 	}
 
-	w, h := dc.Width(), dc.Height()
 	sqSize := geom.
-		RectWithSideLengths(geom.Pt(float64(w), float64(h))).
+		RectWithSideLengths(geom.Pt(float64(width), float64(height))).
 		InnerCenterSquare()
-	center := sqSize.Center()
-	diaSquare := sqSize.ScaleFromCenter(3 * math.Sqrt2 / (13 + math.Sqrt2))
+	scale := opts.Float("scale")
+	if scale <= 0 {
+		scale = defaultDiamondScale
+	}
+
+	diaSquare := sqSize.ScaleFromCenter(scale)
 
 	diaPoly := diaSquare.RotateAroundCenter(geom.QuarterPi)
 	diaBounds := diaPoly.BoundingRect()
-	diaPolySize := int(diaBounds.Width())
 
-	img := imaging.Fill(images[0], diaPolySize, diaPolySize, imaging.Center, imaging.Lanczos)
+	layout := Layout{Width: width, Height: height}
 
-	maskDC := gg.NewContext(w, h)
-	drawPolygon(maskDC, diaPoly)
-	maskDC.Fill()
-	_ = dc.SetMask(maskDC.AsMask())
-	dc.DrawImageAnchored(img, w/2, h/2, .5, .5)
+	addDiamond := func(imageIndex int, poly geom.Polygon) {
+		bounds := poly.BoundingRect()
+		center := bounds.Center()
+
+		// images are centred on the diamond with their size truncated
+		w, h := int(bounds.Width()), int(bounds.Height())
+		x, y := int(center.X)-w/2, int(center.Y)-h/2
 
-	if len(images) < 5 {
-		return nil
+		layout.Regions = append(layout.Regions, Region{
+			Image: imageIndex,
+			Shape: PolygonShape{poly},
+			Rect:  rectFromPixels(image.Rect(x, y, x+w, y+h)),
+		})
 	}
 
-	var mut sync.Mutex
-	var wg sync.WaitGroup
-	defer wg.Wait()
+	addRing := func(firstImage int, poly geom.Polygon, radius float64, startAngle float64) {
+		for i := 0; i < 4; i++ {
+			translation := geom.PtFromPolar(radius, startAngle+float64(i)*geom.HalfPi)
+			addDiamond(firstImage+i, poly.Translate(translation))
+		}
+	}
 
-	drawImages := func(images []image.Image, poly geom.Polygon, radius float64, startAngle float64) {
-		maskDC := gg.NewContext(w, h)
+	addDiamond(0, diaPoly)
 
-		bounds := poly.BoundingRect()
-		polyWidth := int(bounds.Width())
-		polyHeight := int(bounds.Height())
+	if imageCount < 5 {
+		return layout, nil
+	}
 
-		for i, img := range images {
-			translation := geom.PtFromPolar(radius, startAngle+float64(i)*geom.HalfPi)
-			pos := translation.Add(center)
+	addRing(1, diaPoly, diaSquare.Width(), geom.QuarterPi)
 
-			// no need to clear mask because images are far enough apart
-			drawPolygon(maskDC, poly.Translate(translation))
-			maskDC.Fill()
+	if imageCount < 9 {
+		return layout, nil
+	}
 
-			img = imaging.Fill(img, polyWidth, polyHeight, imaging.Center, imaging.Lanczos)
+	smallDiaPoly := diaPoly.ScaleFromCenter(2. / 3)
+	smallDiaBounds := smallDiaPoly.BoundingRect()
 
-			mut.Lock()
-			_ = dc.SetMask(maskDC.AsMask())
-			dc.DrawImageAnchored(img, int(pos.X), int(pos.Y), .5, .5)
-			mut.Unlock()
-		}
+	addRing(5, smallDiaPoly, (diaBounds.Width()+smallDiaBounds.Width())/2, 0)
 
-		wg.Done()
+	if imageCount < 13 {
+		return layout, nil
 	}
 
-	wg.Add(1)
-	go drawImages(images[1:5], diaPoly, diaSquare.Width(), geom.QuarterPi)
+	addRing(9, smallDiaPoly, diaSquare.Width()*11/6, geom.QuarterPi)
+
+	return layout, nil
+}
+
+// hexDirections are the axial coordinates of the neighbours of a hexagon.
+var hexDirections = [6][2]int{{1, 0}, {1, -1}, {0, -1}, {-1, 0}, {-1, 1}, {0, 1}}
+
+// hexSpiral returns the axial coordinates of n hexagons arranged in rings
+// around the centre hexagon.
+func hexSpiral(n int) [][2]int {
+	cells := [][2]int{{0, 0}}
 
-	if len(images) < 9 {
-		return nil
+	for ring := 1; len(cells) < n; ring++ {
+		q, r := hexDirections[4][0]*ring, hexDirections[4][1]*ring
+
+		for _, dir := range hexDirections {
+			for step := 0; step < ring; step++ {
+				cells = append(cells, [2]int{q, r})
+				q, r = q+dir[0], r+dir[1]
+			}
+		}
 	}
 
-	smallDiaPoly := diaPoly.ScaleFromCenter(2. / 3)
-	smallDiaBounds := smallDiaPoly.BoundingRect()
+	return cells[:n]
+}
 
-	wg.Add(1)
-	go drawImages(images[5:9], smallDiaPoly, (diaBounds.Width()+smallDiaBounds.Width())/2, 0)
+// hexagon returns the hexagon with a circumradius of 1 at the given axial
+// coordinates.
+func hexagon(cell [2]int, flat bool) geom.Polygon {
+	q, r := float64(cell[0]), float64(cell[1])
 
-	if len(images) < 13 {
-		return nil
+	if flat {
+		center := geom.Pt(1.5*q, math.Sqrt(3)*(r+q/2))
+		return geom.RegularPoly(center, 1, 6, 0)
 	}
 
-	wg.Add(1)
-	go drawImages(images[9:13], smallDiaPoly, diaSquare.Width()*11/6, geom.QuarterPi)
+	center := geom.Pt(math.Sqrt(3)*(q+r/2), 1.5*r)
+	return geom.RegularPoly(center, 1, 6, math.Pi/6)
+}
+
+func checkHexOrientation(value interface{}) error {
+	o, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("expected orientation string, got %T", value)
+	}
+
+	if o != "pointy" && o != "flat" {
+		return fmt.Errorf("unknown orientation %q, expected pointy or flat", o)
+	}
 
 	return nil
 }
 
-func StripesVertical(dc *gg.Context, images ...image.Image) error {
-	w := dc.Width()
-	h := dc.Height()
-	stripeWidth := float64(w) / float64(len(images))
+func TilesHexagon(width, height int, opts Options, imageCount int) (Layout, error) {
+	if imageCount < 1 {
+		return Layout{}, ErrInvalidImageCount
+	}
 
-	maskDC := gg.NewContext(w, h)
+	flat := opts.Str("orientation") == "flat"
 
-	for i, img := range images {
-		img = imaging.Fill(img, w, h, imaging.Center, imaging.Lanczos)
+	cells := hexSpiral(imageCount)
+	hexagons := make([]geom.Polygon, len(cells))
+	for i, cell := range cells {
+		hexagons[i] = hexagon(cell, flat)
+	}
 
-		iF64 := float64(i)
-		maskDC.Clear()
-		maskDC.DrawRectangle(iF64*stripeWidth, 0, stripeWidth, float64(h))
-		maskDC.Fill()
+	bounds := hexagons[0].BoundingRect()
+	for _, hex := range hexagons[1:] {
+		bounds = bounds.GrowToContain(hex.Vertices...)
+	}
+
+	tiles := newTileLayout(width, height, opts)
 
-		_ = dc.SetMask(maskDC.AsMask())
+	// centre the honeycomb and scale it to fill the tile bounds
+	canvas := tiles.Bounds()
+	scale := math.Min(canvas.Width()/bounds.Width(), canvas.Height()/bounds.Height())
+	offset := canvas.Center().Sub(bounds.Center().Mul(scale))
 
-		dc.DrawImage(img, 0, 0)
+	for i, hex := range hexagons {
+		tiles.AddPolygon(i, hex.Scale(scale).Translate(offset))
 	}
 
-	return nil
+	return tiles.Layout(), nil
 }
 
-func StripesVerticalMulti(dc *gg.Context, images ...image.Image) error {
-	imgCountF := float64(len(images))
+func StripesVertical(width, height int, opts Options, imageCount int) (Layout, error) {
+	w := float64(width)
+	h := float64(height)
+	stripeWidth := w / float64(imageCount)
+
+	canvas := geom.RectWithSideLengths(geom.Pt(w, h))
+	layout := Layout{Width: width, Height: height}
 
-	stripeCountF := math.Ceil(math.Sqrt(imgCountF))
-	stripeCount := int(stripeCountF)
+	for i := 0; i < imageCount; i++ {
+		iF64 := float64(i)
+		stripe := geom.Rect(iF64*stripeWidth, 0, (iF64+1)*stripeWidth, h)
+
+		layout.Regions = append(layout.Regions, Region{
+			Image: i,
+			Shape: RectShape{Rect: stripe},
+			Rect:  canvas,
+		})
+	}
+
+	return layout, nil
+}
+
+func StripesVerticalMulti(width, height int, opts Options, imageCount int) (Layout, error) {
+	if imageCount < 1 {
+		return Layout{}, ErrInvalidImageCount
+	}
+
+	tiles := newTileLayout(width, height, opts)
+	bounds := tiles.Bounds()
+
+	imgCountF := float64(imageCount)
+
+	stripeCount := opts.Int("stripes")
+	if stripeCount == 0 {
+		stripeCount = int(math.Ceil(math.Sqrt(imgCountF)))
+	} else if stripeCount > imageCount {
+		stripeCount = imageCount
+	}
+
+	stripeCountF := float64(stripeCount)
 
 	completeLevelsCountF := math.Floor(imgCountF / stripeCountF)
 	completeLevelsCount := int(completeLevelsCountF)
@@ -273,7 +345,7 @@ func StripesVerticalMulti(dc *gg.Context, images ...image.Image) error {
 		}
 	}
 
-	stripeWidthF := float64(dc.Width()) / stripeCountF
+	stripeWidthF := bounds.Width() / stripeCountF
 	stripeWidth := int(stripeWidthF)
 
 	var imgI int
@@ -281,22 +353,21 @@ func StripesVerticalMulti(dc *gg.Context, images ...image.Image) error {
 		var yOffset int
 
 		for i := 0; i < stripeImgCount; i++ {
-			imgHeight := dc.Height() / stripeImgCount
-			img := imaging.Fill(images[imgI], stripeWidth, imgHeight, imaging.Center, imaging.Lanczos)
+			imgHeight := int(bounds.Height()) / stripeImgCount
+			tiles.Add(imgI, tiles.Tile(int(float64(stripeI)*stripeWidthF), yOffset, stripeWidth, imgHeight))
 			imgI++
 
-			dc.DrawImage(img, int(float64(stripeI)*stripeWidthF), yOffset)
 			yOffset += imgHeight
 		}
 	}
 
-	return nil
+	return tiles.Layout(), nil
 }
 
 func init() {
 	err := RegisterComposer(
 		ComposerInfo{
-			Composer: ComposerFunc(CirclesPie),
+			Composer: LayoutFunc(CirclesPie),
 			Id:       "circles-pie",
 			Name:     "Pie (Circle)",
 
@@ -304,14 +375,16 @@ func init() {
 		},
 
 		ComposerInfo{
-			Composer: ComposerFunc(TilesPerfect),
+			Composer: LayoutFunc(TilesPerfect),
 			Id:       "tiles-perfect",
 			Name:     "Perfect (Tile)",
 
 			RecommendedImageCounts: []int{4, 6, 9, 12, 16},
+
+			Options: TileOptions,
 		},
 		ComposerInfo{
-			Composer: ComposerFunc(TilesFocused),
+			Composer: LayoutFunc(TilesFocused),
 			Id:       "tiles-focused",
 			Name:     "Focused (Tile)",
 
@@ -321,17 +394,70 @@ func init() {
 			},
 
 			RecommendedImageCounts: []int{4, 5, 6, 7, 8, 9},
+
+			Options: withTileOptions(
+				Option{
+					Name:        "focus-width",
+					Description: "share of the width covered by the focused image, 0 derives it from the image count",
+					Type:        OptionFloat,
+					Default:     0.,
+					Min:         0,
+					Max:         .95,
+				},
+				Option{
+					Name:        "focus-height",
+					Description: "share of the height covered by the focused image, 0 derives it from the image count",
+					Type:        OptionFloat,
+					Default:     0.,
+					Min:         0,
+					Max:         .95,
+				},
+			),
 		},
 		ComposerInfo{
-			Composer: ComposerFunc(TilesDiamond),
+			Composer: LayoutFunc(TilesDiamond),
 			Id:       "tiles-diamond",
 			Name:     "Diamond (Tile)",
 
 			RecommendedImageCounts: []int{5, 9, 13},
+
+			Options: []Option{
+				{
+					Name:        "scale",
+					Description: "size of the centre diamond relative to the canvas",
+					Type:        OptionFloat,
+					Default:     defaultDiamondScale,
+					Min:         .05,
+					Max:         1,
+				},
+			},
 		},
 
 		ComposerInfo{
-			Composer: ComposerFunc(StripesVertical),
+			Composer: LayoutFunc(TilesHexagon),
+			Id:       "tiles-hexagon",
+			Name:     "Hexagon (Tile)",
+
+			ImageCountHuman: "any, optimally a complete honeycomb",
+			CheckImageCount: func(count int) bool {
+				return count >= 1
+			},
+
+			RecommendedImageCounts: []int{1, 7, 19},
+
+			Options: withTileOptions(
+				Option{
+					Name:        "orientation",
+					Description: "orientation of the hexagons, either pointy or flat topped",
+					Type:        OptionString,
+					Default:     "pointy",
+					Check:       checkHexOrientation,
+				},
+			),
+		},
+
+		ComposerInfo{
+			Composer: LayoutFunc(StripesVertical),
 			Id:       "stripes-vertical",
 			Name:     "Vertical (Stripes)",
 
@@ -339,11 +465,22 @@ func init() {
 		},
 
 		ComposerInfo{
-			Composer: ComposerFunc(StripesVerticalMulti),
+			Composer: LayoutFunc(StripesVerticalMulti),
 			Id:       "stripes-vertical-multi",
 			Name:     "Vertical Multi (Stripes)",
 
 			RecommendedImageCounts: []int{3, 5, 7},
+
+			Options: withTileOptions(
+				Option{
+					Name:        "stripes",
+					Description: "number of stripes, 0 derives it from the image count",
+					Type:        OptionInt,
+					Default:     0,
+					Min:         0,
+					Max:         64,
+				},
+			),
 		},
 	)
 
@@ -1,10 +1,13 @@
 package mosaic
 
 import (
+	"context"
 	"fmt"
+	"github.com/disintegration/imaging"
 	"github.com/fogleman/gg"
 	"github.com/stretchr/testify/assert"
 	"image"
+	"image/color"
 	"image/png"
 	"io"
 	"testing"
@@ -73,6 +76,7 @@ type ComposerTest struct {
 	testName   string
 
 	InputImageNames []string
+	Options         Options
 	contextWidth    int
 	contextHeight   int
 
@@ -165,7 +169,7 @@ func (c *ComposerTest) Test(t *testing.T) (ok bool) {
 	}
 
 	dc := gg.NewContext(c.ContextWidth(), c.ContextHeight())
-	err := composer.Compose(dc, images...)
+	err := composer.Compose(dc, c.Options, images...)
 	ok = assert.NoError(t, err, "composer returned error")
 	if !ok {
 		return
@@ -206,7 +210,7 @@ func (c *ComposerTest) Benchmark(b *testing.B) {
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		dc.Clear()
-		_ = composer.Compose(dc, images...)
+		_ = composer.Compose(dc, c.Options, images...)
 	}
 }
 
@@ -286,6 +290,87 @@ var composerTests = []*ComposerTest{
 			"t-mikuckis-hbnH0ILjUZE.jpg",
 		},
 	},
+	{
+		ComposerID: "tiles-hexagon",
+		InputImageNames: []string{
+			"j-han-456323.jpg",
+		},
+	},
+	{
+		ComposerID: "tiles-hexagon",
+		InputImageNames: []string{
+			"b-martinez-744134.jpg",
+			"i-palacio-Y20JJ_ddy9M.jpg",
+			"j-crop-764891.jpg",
+			"j-han-456323.jpg",
+			"j-pereira-fSGsKbICefw.jpg",
+			"j-wejxKZ-9IZg.jpg",
+			"m-spiske-78531.jpg",
+		},
+	},
+	{
+		ComposerID: "tiles-hexagon",
+		testName:   "tiles-hexagon-7-50x50-gutter",
+		InputImageNames: []string{
+			"b-martinez-744134.jpg",
+			"i-palacio-Y20JJ_ddy9M.jpg",
+			"j-crop-764891.jpg",
+			"j-han-456323.jpg",
+			"j-pereira-fSGsKbICefw.jpg",
+			"j-wejxKZ-9IZg.jpg",
+			"m-spiske-78531.jpg",
+		},
+		Options: Options{
+			"gutter":       4,
+			"gutter-color": "#ffffff",
+			"border":       2,
+		},
+	},
+	{
+		ComposerID: "tiles-hexagon",
+		testName:   "tiles-hexagon-7-100x100-flat-radius",
+		InputImageNames: []string{
+			"b-martinez-744134.jpg",
+			"i-palacio-Y20JJ_ddy9M.jpg",
+			"j-crop-764891.jpg",
+			"j-han-456323.jpg",
+			"j-pereira-fSGsKbICefw.jpg",
+			"j-wejxKZ-9IZg.jpg",
+			"m-spiske-78531.jpg",
+		},
+		contextWidth:  100,
+		contextHeight: 100,
+		Options: Options{
+			"orientation":  "flat",
+			"gutter":       3,
+			"gutter-color": "#000000",
+			"radius":       6,
+		},
+	},
+	{
+		ComposerID: "tiles-hexagon",
+		InputImageNames: []string{
+			"b-martinez-744134.jpg",
+			"i-palacio-Y20JJ_ddy9M.jpg",
+			"j-crop-764891.jpg",
+			"j-han-456323.jpg",
+			"j-pereira-fSGsKbICefw.jpg",
+			"j-wejxKZ-9IZg.jpg",
+			"m-spiske-78531.jpg",
+			"m-wingen-PDX_a_82obo.jpg",
+			"n-perea-W8BRzoUTHNA.jpg",
+			"p-wooten-FMiczIq8orU.jpg",
+			"s-erixon-753182.jpg",
+			"s-imbrock-487035.jpg",
+			"t-mikuckis-hbnH0ILjUZE.jpg",
+			"b-martinez-744134.jpg",
+			"i-palacio-Y20JJ_ddy9M.jpg",
+			"j-crop-764891.jpg",
+			"j-han-456323.jpg",
+			"j-pereira-fSGsKbICefw.jpg",
+			"j-wejxKZ-9IZg.jpg",
+		},
+	},
 	{
 		ComposerID: "stripes-vertical",
 		InputImageNames: []string{
@@ -337,6 +422,97 @@ func TestComposers(t *testing.T) {
 	}
 }
 
+func TestComposersCancelled(t *testing.T) {
+	images, ok := loadInputImages(t, composerTests[len(composerTests)-1].InputImageNames...)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, composer := range GetComposers() {
+		dc := gg.NewContext(50, 50)
+		imgCount := composer.RecommendImageCount(len(images))
+		err := composer.ComposeContext(ctx, dc, nil, images[:imgCount]...)
+		assert.Equal(t, context.Canceled, err, composer.Id)
+	}
+}
+
+// cancellingImage cancels the context once its bounds are requested,
+// which happens when the composer starts to draw it.
+type cancellingImage struct {
+	image.Image
+	cancel context.CancelFunc
+}
+
+func (img cancellingImage) Bounds() image.Rectangle {
+	img.cancel()
+	return img.Image.Bounds()
+}
+
+func TestComposersCancelledWhileDrawing(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	images := make([]image.Image, 4)
+	for i := range images {
+		images[i] = imaging.New(10, 10, color.White)
+	}
+	images[0] = cancellingImage{Image: images[0], cancel: cancel}
+
+	composer, _ := GetComposer("tiles-perfect")
+	dc := gg.NewContext(100, 100)
+	err := composer.ComposeContext(ctx, dc, nil, images...)
+	assert.Equal(t, context.Canceled, err)
+
+	var drawn int
+	for _, p := range []image.Point{{25, 25}, {75, 25}, {25, 75}, {75, 75}} {
+		if _, _, _, a := dc.Image().At(p.X, p.Y).RGBA(); a > 0 {
+			drawn++
+		}
+	}
+
+	assert.True(t, drawn < len(images), "%d of %d tiles drawn", drawn, len(images))
+}
+
+func TestWithContext(t *testing.T) {
+	var called bool
+	composer := WithContext(ComposerFunc(func(dc *gg.Context, opts Options, images ...image.Image) error {
+		called = true
+		return nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := composer.ComposeContext(ctx, gg.NewContext(1, 1), nil)
+	assert.Equal(t, context.Canceled, err)
+	assert.False(t, called)
+
+	err = composer.ComposeContext(context.Background(), gg.NewContext(1, 1), nil)
+	assert.NoError(t, err)
+	assert.True(t, called)
+}
+
+func TestTilesDiamond_DefaultScale(t *testing.T) {
+	expected, err := TilesDiamond(50, 50, Options{"scale": defaultDiamondScale}, 13)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	actual, err := TilesDiamond(50, 50, nil, 13)
+	if assert.NoError(t, err) {
+		assert.Equal(t, expected, actual)
+	}
+}
+
+func TestCheckHexOrientation(t *testing.T) {
+	assert.NoError(t, checkHexOrientation("flat"))
+	assert.Error(t, checkHexOrientation("round"))
+	assert.Error(t, checkHexOrientation(true))
+}
+
 func BenchmarkComposers(b *testing.B) {
 	for _, c := range composerTests {
 		b.Run(c.TestName(), func(b *testing.B) {
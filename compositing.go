@@ -0,0 +1,202 @@
+package mosaic
+
+import (
+	"context"
+	"fmt"
+	"github.com/fogleman/gg"
+	"image"
+)
+
+// Compositing modes which can be selected using the "compositing" option.
+const (
+	// CompositingBlend draws every region through its own anti-aliased
+	// mask. Edges shared by adjacent regions are blended twice, which lets
+	// the background shine through as a faint hairline.
+	CompositingBlend = "blend"
+	// CompositingCoverage distributes the coverage of every pixel among the
+	// regions, starting with the top most one, so shared edges are drawn
+	// exactly once. Transparent parts of images show the background
+	// instead of the regions below.
+	CompositingCoverage = "coverage"
+)
+
+// CompositingModes contains the names of all compositing modes.
+var CompositingModes = []string{CompositingBlend, CompositingCoverage}
+
+// seamTolerance is the coverage pixels along seams may lack because the
+// masks of adjacent regions are rasterized separately.
+const seamTolerance = 8
+
+// sharedPixel marks the pixels drawn by multiple regions in the owners
+// passed to closeSeams. Other pixels hold the index of their region plus
+// one, or 0 if no region is drawn to them.
+const sharedPixel = -1
+
+func checkCompositing(value interface{}) error {
+	for _, name := range CompositingModes {
+		if value == name {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown compositing mode %q", value)
+}
+
+// renderCoverage draws the fitted images of the regions using
+// CompositingCoverage. The images are accumulated in a separate layer
+// which is drawn on top of the canvas once all regions are done.
+func renderCoverage(ctx context.Context, dc *gg.Context, regions []Region, fitted []image.Image) error {
+	bounds := image.Rect(0, 0, dc.Width(), dc.Height())
+	layer := image.NewRGBA64(bounds)
+	covered := image.NewAlpha(bounds)
+	owners := make([]int32, len(covered.Pix))
+
+	var maskDC *gg.Context
+
+	for i := len(regions) - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		img := fitted[i]
+		if img == nil {
+			continue
+		}
+
+		region := regions[i]
+		rect := pixelRect(region.Rect)
+		offset := img.Bounds().Min.Sub(rect.Min)
+		pixel := premultiplied(img)
+
+		var mask *image.Alpha
+		if region.needsMask() {
+			if maskDC == nil {
+				maskDC = gg.NewContext(dc.Width(), dc.Height())
+			}
+
+			maskDC.Clear()
+			region.Shape.Path(maskDC)
+			maskDC.Fill()
+			mask = maskDC.AsMask()
+		}
+
+		area := rect.Intersect(bounds)
+		for y := area.Min.Y; y < area.Max.Y; y++ {
+			for x := area.Min.X; x < area.Max.X; x++ {
+				coverage := uint32(0xff)
+				if mask != nil {
+					coverage = uint32(mask.Pix[mask.PixOffset(x, y)])
+				}
+
+				ci := covered.PixOffset(x, y)
+				if remaining := 0xff - uint32(covered.Pix[ci]); coverage > remaining {
+					coverage = remaining
+				}
+
+				if coverage == 0 {
+					continue
+				}
+
+				covered.Pix[ci] += uint8(coverage)
+				if owners[ci] == 0 {
+					owners[ci] = int32(i) + 1
+				} else {
+					owners[ci] = sharedPixel
+				}
+
+				r, g, b, a := pixel(x+offset.X, y+offset.Y)
+				li := layer.PixOffset(x, y)
+				for c, v := range [4]uint32{r, g, b, a} {
+					addUint16(layer.Pix[li+2*c:], v*coverage/0xff)
+				}
+			}
+		}
+	}
+
+	closeSeams(layer, covered, owners)
+
+	dc.ResetClip()
+	dc.DrawImage(layer, 0, 0)
+	return nil
+}
+
+// premultiplied returns a function returning the alpha-premultiplied
+// color of the pixels of the image like color.Color.RGBA. The pixels of
+// the image types used for fitted images are read directly.
+func premultiplied(img image.Image) func(x, y int) (r, g, b, a uint32) {
+	switch img := img.(type) {
+	case *image.NRGBA:
+		return func(x, y int) (r, g, b, a uint32) {
+			s := img.Pix[img.PixOffset(x, y):]
+			a = uint32(s[3]) * 0x101
+			r = uint32(s[0]) * 0x101 * a / 0xffff
+			g = uint32(s[1]) * 0x101 * a / 0xffff
+			b = uint32(s[2]) * 0x101 * a / 0xffff
+			return
+		}
+	case *image.RGBA:
+		return func(x, y int) (r, g, b, a uint32) {
+			s := img.Pix[img.PixOffset(x, y):]
+			return uint32(s[0]) * 0x101, uint32(s[1]) * 0x101, uint32(s[2]) * 0x101, uint32(s[3]) * 0x101
+		}
+	}
+
+	return func(x, y int) (r, g, b, a uint32) {
+		return img.At(x, y).RGBA()
+	}
+}
+
+// onSeam checks whether the pixel is shared by multiple regions or next
+// to a pixel of another region.
+func onSeam(covered *image.Alpha, owners []int32, x, y int) bool {
+	owner := owners[covered.PixOffset(x, y)]
+	if owner == sharedPixel {
+		return true
+	}
+
+	for _, n := range [4]image.Point{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}} {
+		if !n.In(covered.Rect) {
+			continue
+		}
+
+		if o := owners[covered.PixOffset(n.X, n.Y)]; o != 0 && o != owner {
+			return true
+		}
+	}
+
+	return false
+}
+
+// addUint16 adds the value to the big endian uint16 at the start of b.
+func addUint16(b []byte, v uint32) {
+	v += uint32(b[0])<<8 | uint32(b[1])
+	if v > 0xffff {
+		v = 0xffff
+	}
+
+	b[0], b[1] = uint8(v>>8), uint8(v)
+}
+
+// closeSeams scales up the pixels along the seams between regions which
+// are only missing the coverage lost to rasterizing the masks, see
+// seamTolerance. The owners are the regions drawn to the pixels, see
+// sharedPixel. Other pixels keep their anti-aliasing.
+func closeSeams(layer *image.RGBA64, covered *image.Alpha, owners []int32) {
+	b := covered.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			ci := covered.PixOffset(x, y)
+			c := uint32(covered.Pix[ci])
+			if c == 0xff || c < 0xff-seamTolerance || !onSeam(covered, owners, x, y) {
+				continue
+			}
+
+			li := layer.PixOffset(x, y)
+			for i := 0; i < 8; i += 2 {
+				v := uint32(layer.Pix[li+i])<<8 | uint32(layer.Pix[li+i+1])
+				layer.Pix[li+i], layer.Pix[li+i+1] = 0, 0
+				addUint16(layer.Pix[li+i:], v*0xff/c)
+			}
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package mosaic
+
+import (
+	"context"
+	"github.com/fogleman/gg"
+	"github.com/gieseladev/mosaic/pkg/geom"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// leakingPixels composes the images on a blue canvas and returns the
+// amount of pixels inside the area showing some of the background.
+func leakingPixels(t *testing.T, id string, compositing string, width, height int, inside func(x, y int) bool) int {
+	return countPixels(t, id, compositing, width, height, inside, func(img *image.RGBA, x, y int) bool {
+		return img.RGBAAt(x, y).B > 0
+	})
+}
+
+// countPixels composes the images on a blue canvas and returns the amount
+// of pixels inside the area matching the predicate.
+func countPixels(t *testing.T, id string, compositing string, width, height int, inside func(x, y int) bool, match func(img *image.RGBA, x, y int) bool) int {
+	composer, ok := GetComposer(id)
+	if !assert.True(t, ok) {
+		return 0
+	}
+
+	images := []image.Image{
+		uniformImage(color.NRGBA{R: 0xff, A: 0xff}),
+		uniformImage(color.NRGBA{G: 0xff, A: 0xff}),
+		uniformImage(color.NRGBA{R: 0xff, G: 0xff, A: 0xff}),
+	}
+
+	dc := gg.NewContext(width, height)
+	dc.SetColor(color.NRGBA{B: 0xff, A: 0xff})
+	dc.Clear()
+
+	if !assert.NoError(t, composer.Compose(dc, Options{"compositing": compositing}, images...)) {
+		return 0
+	}
+
+	var count int
+	img := dc.Image().(*image.RGBA)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if inside(x, y) && match(img, x, y) {
+				count++
+			}
+		}
+	}
+
+	return count
+}
+
+func TestRenderCoverage_Seams(t *testing.T) {
+	everywhere := func(x, y int) bool { return true }
+
+	// the stripes are a third of the width wide
+	assert.NotZero(t, leakingPixels(t, "stripes-vertical", CompositingBlend, 100, 20, everywhere))
+	assert.Zero(t, leakingPixels(t, "stripes-vertical", CompositingCoverage, 100, 20, everywhere))
+
+	radius := geom.InnerSquareRadius(64)
+	inCircle := func(x, y int) bool {
+		d, _ := geom.Pt(float64(x)+.5, float64(y)+.5).Sub(geom.Pt(32, 32)).Polar()
+		return d < radius-1
+	}
+
+	assert.NotZero(t, leakingPixels(t, "circles-pie", CompositingBlend, 64, 64, inCircle))
+	assert.Zero(t, leakingPixels(t, "circles-pie", CompositingCoverage, 64, 64, inCircle))
+}
+
+func TestRenderCoverage_AntiAliasing(t *testing.T) {
+	radius := geom.InnerSquareRadius(64)
+	outerEdge := func(x, y int) bool {
+		d, _ := geom.Pt(float64(x)+.5, float64(y)+.5).Sub(geom.Pt(32, 32)).Polar()
+		return d > radius-1 && d < radius+1
+	}
+
+	// the edge of the circle isn't a seam, so even the pixels which are
+	// almost covered keep showing some of the background
+	almostCovered := func(img *image.RGBA, x, y int) bool {
+		b := img.RGBAAt(x, y).B
+		return b > 0 && b <= seamTolerance
+	}
+
+	assert.NotZero(t, countPixels(t, "circles-pie", CompositingCoverage, 64, 64, outerEdge, almostCovered))
+}
+
+func TestRenderCoverage_Order(t *testing.T) {
+	full := geom.Rect(0, 0, 10, 10)
+	layout := Layout{
+		Width: 10, Height: 10,
+		Regions: []Region{
+			{Image: 0, Shape: RectShape{Rect: full}, Rect: full},
+			{Image: 1, Shape: CircleShape{Center: geom.Pt(5, 5), Radius: 3}, Rect: full},
+		},
+	}
+
+	images := []image.Image{uniformImage(color.Black), uniformImage(color.White)}
+
+	dc := gg.NewContext(10, 10)
+	if assert.NoError(t, Render(context.Background(), dc, layout, Options{"compositing": CompositingCoverage}, images...)) {
+		img := dc.Image().(*image.RGBA)
+		// later regions are drawn on top
+		assert.Equal(t, color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, img.RGBAAt(5, 5))
+		assert.Equal(t, color.RGBA{A: 0xff}, img.RGBAAt(0, 0))
+	}
+}
+
+func TestPremultiplied(t *testing.T) {
+	nrgba := image.NewNRGBA(image.Rect(2, 3, 6, 5))
+	rgba := image.NewRGBA(nrgba.Bounds())
+	for i := range nrgba.Pix {
+		nrgba.Pix[i] = uint8(37 * i)
+		rgba.Pix[i] = uint8(37 * i)
+	}
+
+	for _, img := range []image.Image{nrgba, rgba, image.NewGray(nrgba.Bounds())} {
+		pixel := premultiplied(img)
+		for y := 3; y < 5; y++ {
+			for x := 2; x < 6; x++ {
+				r, g, b, a := img.At(x, y).RGBA()
+				pr, pg, pb, pa := pixel(x, y)
+				assert.Equal(t, [4]uint32{r, g, b, a}, [4]uint32{pr, pg, pb, pa})
+			}
+		}
+	}
+}
+
+func TestRenderCoverage_Unaligned(t *testing.T) {
+	// none of the edges are aligned to the pixel grid
+	left := geom.Rect(0, 0, 10.3, 20)
+	right := geom.Rect(10.3, 0, 20, 20)
+	top := geom.Poly(geom.Pt(0, 0), geom.Pt(20, 0), geom.Pt(0, 13.7))
+	bottom := geom.Poly(geom.Pt(20, 0), geom.Pt(20, 20), geom.Pt(0, 20), geom.Pt(0, 13.7))
+	canvas := geom.Rect(0, 0, 20, 20)
+
+	layouts := map[string][]Region{
+		"rects": {
+			{Image: 0, Shape: RectShape{Rect: left}, Rect: left},
+			{Image: 1, Shape: RectShape{Rect: right}, Rect: right},
+		},
+		"polygons": {
+			{Image: 0, Shape: PolygonShape{top}, Rect: canvas},
+			{Image: 1, Shape: PolygonShape{bottom}, Rect: canvas},
+		},
+	}
+
+	images := []image.Image{
+		uniformImage(color.NRGBA{R: 0xff, A: 0xff}),
+		uniformImage(color.NRGBA{G: 0xff, A: 0xff}),
+	}
+
+	for name, regions := range layouts {
+		dc := gg.NewContext(20, 20)
+		dc.SetColor(color.NRGBA{B: 0xff, A: 0xff})
+		dc.Clear()
+
+		layout := Layout{Width: 20, Height: 20, Regions: regions}
+		if !assert.NoError(t, Render(context.Background(), dc, layout, Options{"compositing": CompositingCoverage}, images...)) {
+			continue
+		}
+
+		img := dc.Image().(*image.RGBA)
+		for y := 0; y < 20; y++ {
+			for x := 0; x < 20; x++ {
+				c := img.RGBAAt(x, y)
+				// the background doesn't leak through the seam and the
+				// regions sharing a pixel don't add up to more than it
+				assert.Zero(t, c.B, "%s: leak at %d,%d", name, x, y)
+				assert.InDelta(t, 0xff, int(c.R)+int(c.G), 2, "%s: double draw at %d,%d", name, x, y)
+			}
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package mosaic
+
+import (
+	"fmt"
+	"github.com/disintegration/imaging"
+	"github.com/gieseladev/mosaic/pkg/geom"
+	"image"
+	"math"
+	"sort"
+	"sync"
+)
+
+// A Cropper decides which part of an image is kept when the image is
+// resized to fill an area with a different aspect ratio.
+// Croppers must be safe for concurrent use.
+type Cropper interface {
+	// Crop returns the rectangle inside of the image bounds which should
+	// be scaled to the given size.
+	Crop(img image.Image, width, height int) image.Rectangle
+}
+
+// A CropperFunc is a Cropper which itself is a function.
+type CropperFunc func(img image.Image, width, height int) image.Rectangle
+
+// Crop calls the underlying function with the given arguments.
+func (f CropperFunc) Crop(img image.Image, width, height int) image.Rectangle {
+	return f(img, width, height)
+}
+
+// cropSize returns the size of the largest rectangle fitting inside of the
+// bounds which has the aspect ratio of width / height.
+func cropSize(bounds image.Rectangle, width, height int) (int, int) {
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+
+	if float64(srcW)/float64(srcH) < float64(width)/float64(height) {
+		cropH := float64(srcW) * float64(height) / float64(width)
+		return srcW, int(math.Max(1, cropH) + .5)
+	}
+
+	cropW := float64(srcH) * float64(width) / float64(height)
+	return int(math.Max(1, cropW) + .5), srcH
+}
+
+// AnchorCropper returns a cropper which always keeps the same part of the
+// image. The anchor is relative to the image size, i.e. (.5, .5) keeps the
+// center and (0, 0) the top left corner.
+func AnchorCropper(anchor geom.Point) Cropper {
+	return CropperFunc(func(img image.Image, width, height int) image.Rectangle {
+		b := img.Bounds()
+		cropW, cropH := cropSize(b, width, height)
+
+		min := b.Min.Add(image.Pt(
+			int(float64(b.Dx()-cropW)*anchor.X),
+			int(float64(b.Dy()-cropH)*anchor.Y),
+		))
+
+		return image.Rectangle{Min: min, Max: min.Add(image.Pt(cropW, cropH))}
+	})
+}
+
+// CenterCropper is the default cropper which keeps the center of images.
+var CenterCropper = AnchorCropper(geom.Pt(.5, .5))
+
+// fill resizes the image to the given size using the cropper to determine
+// which part of the image to keep.
+func fill(cropper Cropper, img image.Image, width, height int) *image.NRGBA {
+	if width <= 0 || height <= 0 || img.Bounds().Empty() {
+		return &image.NRGBA{}
+	}
+
+	rect := cropper.Crop(img, width, height)
+	return imaging.Resize(imaging.Crop(img, rect), width, height, imaging.Lanczos)
+}
+
+// A DuplicateCropperError is returned when a cropper is registered using a
+// name which is already taken.
+type DuplicateCropperError struct {
+	Name string
+}
+
+func (e *DuplicateCropperError) Error() string {
+	return fmt.Sprintf("cropper %q already registered", e.Name)
+}
+
+var (
+	croppersMu         sync.RWMutex
+	registeredCroppers = make(map[string]Cropper)
+)
+
+// RegisterCropper registers the cropper under the given name. It's safe
+// for concurrent use.
+func RegisterCropper(name string, cropper Cropper) error {
+	if name == "" {
+		return fmt.Errorf("cropper name must not be empty")
+	}
+
+	croppersMu.Lock()
+	defer croppersMu.Unlock()
+
+	if _, ok := registeredCroppers[name]; ok {
+		return &DuplicateCropperError{Name: name}
+	}
+
+	registeredCroppers[name] = cropper
+	return nil
+}
+
+// GetCropper returns the cropper with the given name.
+func GetCropper(name string) (Cropper, bool) {
+	croppersMu.RLock()
+	defer croppersMu.RUnlock()
+
+	cropper, ok := registeredCroppers[name]
+	return cropper, ok
+}
+
+// GetCropperNames returns the sorted names of all registered croppers.
+func GetCropperNames() []string {
+	croppersMu.RLock()
+	defer croppersMu.RUnlock()
+
+	names := make([]string, 0, len(registeredCroppers))
+	for name := range registeredCroppers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func checkCropper(value interface{}) error {
+	name, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("expected cropper name, got %T", value)
+	}
+
+	if _, ok := GetCropper(name); !ok {
+		return fmt.Errorf("unknown cropper %q", value)
+	}
+
+	return nil
+}
+
+// Cropper returns the cropper selected by the "crop" option.
+// If the option isn't set, the CenterCropper is returned.
+func (opts Options) Cropper() Cropper {
+	if cropper, ok := GetCropper(opts.Str("crop")); ok {
+		return cropper
+	}
+
+	return CenterCropper
+}
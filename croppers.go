@@ -0,0 +1,355 @@
+package mosaic
+
+import (
+	"fmt"
+	"github.com/disintegration/imaging"
+	"github.com/gieseladev/mosaic/pkg/geom"
+	"image"
+	"math"
+)
+
+// analysisSize is the maximum side length of the downscaled images the
+// content aware croppers analyse.
+const analysisSize = 128
+
+// scoreMap holds a score for every pixel of a downscaled image.
+type scoreMap struct {
+	W, H   int
+	Scores []float64
+}
+
+func (m scoreMap) at(x, y int) float64 {
+	return m.Scores[y*m.W+x]
+}
+
+// profile sums up the scores along the given axis.
+// If horizontal is true the result contains a value per column, otherwise
+// per row.
+func (m scoreMap) profile(horizontal bool) []float64 {
+	var p []float64
+	if horizontal {
+		p = make([]float64, m.W)
+	} else {
+		p = make([]float64, m.H)
+	}
+
+	for y := 0; y < m.H; y++ {
+		for x := 0; x < m.W; x++ {
+			if horizontal {
+				p[x] += m.at(x, y)
+			} else {
+				p[y] += m.at(x, y)
+			}
+		}
+	}
+
+	return p
+}
+
+// downscale returns a copy of the image small enough to be analysed.
+func downscale(img image.Image) *image.NRGBA {
+	b := img.Bounds()
+	scale := math.Min(1, analysisSize/math.Max(float64(b.Dx()), float64(b.Dy())))
+
+	w := int(math.Max(1, math.Round(float64(b.Dx())*scale)))
+	h := int(math.Max(1, math.Round(float64(b.Dy())*scale)))
+
+	return imaging.Resize(img, w, h, imaging.Box)
+}
+
+// rgbAt returns the color components of the pixel in the range [0, 1].
+// The image bounds must start at the origin.
+func rgbAt(img *image.NRGBA, x, y int) (r, g, b float64) {
+	i := img.PixOffset(x, y)
+	p := img.Pix[i : i+3]
+	return float64(p[0]) / 255, float64(p[1]) / 255, float64(p[2]) / 255
+}
+
+// luminance returns the relative luminance for every pixel of the image.
+func luminance(img *image.NRGBA) scoreMap {
+	size := img.Bounds().Size()
+	m := scoreMap{W: size.X, H: size.Y, Scores: make([]float64, size.X*size.Y)}
+
+	for y := 0; y < m.H; y++ {
+		for x := 0; x < m.W; x++ {
+			r, g, b := rgbAt(img, x, y)
+			m.Scores[y*m.W+x] = .2126*r + .7152*g + .0722*b
+		}
+	}
+
+	return m
+}
+
+// edges returns the gradient magnitude of the luminance using the Sobel
+// operator.
+func edges(lum scoreMap) scoreMap {
+	m := scoreMap{W: lum.W, H: lum.H, Scores: make([]float64, len(lum.Scores))}
+
+	at := func(x, y int) float64 {
+		if x < 0 {
+			x = 0
+		} else if x >= lum.W {
+			x = lum.W - 1
+		}
+
+		if y < 0 {
+			y = 0
+		} else if y >= lum.H {
+			y = lum.H - 1
+		}
+
+		return lum.at(x, y)
+	}
+
+	for y := 0; y < m.H; y++ {
+		for x := 0; x < m.W; x++ {
+			gx := at(x+1, y-1) + 2*at(x+1, y) + at(x+1, y+1) -
+				at(x-1, y-1) - 2*at(x-1, y) - at(x-1, y+1)
+			gy := at(x-1, y+1) + 2*at(x, y+1) + at(x+1, y+1) -
+				at(x-1, y-1) - 2*at(x, y-1) - at(x+1, y-1)
+
+			m.Scores[y*m.W+x] = math.Sqrt(gx*gx + gy*gy)
+		}
+	}
+
+	return m
+}
+
+// cropWindow describes the crop along the only axis which needs to be
+// cropped.
+type cropWindow struct {
+	bounds        image.Rectangle
+	width, height int
+	horizontal    bool
+}
+
+func newCropWindow(img image.Image, width, height int) cropWindow {
+	b := img.Bounds()
+	cropW, cropH := cropSize(b, width, height)
+	return cropWindow{bounds: b, width: cropW, height: cropH, horizontal: cropW < b.Dx()}
+}
+
+// trivial checks whether the window covers the entire image.
+func (w cropWindow) trivial() bool {
+	return w.width >= w.bounds.Dx() && w.height >= w.bounds.Dy()
+}
+
+// scaled returns the length of the window along the crop axis when the
+// image is scaled to have n pixels along the same axis.
+func (w cropWindow) scaled(n int) int {
+	var l float64
+	if w.horizontal {
+		l = float64(n) * float64(w.width) / float64(w.bounds.Dx())
+	} else {
+		l = float64(n) * float64(w.height) / float64(w.bounds.Dy())
+	}
+
+	return int(math.Max(1, math.Min(float64(n), math.Round(l))))
+}
+
+// rect returns the rectangle for the window starting at the given offset
+// along the crop axis of an image with n pixels along that axis.
+func (w cropWindow) rect(offset, n int) image.Rectangle {
+	b := w.bounds
+	var min image.Point
+	if w.horizontal {
+		x := int(math.Round(float64(offset) * float64(b.Dx()) / float64(n)))
+		x = int(math.Min(float64(x), float64(b.Dx()-w.width)))
+		min = image.Pt(b.Min.X+x, b.Min.Y)
+	} else {
+		y := int(math.Round(float64(offset) * float64(b.Dy()) / float64(n)))
+		y = int(math.Min(float64(y), float64(b.Dy()-w.height)))
+		min = image.Pt(b.Min.X, b.Min.Y+y)
+	}
+
+	return image.Rectangle{Min: min, Max: min.Add(image.Pt(w.width, w.height))}
+}
+
+// bestWindowOffset finds the window in the profile with the highest sum.
+// The center bias (0 - 1) reduces the score of windows the further they
+// are from the center.
+func bestWindowOffset(profile []float64, length int, centerBias float64) int {
+	var sum float64
+	for _, v := range profile[:length] {
+		sum += v
+	}
+
+	maxOffset := len(profile) - length
+	if maxOffset == 0 {
+		return 0
+	}
+
+	best, bestScore := 0, math.Inf(-1)
+	for offset := 0; ; offset++ {
+		dist := math.Abs(float64(offset)/float64(maxOffset)-.5) * 2
+		score := sum * (1 - centerBias*dist)
+		if score > bestScore {
+			best, bestScore = offset, score
+		}
+
+		if offset == maxOffset {
+			break
+		}
+
+		sum += profile[offset+length] - profile[offset]
+	}
+
+	return best
+}
+
+// scoreCropper returns a cropper which keeps the window with the highest
+// total score where the score of each pixel is determined by the given
+// function operating on a downscaled version of the image.
+func scoreCropper(score func(img *image.NRGBA) scoreMap, centerBias float64) Cropper {
+	return CropperFunc(func(img image.Image, width, height int) image.Rectangle {
+		w := newCropWindow(img, width, height)
+		if w.trivial() {
+			return w.bounds
+		}
+
+		m := score(downscale(img))
+		profile := m.profile(w.horizontal)
+		length := w.scaled(len(profile))
+
+		return w.rect(bestWindowOffset(profile, length, centerBias), len(profile))
+	})
+}
+
+// EdgeCropper keeps the part of the image with the highest edge density.
+var EdgeCropper = scoreCropper(func(img *image.NRGBA) scoreMap {
+	return edges(luminance(img))
+}, .1)
+
+// saliency estimates how interesting each pixel is by combining edges,
+// saturation and skin tones.
+func saliency(img *image.NRGBA) scoreMap {
+	lum := luminance(img)
+	m := edges(lum)
+
+	for y := 0; y < m.H; y++ {
+		for x := 0; x < m.W; x++ {
+			r, g, b := rgbAt(img, x, y)
+			l := lum.at(x, y)
+
+			maxC := math.Max(r, math.Max(g, b))
+			minC := math.Min(r, math.Min(g, b))
+
+			var saturation float64
+			if maxC > 0 && l > .05 && l < .95 {
+				saturation = (maxC - minC) / maxC
+			}
+
+			// distance to a typical skin tone
+			dr, dg, db := r-.78, g-.57, b-.44
+			skin := math.Max(0, 1-math.Sqrt(dr*dr+dg*dg+db*db)*2.5)
+			if l < .2 || l > .9 {
+				skin = 0
+			}
+
+			m.Scores[y*m.W+x] += .3*saturation + 1.8*skin
+		}
+	}
+
+	return m
+}
+
+// SaliencyCropper keeps the part of the image which is most likely to be
+// interesting to a human.
+// It combines edge density, saturation and skin tone detection with a bias
+// towards the center of the image.
+var SaliencyCropper = scoreCropper(saliency, .2)
+
+// entropyBins is the number of histogram bins used to calculate the
+// entropy.
+const entropyBins = 32
+
+// entropy calculates the Shannon entropy of the luminance of the pixels in
+// the given area.
+func entropy(lum scoreMap, area image.Rectangle) float64 {
+	var hist [entropyBins]int
+	var total int
+
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			bin := int(lum.at(x, y) * (entropyBins - 1))
+			hist[bin]++
+			total++
+		}
+	}
+
+	var e float64
+	for _, count := range hist {
+		if count > 0 {
+			p := float64(count) / float64(total)
+			e -= p * math.Log2(p)
+		}
+	}
+
+	return e
+}
+
+// EntropyCropper keeps the part of the image with the most information by
+// repeatedly removing the slice with the lower entropy from either side.
+var EntropyCropper Cropper = CropperFunc(func(img image.Image, width, height int) image.Rectangle {
+	w := newCropWindow(img, width, height)
+	if w.trivial() {
+		return w.bounds
+	}
+
+	lum := luminance(downscale(img))
+
+	n := lum.H
+	if w.horizontal {
+		n = lum.W
+	}
+
+	length := w.scaled(n)
+	step := (n - length) / 8
+	if step < 1 {
+		step = 1
+	}
+
+	slice := func(start, end int) image.Rectangle {
+		if w.horizontal {
+			return image.Rect(start, 0, end, lum.H)
+		}
+
+		return image.Rect(0, start, lum.W, end)
+	}
+
+	low, high := 0, n
+	for high-low > length {
+		s := step
+		if high-low-s < length {
+			s = high - low - length
+		}
+
+		if entropy(lum, slice(low, low+s)) < entropy(lum, slice(high-s, high)) {
+			low += s
+		} else {
+			high -= s
+		}
+	}
+
+	return w.rect(low, n)
+})
+
+func init() {
+	croppers := map[string]Cropper{
+		"center": CenterCropper,
+		"top":    AnchorCropper(geom.Pt(.5, 0)),
+		"bottom": AnchorCropper(geom.Pt(.5, 1)),
+		"left":   AnchorCropper(geom.Pt(0, .5)),
+		"right":  AnchorCropper(geom.Pt(1, .5)),
+
+		"entropy":  EntropyCropper,
+		"edges":    EdgeCropper,
+		"saliency": SaliencyCropper,
+	}
+
+	for name, cropper := range croppers {
+		if err := RegisterCropper(name, cropper); err != nil {
+			panic(fmt.Sprintf("couldn't register built-in cropper %q: %v", name, err))
+		}
+	}
+}
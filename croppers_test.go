@@ -0,0 +1,80 @@
+package mosaic
+
+import (
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+// detailedImage creates a flat image with a noisy square in the given
+// area.
+func detailedImage(bounds, detail image.Rectangle) image.Image {
+	img := image.NewNRGBA(bounds)
+	r := rand.New(rand.NewSource(0))
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBA{R: 90, G: 90, B: 90, A: 255}
+			if image.Pt(x, y).In(detail) {
+				c.R, c.G, c.B = uint8(r.Intn(256)), uint8(r.Intn(256)), uint8(r.Intn(256))
+			}
+
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	return img
+}
+
+func TestCenterCropper(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 300, 200))
+	assert.Equal(t, image.Rect(50, 0, 250, 200), CenterCropper.Crop(img, 50, 50))
+	assert.Equal(t, image.Rect(0, 25, 300, 175), CenterCropper.Crop(img, 100, 50))
+	assert.Equal(t, img.Bounds(), CenterCropper.Crop(img, 30, 20))
+}
+
+func TestRegisterCropper(t *testing.T) {
+	err := RegisterCropper("center", CenterCropper)
+	assert.Equal(t, &DuplicateCropperError{Name: "center"}, err)
+
+	assert.Error(t, RegisterCropper("", CenterCropper))
+}
+
+func TestContentCroppers(t *testing.T) {
+	bounds := image.Rect(0, 0, 400, 200)
+	detail := image.Rect(300, 50, 400, 150)
+	img := detailedImage(bounds, detail)
+
+	for _, name := range []string{"entropy", "edges", "saliency"} {
+		cropper, ok := GetCropper(name)
+		if !assert.True(t, ok, name) {
+			continue
+		}
+
+		rect := cropper.Crop(img, 100, 100)
+		assert.Equal(t, image.Pt(200, 200), rect.Size(), name)
+		assert.True(t, rect.In(bounds), name)
+		assert.True(t, detail.In(rect), "%s didn't keep the detailed area: %v", name, rect)
+	}
+}
+
+func TestContentCroppersVertical(t *testing.T) {
+	bounds := image.Rect(10, 10, 110, 310)
+	detail := image.Rect(10, 20, 110, 80)
+	img := detailedImage(bounds, detail)
+
+	for _, cropper := range []Cropper{EntropyCropper, EdgeCropper, SaliencyCropper} {
+		rect := cropper.Crop(img, 100, 100)
+		assert.Equal(t, image.Pt(100, 100), rect.Size())
+		assert.True(t, rect.In(bounds))
+		assert.True(t, detail.In(rect), "detailed area not kept: %v", rect)
+	}
+}
+
+func TestCheckCropper(t *testing.T) {
+	assert.NoError(t, checkCropper("entropy"))
+	assert.Error(t, checkCropper("unknown"))
+	assert.Error(t, checkCropper(1))
+}
@@ -0,0 +1,264 @@
+package mosaic
+
+import (
+	"github.com/gieseladev/mosaic/pkg/geom"
+	"image"
+	"image/color"
+	"math"
+)
+
+// TileOptions are the options supported by all tile based composers.
+// They configure the Decoration of the tiles.
+var TileOptions = []Option{
+	{
+		Name:        "gutter",
+		Description: "space between the tiles in pixels",
+		Type:        OptionFloat,
+		Default:     0.,
+		Min:         0,
+		Max:         4096,
+	},
+	{
+		Name:        "gutter-color",
+		Description: "color of the gutters and the border",
+		Type:        OptionColor,
+		Default:     "transparent",
+	},
+	{
+		Name:        "border",
+		Description: "width of the border around the tiles in pixels",
+		Type:        OptionFloat,
+		Default:     0.,
+		Min:         0,
+		Max:         4096,
+	},
+	{
+		Name:        "radius",
+		Description: "corner radius of the tiles in pixels",
+		Type:        OptionFloat,
+		Default:     0.,
+		Min:         0,
+		Max:         4096,
+	},
+}
+
+// withTileOptions returns the TileOptions followed by the given options.
+func withTileOptions(options ...Option) []Option {
+	all := make([]Option, 0, len(TileOptions)+len(options))
+	all = append(all, TileOptions...)
+	return append(all, options...)
+}
+
+// A Decoration describes how the tiles of tile based composers are
+// decorated.
+type Decoration struct {
+	// Gutter is the space between two adjacent tiles.
+	Gutter float64
+	// Color is used for the gutters and the border.
+	Color color.Color
+	// Border is the width of the border around all tiles.
+	Border float64
+	// Radius is the corner radius of each tile.
+	Radius float64
+}
+
+// Decoration returns the decoration described by the TileOptions.
+func (opts Options) Decoration() Decoration {
+	return Decoration{
+		Gutter: opts.Float("gutter"),
+		Color:  opts.Color("gutter-color"),
+		Border: opts.Float("border"),
+		Radius: opts.Float("radius"),
+	}
+}
+
+// Empty checks whether the decoration has no visible effect.
+func (d Decoration) Empty() bool {
+	return d.Gutter <= 0 && d.Border <= 0 && d.Radius <= 0
+}
+
+// transparent checks whether the decoration color is fully transparent.
+func (d Decoration) transparent() bool {
+	if d.Color == nil {
+		return true
+	}
+
+	_, _, _, a := d.Color.RGBA()
+	return a == 0
+}
+
+// A tileLayout creates the regions of tile based composers honouring the
+// decoration.
+// All tile based composers should use it to lay out their tiles.
+type tileLayout struct {
+	layout     Layout
+	decoration Decoration
+	bounds     geom.Rectangle
+}
+
+// newTileLayout creates a tile layout for a canvas with the given size.
+func newTileLayout(width, height int, opts Options) *tileLayout {
+	t := &tileLayout{
+		layout:     Layout{Width: width, Height: height},
+		decoration: opts.Decoration(),
+		bounds:     geom.RectWithSideLengths(geom.Pt(float64(width), float64(height))),
+	}
+
+	d := t.decoration
+	if d.Empty() {
+		return t
+	}
+
+	// every tile is shrunk by half the gutter on all sides, so the bounds
+	// need to grow by the same amount to keep the border width.
+	t.bounds = t.bounds.Inset(d.Border - d.Gutter/2)
+
+	if !d.transparent() {
+		t.layout.Background = d.Color
+	}
+
+	return t
+}
+
+// Bounds returns the area which should be covered by the tiles.
+func (t *tileLayout) Bounds() geom.Rectangle {
+	return t.bounds
+}
+
+// Tile returns the tile with the given position relative to the bounds
+// and size.
+func (t *tileLayout) Tile(x, y, width, height int) geom.Rectangle {
+	return geom.Rect(0, 0, float64(width), float64(height)).
+		Translate(t.bounds.Min.Add(geom.Pt(float64(x), float64(y))))
+}
+
+// tileRect returns the area actually covered by the image of a tile.
+func (t *tileLayout) tileRect(tile geom.Rectangle) image.Rectangle {
+	tile = tile.Inset(t.decoration.Gutter / 2)
+
+	return image.Rect(
+		int(math.Round(tile.Min.X)), int(math.Round(tile.Min.Y)),
+		int(math.Round(tile.Max.X)), int(math.Round(tile.Max.Y)),
+	).Intersect(image.Rect(0, 0, t.layout.Width, t.layout.Height))
+}
+
+// Add adds a region showing the image in the given tile.
+func (t *tileLayout) Add(imageIndex int, tile geom.Rectangle) {
+	rect := t.tileRect(tile)
+	if rect.Empty() {
+		return
+	}
+
+	r := rectFromPixels(rect)
+	t.layout.Regions = append(t.layout.Regions, Region{
+		Image: imageIndex,
+		Shape: RectShape{Rect: r, Radius: t.decoration.Radius},
+		Rect:  r,
+	})
+}
+
+// AddPolygon adds a region showing the image in the convex polygon. Like
+// the tiles added by Add, the polygon is shrunk by half the gutter and its
+// corners are rounded.
+func (t *tileLayout) AddPolygon(imageIndex int, pg geom.Polygon) {
+	d := t.decoration
+	if d.Gutter > 0 {
+		var ok bool
+		if pg, ok = insetPolygon(pg, d.Gutter/2); !ok {
+			return
+		}
+	}
+
+	if d.Radius > 0 {
+		pg = roundPolygon(pg, d.Radius)
+	}
+
+	t.layout.Regions = append(t.layout.Regions, Region{
+		Image: imageIndex,
+		Shape: PolygonShape{pg},
+		Rect:  pg.BoundingRect(),
+	})
+}
+
+// corner returns the unit vectors from the vertex at index i of the
+// polygon towards its neighbours and half the angle between them.
+func corner(pg geom.Polygon, i int) (geom.Point, geom.Point, float64) {
+	n := len(pg.Vertices)
+	v := pg.Vertices[i]
+
+	r1, a1 := pg.Vertices[(i+n-1)%n].Sub(v).Polar()
+	r2, a2 := pg.Vertices[(i+1)%n].Sub(v).Polar()
+	if r1 == 0 || r2 == 0 {
+		return geom.Point{}, geom.Point{}, 0
+	}
+
+	u1, u2 := geom.PtFromPolar(1, a1), geom.PtFromPolar(1, a2)
+	dot := math.Max(-1, math.Min(1, u1.X*u2.X+u1.Y*u2.Y))
+	return u1, u2, math.Acos(dot) / 2
+}
+
+// insetPolygon moves all edges of the convex polygon inwards by the
+// distance. The second return value is false if nothing is left of the
+// polygon.
+func insetPolygon(pg geom.Polygon, distance float64) (geom.Polygon, bool) {
+	n := len(pg.Vertices)
+	vertices := make([]geom.Point, n)
+	for i, v := range pg.Vertices {
+		u1, u2, half := corner(pg, i)
+		if half == 0 {
+			return geom.Polygon{}, false
+		}
+
+		_, bisector := u1.Add(u2).Polar()
+		vertices[i] = v.Add(geom.PtFromPolar(distance/math.Sin(half), bisector))
+	}
+
+	// the edges flip their direction once the polygon collapses
+	for i := range vertices {
+		j := (i + 1) % n
+		before, after := pg.Vertices[j].Sub(pg.Vertices[i]), vertices[j].Sub(vertices[i])
+		if before.X*after.X+before.Y*after.Y <= 0 {
+			return geom.Polygon{}, false
+		}
+	}
+
+	return geom.Polygon{Vertices: vertices}, true
+}
+
+// roundPolygon replaces the corners of the convex polygon by circle arcs
+// with the radius. Like the radius of a RectShape, it's capped so that
+// the arcs of adjacent corners don't overlap.
+func roundPolygon(pg geom.Polygon, radius float64) geom.Polygon {
+	var vertices []geom.Point
+	for i, v := range pg.Vertices {
+		u1, u2, half := corner(pg, i)
+		if half == 0 {
+			continue
+		}
+
+		// distance from the vertex to the points where the arc touches
+		// the edges
+		tangent := radius / math.Tan(half)
+		n := len(pg.Vertices)
+		r1, _ := pg.Vertices[(i+n-1)%n].Sub(v).Polar()
+		r2, _ := pg.Vertices[(i+1)%n].Sub(v).Polar()
+		tangent = math.Min(tangent, math.Min(r1, r2)/2)
+		r := tangent * math.Tan(half)
+
+		_, bisector := u1.Add(u2).Polar()
+		center := v.Add(geom.PtFromPolar(r/math.Sin(half), bisector))
+
+		_, start := v.Add(u1.Mul(tangent)).Sub(center).Polar()
+		_, end := v.Add(u2.Mul(tangent)).Sub(center).Polar()
+		sweep := math.Remainder(end-start, geom.TwoPi)
+
+		vertices = append(vertices, arcPoints(center, r, start, start+sweep)...)
+	}
+
+	return geom.Polygon{Vertices: vertices}
+}
+
+// Layout returns the layout containing all tiles.
+func (t *tileLayout) Layout() Layout {
+	return t.layout
+}
@@ -0,0 +1,95 @@
+package mosaic
+
+import (
+	"github.com/fogleman/gg"
+	"github.com/gieseladev/mosaic/pkg/geom"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func solidImages(n int) []image.Image {
+	images := make([]image.Image, n)
+	for i := range images {
+		img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+		for j := 0; j < len(img.Pix); j += 4 {
+			img.Pix[j], img.Pix[j+3] = uint8(10*(i+1)), 255
+		}
+
+		images[i] = img
+	}
+
+	return images
+}
+
+func TestTileDecoration(t *testing.T) {
+	composer, ok := GetComposer("tiles-perfect")
+	if !assert.True(t, ok) {
+		return
+	}
+
+	dc := gg.NewContext(100, 100)
+	err := composer.Compose(dc, Options{
+		"gutter":       10,
+		"gutter-color": "#00ff00",
+		"border":       5,
+		"radius":       8,
+	}, solidImages(4)...)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	gutter := color.RGBA{G: 255, A: 255}
+	img := dc.Image()
+
+	// border
+	assert.Equal(t, gutter, img.At(2, 50))
+	assert.Equal(t, gutter, img.At(97, 50))
+	// gutter between the columns
+	assert.Equal(t, gutter, img.At(50, 30))
+	// rounded corner of the first tile
+	assert.Equal(t, gutter, img.At(5, 5))
+	// inside of the tiles
+	assert.Equal(t, color.RGBA{R: 10, A: 255}, img.At(25, 25))
+	assert.Equal(t, color.RGBA{R: 20, A: 255}, img.At(75, 25))
+}
+
+func TestTileDecorationEmpty(t *testing.T) {
+	composer, ok := GetComposer("tiles-perfect")
+	if !assert.True(t, ok) {
+		return
+	}
+
+	dc := gg.NewContext(100, 100)
+	err := composer.Compose(dc, nil, solidImages(4)...)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	img := dc.Image()
+	assert.Equal(t, color.RGBA{R: 10, A: 255}, img.At(0, 0))
+	assert.Equal(t, color.RGBA{R: 20, A: 255}, img.At(50, 0))
+	assert.Equal(t, color.RGBA{R: 40, A: 255}, img.At(99, 99))
+}
+
+func TestInsetPolygon(t *testing.T) {
+	square := geom.Poly(geom.Pt(0, 0), geom.Pt(10, 0), geom.Pt(10, 10), geom.Pt(0, 10))
+
+	inset, ok := insetPolygon(square, 2)
+	if assert.True(t, ok) {
+		assert.InDeltaSlice(t, []float64{2, 2, 8, 2, 8, 8, 2, 8}, flatVertices(inset), 1e-9)
+	}
+
+	_, ok = insetPolygon(square, 6)
+	assert.False(t, ok)
+}
+
+func flatVertices(pg geom.Polygon) []float64 {
+	var values []float64
+	for _, v := range pg.Vertices {
+		values = append(values, v.X, v.Y)
+	}
+
+	return values
+}
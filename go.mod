@@ -5,8 +5,9 @@ go 1.12
 require (
 	github.com/disintegration/imaging v1.6.0
 	github.com/fogleman/gg v1.3.0
-	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
+	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
 	github.com/stretchr/testify v1.3.0
 	golang.org/x/image v0.0.0-20180708004352-c73c2afc3b81
 	gopkg.in/urfave/cli.v2 v2.0.0-20180128182452-d3ae77c26ac8
+	gopkg.in/yaml.v2 v2.4.0
 )
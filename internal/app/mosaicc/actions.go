@@ -1,56 +1,194 @@
 package mosaicc
 
 import (
+	"fmt"
 	"github.com/fogleman/gg"
 	"github.com/gieseladev/mosaic"
 	"github.com/gieseladev/mosaic/pkg/geom"
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
 	"image"
 	"image/color"
+	"math"
 )
 
-// GenerateComposerShowcase generates an image containing a sample of all
-// composers.
-func GenerateComposerShowcase(images []image.Image) (image.Image, error) {
-	composers := mosaic.GetComposers()
-	panelsX, panelsY := geom.FindBalancedFactors(len(composers))
+// showcaseLineSpacing is the line spacing of the labels of the showcase.
+const showcaseLineSpacing = 1.05
 
-	panelWidth, panelHeight := 200, 300
-	marginX, marginY := 15, 15
+// ShowcaseOptions configure GenerateComposerShowcase.
+type ShowcaseOptions struct {
+	// PanelSize is the width and height of the composition of each panel.
+	PanelSize int
+	// Columns is the amount of panels per row. If it's 0 the panels are
+	// arranged in a balanced grid.
+	Columns int
+	// Margin is the space between two panels.
+	Margin int
 
-	fontPoints := float64(panelWidth) / 6
-	lineSpacing := 1.05
+	// Background fills the showcase. It may be nil.
+	Background color.Color
 
-	dc := gg.NewContext(panelsX*panelWidth+(panelsX-1)*marginX,
-		panelsY*panelHeight+(panelsY-1)*marginY)
+	// Labels enables the name of the composer above each panel.
+	Labels bool
+	// Details adds the description and image count below the name.
+	Details bool
+	// LabelColor is the color of the labels.
+	LabelColor color.Color
 
-	for i, composer := range composers {
-		compImages := images[:composer.RecommendImageCount(len(images))]
+	// Composers contains the ids of the composers to show. All composers
+	// are shown if it's empty.
+	Composers []string
+}
 
-		compositionDC := gg.NewContext(panelWidth, panelWidth)
-		err := composer.Compose(compositionDC, compImages...)
-		if err != nil {
-			return nil, err
+// DefaultShowcaseOptions are the options used by the showcase-gen command.
+var DefaultShowcaseOptions = ShowcaseOptions{
+	PanelSize:  200,
+	Margin:     15,
+	Labels:     true,
+	LabelColor: color.Black,
+}
+
+// showcaseComposers returns the composers selected by the options.
+func showcaseComposers(ids []string) ([]mosaic.ComposerInfo, error) {
+	if len(ids) == 0 {
+		return mosaic.GetComposers(), nil
+	}
+
+	composers := make([]mosaic.ComposerInfo, len(ids))
+	for i, id := range ids {
+		composer, ok := mosaic.GetComposer(id)
+		if !ok {
+			return nil, fmt.Errorf("no composer %q found", id)
 		}
 
-		composerDC := gg.NewContext(panelWidth, panelHeight)
+		composers[i] = composer
+	}
 
-		composerDC.DrawImage(compositionDC.Image(), 0, panelHeight-panelWidth)
+	return composers, nil
+}
 
-		// TODO nonononono, this ain't it, chief
-		if err = composerDC.LoadFontFace("/windows/fonts/arial.ttf", fontPoints); err != nil {
-			return nil, err
+// showcaseLabel is a piece of text drawn above a panel.
+type showcaseLabel struct {
+	Text string
+	Face font.Face
+}
+
+// labels returns the labels of the composer.
+func (opts ShowcaseOptions) labels(composer mosaic.ComposerInfo) []showcaseLabel {
+	if !opts.Labels {
+		return nil
+	}
+
+	size := float64(opts.PanelSize)
+	labels := []showcaseLabel{{
+		Text: composer.Name,
+		Face: truetype.NewFace(mosaic.DefaultBoldFont(), &truetype.Options{Size: size / 9}),
+	}}
+
+	if opts.Details {
+		detailFace := truetype.NewFace(mosaic.DefaultFont(), &truetype.Options{Size: size / 16})
+		if composer.Description != "" {
+			labels = append(labels, showcaseLabel{Text: composer.Description, Face: detailFace})
 		}
 
-		composerDC.SetColor(color.Black)
-		composerDC.DrawStringWrapped(composer.Name, 0, float64(panelHeight-panelWidth-marginY), 0, 1, float64(panelWidth), lineSpacing, gg.AlignCenter)
+		labels = append(labels, showcaseLabel{Text: "images: " + formatImageCount(composer), Face: detailFace})
+	}
+
+	return labels
+}
+
+// labelHeight returns the height of the wrapped labels.
+func labelHeight(dc *gg.Context, labels []showcaseLabel, width float64) float64 {
+	var height float64
+	for _, label := range labels {
+		dc.SetFontFace(label.Face)
+		lines := len(dc.WordWrap(label.Text, width))
+		height += float64(lines) * dc.FontHeight() * showcaseLineSpacing
+	}
+
+	return height
+}
 
-		column := i % panelsX
-		row := i / panelsX
+// GenerateComposerShowcase generates an image containing a sample of the
+// composers selected by the options.
+func GenerateComposerShowcase(images []image.Image, opts ShowcaseOptions) (image.Image, error) {
+	if opts.PanelSize <= 0 {
+		return nil, fmt.Errorf("panel size must be positive")
+	}
 
-		posX := column * (panelWidth + marginX)
-		posY := row * (panelHeight + marginY)
+	if opts.Columns < 0 || opts.Margin < 0 {
+		return nil, fmt.Errorf("columns and margin must not be negative")
+	}
+
+	composers, err := showcaseComposers(opts.Composers)
+	if err != nil {
+		return nil, err
+	}
 
-		dc.DrawImage(composerDC.Image(), posX, posY)
+	if len(composers) == 0 {
+		return nil, ErrNoComposer
+	}
+
+	panelsX, panelsY := geom.FindBalancedFactors(len(composers))
+	if opts.Columns > 0 {
+		panelsX = opts.Columns
+		panelsY = int(math.Ceil(float64(len(composers)) / float64(panelsX)))
+	}
+
+	panelWidth, margin := opts.PanelSize, opts.Margin
+
+	labels := make([][]showcaseLabel, len(composers))
+	var maxLabelHeight float64
+	measureDC := gg.NewContext(1, 1)
+	for i, composer := range composers {
+		labels[i] = opts.labels(composer)
+		maxLabelHeight = math.Max(maxLabelHeight, labelHeight(measureDC, labels[i], float64(panelWidth)))
+	}
+
+	labelSpace := 0
+	if maxLabelHeight > 0 {
+		labelSpace = int(math.Ceil(maxLabelHeight)) + margin
+	}
+
+	panelHeight := panelWidth + labelSpace
+
+	dc := gg.NewContext(panelsX*panelWidth+(panelsX-1)*margin,
+		panelsY*panelHeight+(panelsY-1)*margin)
+
+	if opts.Background != nil {
+		dc.SetColor(opts.Background)
+		dc.Clear()
+	}
+
+	labelColor := opts.LabelColor
+	if labelColor == nil {
+		labelColor = color.Black
+	}
+
+	for i, composer := range composers {
+		imgCount := composer.RecommendImageCount(len(images))
+		if imgCount == 0 {
+			return nil, fmt.Errorf("composer %q can't use %d images", composer.Id, len(images))
+		}
+
+		compositionDC := gg.NewContext(panelWidth, panelWidth)
+		if err := composer.Compose(compositionDC, nil, images[:imgCount]...); err != nil {
+			return nil, err
+		}
+
+		posX := float64((i % panelsX) * (panelWidth + margin))
+		posY := float64((i / panelsX) * (panelHeight + margin))
+
+		dc.DrawImage(compositionDC.Image(), int(posX), int(posY)+labelSpace)
+
+		// labels are aligned to the bottom of the label space
+		y := posY + maxLabelHeight - labelHeight(dc, labels[i], float64(panelWidth))
+		dc.SetColor(labelColor)
+		for _, label := range labels[i] {
+			dc.SetFontFace(label.Face)
+			dc.DrawStringWrapped(label.Text, posX, y, 0, 0, float64(panelWidth), showcaseLineSpacing, gg.AlignCenter)
+			y += labelHeight(dc, []showcaseLabel{label}, float64(panelWidth))
+		}
 	}
 
 	return dc.Image(), nil
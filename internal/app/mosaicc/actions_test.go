@@ -0,0 +1,46 @@
+package mosaicc
+
+import (
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestGenerateComposerShowcase(t *testing.T) {
+	images := make([]image.Image, 4)
+	for i := range images {
+		img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+		img.Set(0, 0, color.White)
+		images[i] = img
+	}
+
+	opts := DefaultShowcaseOptions
+	opts.PanelSize = 40
+	opts.Margin = 4
+	opts.Columns = 2
+	opts.Labels = false
+	opts.Composers = []string{"tiles-perfect", "circles-pie", "tiles-diamond"}
+
+	showcase, err := GenerateComposerShowcase(images, opts)
+	if assert.NoError(t, err) {
+		assert.Equal(t, image.Rect(0, 0, 2*40+4, 2*40+4), showcase.Bounds())
+	}
+
+	opts.Labels = true
+	opts.Details = true
+	showcase, err = GenerateComposerShowcase(images, opts)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2*40+4, showcase.Bounds().Dx())
+		assert.True(t, showcase.Bounds().Dy() > 2*40+4, "labels need space")
+	}
+
+	opts.Composers = []string{"unknown"}
+	_, err = GenerateComposerShowcase(images, opts)
+	assert.Error(t, err)
+
+	opts = DefaultShowcaseOptions
+	opts.PanelSize = 0
+	_, err = GenerateComposerShowcase(images, opts)
+	assert.Error(t, err)
+}
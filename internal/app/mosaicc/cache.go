@@ -0,0 +1,429 @@
+package mosaicc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// cacheMetaExt is the extension of the files holding the CacheEntry of
+	// the cached data.
+	cacheMetaExt = ".json"
+	// maxHeuristicLifetime limits the lifetime of responses without
+	// explicit expiration.
+	maxHeuristicLifetime = 24 * time.Hour
+)
+
+// A CacheEntry describes a cached response.
+type CacheEntry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Expires      time.Time `json:"expires"`
+	Size         int64     `json:"size"`
+
+	// LastUsed is the last time the entry was stored or read.
+	LastUsed time.Time `json:"-"`
+}
+
+// Fresh checks whether the entry can be used without revalidating it.
+func (e CacheEntry) Fresh(now time.Time) bool {
+	return now.Before(e.Expires)
+}
+
+// A Cache stores remote images on disk. Entries are keyed by the SHA-256
+// hash of their URL and expire according to the Cache-Control, Expires and
+// Last-Modified headers of the response. When the cache grows larger than
+// MaxSize, the least recently used entries are removed.
+//
+// A Cache is safe for concurrent use by a single process.
+type Cache struct {
+	Dir string
+	// MaxSize is the maximum size of the cached data in bytes. 0 disables
+	// the limit.
+	MaxSize int64
+
+	mu sync.Mutex
+	// size is the total size of the cached data. It's read from the
+	// directory once and kept up to date by Put and Prune afterwards.
+	size  int64
+	sized bool
+}
+
+// NewCache creates a cache storing its entries in the directory.
+func NewCache(dir string, maxSize int64) *Cache {
+	return &Cache{Dir: dir, MaxSize: maxSize}
+}
+
+// DefaultCacheDir returns the directory used for the cache if none is
+// given.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "mosaic"), nil
+}
+
+func (c *Cache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:]))
+}
+
+// readEntry reads the entry stored for the data at the path.
+func readEntry(path string) (CacheEntry, error) {
+	var entry CacheEntry
+
+	data, err := ioutil.ReadFile(path + cacheMetaExt)
+	if err != nil {
+		return entry, err
+	}
+
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return entry, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return entry, err
+	}
+
+	entry.LastUsed = info.ModTime()
+	return entry, nil
+}
+
+// writeFile atomically replaces the file at the path.
+func writeFile(path string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+func (c *Cache) writeEntry(path string, entry CacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(path+cacheMetaExt, data)
+}
+
+// Get returns the cached entry and data of the URL.
+func (c *Cache) Get(url string) (CacheEntry, []byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := c.path(url)
+	entry, err := readEntry(path)
+	if err != nil || entry.URL != url {
+		return CacheEntry{}, nil, false
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return CacheEntry{}, nil, false
+	}
+
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	entry.LastUsed = now
+
+	return entry, data, true
+}
+
+// cacheControl parses the directives of the Cache-Control header.
+func cacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, value := range header["Cache-Control"] {
+		for _, directive := range strings.Split(value, ",") {
+			parts := strings.SplitN(strings.TrimSpace(directive), "=", 2)
+			name := strings.ToLower(parts[0])
+			if len(parts) == 2 {
+				directives[name] = strings.Trim(parts[1], `"`)
+			} else {
+				directives[name] = ""
+			}
+		}
+	}
+
+	return directives
+}
+
+// expiration returns when a response with the header expires and whether it
+// may be stored at all.
+func expiration(header http.Header, now time.Time) (time.Time, bool) {
+	directives := cacheControl(header)
+	if _, ok := directives["no-store"]; ok {
+		return now, false
+	}
+
+	if _, ok := directives["no-cache"]; ok {
+		return now, true
+	}
+
+	if maxAge, ok := directives["max-age"]; ok {
+		if seconds, err := strconv.ParseInt(maxAge, 10, 64); err == nil {
+			return now.Add(time.Duration(seconds) * time.Second), true
+		}
+	}
+
+	if expires := header.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return now, true
+		}
+
+		return t, true
+	}
+
+	// heuristic freshness, see RFC 7234 section 4.2.2
+	if lastModified, err := http.ParseTime(header.Get("Last-Modified")); err == nil {
+		lifetime := now.Sub(lastModified) / 10
+		if lifetime > maxHeuristicLifetime {
+			lifetime = maxHeuristicLifetime
+		}
+
+		return now.Add(lifetime), true
+	}
+
+	return now, true
+}
+
+// Put stores the response for the URL. Responses which mustn't be stored
+// according to their header are ignored.
+func (c *Cache) Put(url string, header http.Header, data []byte) error {
+	expires, store := expiration(header, time.Now())
+	if !store {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+
+	if !c.sized {
+		if err := c.readSize(); err != nil {
+			return err
+		}
+	}
+
+	path := c.path(url)
+	if old, err := readEntry(path); err == nil {
+		c.size -= old.Size
+	}
+
+	if err := writeFile(path, data); err != nil {
+		return err
+	}
+
+	entry := CacheEntry{
+		URL:          url,
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+		Expires:      expires,
+		Size:         int64(len(data)),
+	}
+
+	if err := c.writeEntry(path, entry); err != nil {
+		return err
+	}
+
+	c.size += entry.Size
+
+	if c.MaxSize > 0 && c.size > c.MaxSize {
+		_, err := c.prune(c.MaxSize)
+		return err
+	}
+
+	return nil
+}
+
+// Revalidate updates the expiration of the entry of the URL after the
+// server confirmed that it's still valid.
+func (c *Cache) Revalidate(url string, header http.Header) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := c.path(url)
+	entry, err := readEntry(path)
+	if err != nil {
+		return err
+	}
+
+	entry.Expires, _ = expiration(header, time.Now())
+	if etag := header.Get("ETag"); etag != "" {
+		entry.ETag = etag
+	}
+
+	return c.writeEntry(path, entry)
+}
+
+// cacheFile is an entry together with the path of its data.
+type cacheFile struct {
+	Entry CacheEntry
+	Path  string
+}
+
+// files returns all entries ordered from the least to the most recently
+// used one.
+func (c *Cache) files() ([]cacheFile, error) {
+	infos, err := ioutil.ReadDir(c.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var files []cacheFile
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, cacheMetaExt) {
+			continue
+		}
+
+		path := filepath.Join(c.Dir, name)
+		entry, err := readEntry(path)
+		if err != nil {
+			continue
+		}
+
+		files = append(files, cacheFile{Entry: entry, Path: path})
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].Entry.LastUsed.Before(files[j].Entry.LastUsed)
+	})
+
+	return files, nil
+}
+
+// Entries returns all entries ordered from the least to the most recently
+// used one.
+func (c *Cache) Entries() ([]CacheEntry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	files, err := c.files()
+	entries := make([]CacheEntry, len(files))
+	for i, f := range files {
+		entries[i] = f.Entry
+	}
+
+	return entries, err
+}
+
+// readSize sums up the size of all entries.
+func (c *Cache) readSize() error {
+	files, err := c.files()
+	if err != nil {
+		return err
+	}
+
+	c.size = 0
+	for _, f := range files {
+		c.size += f.Entry.Size
+	}
+
+	c.sized = true
+	return nil
+}
+
+func removeEntry(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.Remove(path + cacheMetaExt); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (c *Cache) prune(maxSize int64) (int, error) {
+	files, err := c.files()
+	if err != nil {
+		return 0, err
+	}
+
+	var size int64
+	for _, f := range files {
+		size += f.Entry.Size
+	}
+
+	// the size is updated even if removing an entry fails
+	defer func() { c.size, c.sized = size, true }()
+
+	var removed int
+	for _, f := range files {
+		if size <= maxSize {
+			break
+		}
+
+		if err := removeEntry(f.Path); err != nil {
+			return removed, err
+		}
+
+		size -= f.Entry.Size
+		removed++
+	}
+
+	return removed, nil
+}
+
+// Prune removes the least recently used entries until the cache is no
+// larger than maxSize and returns the amount of removed entries.
+func (c *Cache) Prune(maxSize int64) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.prune(maxSize)
+}
+
+// WriteCacheEntries writes a human readable list of the entries to w.
+func WriteCacheEntries(w io.Writer, entries []CacheEntry) error {
+	var b strings.Builder
+
+	now := time.Now()
+	for _, entry := range entries {
+		state := "fresh"
+		if !entry.Fresh(now) {
+			state = "stale"
+		}
+
+		_, _ = fmt.Fprintf(&b, "%s\n    size: %d bytes, %s, last used: %s\n",
+			entry.URL, entry.Size, state, entry.LastUsed.Format(time.RFC3339))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
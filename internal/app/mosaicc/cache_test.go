@@ -0,0 +1,199 @@
+package mosaicc
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func newTestCache(t *testing.T, maxSize int64) (*Cache, func()) {
+	dir, err := ioutil.TempDir("", "mosaic-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewCache(dir, maxSize), func() { _ = os.RemoveAll(dir) }
+}
+
+func TestExpiration(t *testing.T) {
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		Header  http.Header
+		Expires time.Time
+		Store   bool
+	}{
+		{http.Header{}, now, true},
+		{http.Header{"Cache-Control": {"public, max-age=60"}}, now.Add(time.Minute), true},
+		{http.Header{"Cache-Control": {"no-store"}}, now, false},
+		{http.Header{"Cache-Control": {"no-cache"}, "Expires": {"Tue, 01 Jan 2019 01:00:00 GMT"}}, now, true},
+		{http.Header{"Expires": {"Tue, 01 Jan 2019 01:00:00 GMT"}}, now.Add(time.Hour), true},
+		{http.Header{"Last-Modified": {"Mon, 31 Dec 2018 14:00:00 GMT"}}, now.Add(time.Hour), true},
+		{http.Header{"Last-Modified": {"Mon, 01 Jan 2018 00:00:00 GMT"}}, now.Add(maxHeuristicLifetime), true},
+	}
+
+	for _, test := range tests {
+		expires, store := expiration(test.Header, now)
+		assert.True(t, test.Expires.Equal(expires), "%v: %v", test.Header, expires)
+		assert.Equal(t, test.Store, store, "%v", test.Header)
+	}
+}
+
+func TestCache_Prune(t *testing.T) {
+	cache, cleanup := newTestCache(t, 10)
+	defer cleanup()
+
+	header := http.Header{"Cache-Control": {"max-age=60"}}
+	assert.NoError(t, cache.Put("a", header, []byte("aaaa")))
+	assert.NoError(t, cache.Put("b", header, []byte("bbbb")))
+
+	// make "a" the most recently used entry
+	past := time.Now().Add(-time.Minute)
+	_ = os.Chtimes(cache.path("b"), past, past)
+	_, data, ok := cache.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("aaaa"), data)
+
+	assert.NoError(t, cache.Put("c", header, []byte("cccc")))
+
+	_, _, ok = cache.Get("b")
+	assert.False(t, ok, "least recently used entry must be evicted")
+
+	entries, err := cache.Entries()
+	if assert.NoError(t, err) && assert.Len(t, entries, 2) {
+		assert.ElementsMatch(t, []string{"a", "c"}, []string{entries[0].URL, entries[1].URL})
+	}
+
+	removed, err := cache.Prune(0)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, removed)
+
+	entries, _ = cache.Entries()
+	assert.Empty(t, entries)
+}
+
+func TestCache_PutReplace(t *testing.T) {
+	cache, cleanup := newTestCache(t, 10)
+	defer cleanup()
+
+	header := http.Header{"Cache-Control": {"max-age=60"}}
+	assert.NoError(t, cache.Put("a", header, []byte("aaaa")))
+	assert.NoError(t, cache.Put("b", header, []byte("bbbb")))
+
+	// replacing an entry mustn't count its old size
+	assert.NoError(t, cache.Put("a", header, []byte("AAAA")))
+	assert.Equal(t, int64(8), cache.size)
+
+	entries, err := cache.Entries()
+	if assert.NoError(t, err) {
+		assert.Len(t, entries, 2)
+	}
+}
+
+func TestLoader_CacheInvalidImage(t *testing.T) {
+	cache, cleanup := newTestCache(t, 0)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		_, _ = w.Write([]byte("not an image"))
+	}))
+	defer server.Close()
+
+	l := NewLoader()
+	l.Cache = cache
+
+	_, err := l.Load(context.Background(), server.URL+"/broken.png")
+	assert.Error(t, err)
+
+	entries, err := cache.Entries()
+	if assert.NoError(t, err) {
+		assert.Empty(t, entries, "images which can't be decoded mustn't be cached")
+	}
+}
+
+func TestLoader_Cache(t *testing.T) {
+	cache, cleanup := newTestCache(t, 0)
+	defer cleanup()
+
+	image := encodeTestPNG(4, 4)
+
+	var requests, revalidations int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		if r.URL.Path == "/fresh.png" {
+			w.Header().Set("Cache-Control", "max-age=3600")
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			assert.Empty(t, r.Header.Get("If-Modified-Since"))
+			revalidations++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		_, _ = w.Write(image)
+	}))
+	defer server.Close()
+
+	l := NewLoader()
+	l.Cache = cache
+
+	for i := 0; i < 3; i++ {
+		_, err := l.Load(context.Background(), server.URL+"/fresh.png")
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 1, requests, "fresh entries mustn't be requested again")
+
+	for i := 0; i < 3; i++ {
+		_, err := l.Load(context.Background(), server.URL+"/stale.png")
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 4, requests)
+	assert.Equal(t, 2, revalidations)
+}
+
+func TestLoader_CacheLastModified(t *testing.T) {
+	cache, cleanup := newTestCache(t, 0)
+	defer cleanup()
+
+	image := encodeTestPNG(4, 4)
+	lastModified := "Mon, 02 Jan 2006 15:04:05 GMT"
+
+	var revalidations int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", lastModified)
+		w.Header().Set("Cache-Control", "no-cache")
+
+		if r.Header.Get("If-Modified-Since") == lastModified {
+			revalidations++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		_, _ = w.Write(image)
+	}))
+	defer server.Close()
+
+	l := NewLoader()
+	l.Cache = cache
+
+	for i := 0; i < 2; i++ {
+		_, err := l.Load(context.Background(), server.URL+"/image.png")
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 1, revalidations)
+}
@@ -0,0 +1,138 @@
+package mosaicc
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// An AddressError is returned when a client created by NewPublicClient
+// refuses to connect to an address.
+type AddressError struct {
+	Address string
+}
+
+func (e *AddressError) Error() string {
+	return fmt.Sprintf("connecting to the non-public address %s isn't allowed", e.Address)
+}
+
+// privateNetworks are the networks which aren't reachable publicly and
+// aren't covered by the methods of net.IP.
+var privateNetworks = mustParseNetworks(
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"100.64.0.0/10",
+	"fc00::/7",
+)
+
+func mustParseNetworks(cidrs ...string) []*net.IPNet {
+	networks, err := ParseNetworks(cidrs)
+	if err != nil {
+		panic(err)
+	}
+
+	return networks
+}
+
+// ParseNetworks parses the networks in CIDR notation, like "10.0.0.0/8".
+func ParseNetworks(cidrs []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+
+		networks[i] = network
+	}
+
+	return networks, nil
+}
+
+// isPublicIP checks whether the IP is neither a loopback, link-local,
+// private nor unspecified address.
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() {
+		return false
+	}
+
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkAddress makes sure the dialled address is public or in one of the
+// allowed networks.
+func checkAddress(address string, allowed []*net.IPNet) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return &AddressError{Address: address}
+	}
+
+	for _, network := range allowed {
+		if network.Contains(ip) {
+			return nil
+		}
+	}
+
+	if !isPublicIP(ip) {
+		return &AddressError{Address: address}
+	}
+
+	return nil
+}
+
+// NewPublicClient creates an HTTP client which refuses to connect to
+// loopback, link-local and private addresses unless they're in one of the
+// allowed networks. The addresses are checked after resolving the host
+// names, so redirects and DNS records pointing to internal services are
+// refused as well.
+func NewPublicClient(allowed ...*net.IPNet) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			return checkAddress(address, allowed)
+		},
+	}
+
+	// a proxy would be dialled instead of the host, so none is used
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+	}
+}
+
+// isAddressError checks whether the request failed because the client
+// refused to connect to the address.
+func isAddressError(err error) bool {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+
+	if opErr, ok := err.(*net.OpError); ok {
+		err = opErr.Err
+	}
+
+	_, ok := err.(*AddressError)
+	return ok
+}
@@ -0,0 +1,53 @@
+package mosaicc
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckAddress(t *testing.T) {
+	allowed := mustParseNetworks("10.1.0.0/16")
+
+	tests := map[string]bool{
+		"93.184.216.34:80":     true,
+		"[2606:4700::1111]:80": true,
+		"10.1.2.3:80":          true,
+		"127.0.0.1:80":         false,
+		"[::1]:443":            false,
+		"169.254.169.254:80":   false,
+		"10.2.0.1:80":          false,
+		"172.16.5.4:80":        false,
+		"192.168.1.1:80":       false,
+		"0.0.0.0:80":           false,
+		"[fd00::1]:80":         false,
+	}
+
+	for address, public := range tests {
+		err := checkAddress(address, allowed)
+		if public {
+			assert.NoError(t, err, address)
+		} else {
+			assert.IsType(t, &AddressError{}, err, address)
+		}
+	}
+}
+
+func TestNewPublicClient(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	_, err := NewPublicClient().Get(server.URL)
+	if assert.Error(t, err) {
+		assert.True(t, isAddressError(err), err.Error())
+		assert.False(t, temporary(err))
+	}
+
+	resp, err := NewPublicClient(&net.IPNet{IP: net.IPv4(127, 0, 0, 0), Mask: net.CIDRMask(8, 32)}).Get(server.URL)
+	if assert.NoError(t, err) {
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	}
+}
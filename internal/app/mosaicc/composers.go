@@ -0,0 +1,97 @@
+package mosaicc
+
+import (
+	"fmt"
+	"github.com/gieseladev/mosaic"
+	"io"
+	"strings"
+)
+
+// ParseOptionArgs parses option arguments of the form "key=value".
+func ParseOptionArgs(args []string) (map[string]string, error) {
+	raw := make(map[string]string, len(args))
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid option %q, expected key=value", arg)
+		}
+
+		raw[parts[0]] = parts[1]
+	}
+
+	return raw, nil
+}
+
+// RegisterLayouts loads the layout files at the given paths, which may
+// also be directories containing layout files, and registers them as
+// composers.
+func RegisterLayouts(paths []string) ([]mosaic.ComposerInfo, error) {
+	var loaded []mosaic.ComposerInfo
+	for _, path := range paths {
+		composers, err := mosaic.LoadLayouts(path)
+		if err != nil {
+			return nil, err
+		}
+
+		loaded = append(loaded, composers...)
+	}
+
+	if err := mosaic.RegisterComposer(loaded...); err != nil {
+		return nil, err
+	}
+
+	return loaded, nil
+}
+
+func formatImageCount(composer mosaic.ComposerInfo) string {
+	if composer.ImageCountHuman != "" {
+		return composer.ImageCountHuman
+	}
+
+	counts := make([]string, len(composer.RecommendedImageCounts))
+	for i, c := range composer.RecommendedImageCounts {
+		counts[i] = fmt.Sprint(c)
+	}
+
+	return strings.Join(counts, ", ")
+}
+
+func formatOption(o mosaic.Option) string {
+	s := fmt.Sprintf("%s (%v, default: %v", o.Name, o.Type, o.Default)
+	if o.HasRange() {
+		s += fmt.Sprintf(", range: %v - %v", o.Min, o.Max)
+	}
+
+	return s + ")"
+}
+
+// WriteComposers writes a human readable description of the composers
+// and their options to w.
+func WriteComposers(w io.Writer, composers []mosaic.ComposerInfo) error {
+	var b strings.Builder
+
+	for _, composer := range composers {
+		_, _ = fmt.Fprintf(&b, "%s: %s\n", composer.Id, composer.Name)
+
+		if composer.Description != "" {
+			_, _ = fmt.Fprintf(&b, "    %s\n", composer.Description)
+		}
+
+		_, _ = fmt.Fprintf(&b, "    images: %s\n", formatImageCount(composer))
+
+		options := composer.AllOptions()
+		if len(options) > 0 {
+			b.WriteString("    options:\n")
+		}
+
+		for _, o := range options {
+			_, _ = fmt.Fprintf(&b, "        %s\n", formatOption(o))
+			if o.Description != "" {
+				_, _ = fmt.Fprintf(&b, "            %s\n", o.Description)
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,348 @@
+package mosaicc
+
+import (
+	"context"
+	"fmt"
+	"github.com/gieseladev/mosaic"
+	"github.com/gieseladev/mosaic/pkg/apng"
+	"github.com/gieseladev/mosaic/pkg/palette"
+	"github.com/gieseladev/mosaic/pkg/webp"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// StdoutPath is the output path which writes the image to stdout.
+const StdoutPath = "-"
+
+// SVGFormat is the format of SVG output. SVGs are written from the layout
+// of a composition, so there's no Encoder for them.
+const SVGFormat = "svg"
+
+// EncodeOptions configure how an image is encoded.
+// Encoders ignore the options which don't apply to their format.
+type EncodeOptions struct {
+	// Quality is the JPEG quality ranging from 1 to 100.
+	Quality int
+	// Colors is the maximum amount of colors in a GIF palette.
+	Colors int
+	// Quantize makes GIF encoding derive the palette from the image
+	// instead of using a fixed palette. Animated GIFs always derive a
+	// palette shared by all frames.
+	Quantize bool
+	// Loops is how often animations are played. 0 loops forever.
+	Loops int
+}
+
+// DefaultEncodeOptions are the options used when none are given.
+var DefaultEncodeOptions = EncodeOptions{
+	Quality: 90,
+	Colors:  256,
+}
+
+// An Encoder writes images in a specific format.
+type Encoder struct {
+	// Name is the name of the format.
+	Name string
+	// Extensions contains the file extensions of the format without the
+	// leading dot. The name is always accepted as well.
+	Extensions  []string
+	ContentType string
+
+	Encode func(w io.Writer, img image.Image, opts EncodeOptions) error
+	// EncodeAnimation is nil if the format doesn't support animations.
+	EncodeAnimation func(w io.Writer, frames []mosaic.AnimationFrame, opts EncodeOptions) error
+}
+
+// matches checks whether the encoder handles the given name or extension.
+func (e Encoder) matches(format string) bool {
+	if format == e.Name {
+		return true
+	}
+
+	for _, ext := range e.Extensions {
+		if format == ext {
+			return true
+		}
+	}
+
+	return false
+}
+
+var registeredEncoders []Encoder
+
+// RegisterEncoder registers a new encoder.
+// Encoders registered later take precedence.
+func RegisterEncoder(e Encoder) {
+	registeredEncoders = append(registeredEncoders, e)
+}
+
+// GetEncoder returns the encoder for the given format name or file
+// extension.
+func GetEncoder(format string) (Encoder, bool) {
+	format = strings.TrimPrefix(strings.ToLower(format), ".")
+
+	for i := len(registeredEncoders) - 1; i >= 0; i-- {
+		if e := registeredEncoders[i]; e.matches(format) {
+			return e, true
+		}
+	}
+
+	return Encoder{}, false
+}
+
+// GetEncoderNames returns the sorted names of all registered encoders.
+func GetEncoderNames() []string {
+	seen := make(map[string]bool, len(registeredEncoders))
+	names := make([]string, 0, len(registeredEncoders))
+	for _, e := range registeredEncoders {
+		if !seen[e.Name] {
+			seen[e.Name] = true
+			names = append(names, e.Name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// ResolveEncoder returns the encoder for the explicit format, or if it's
+// empty, the encoder matching the extension of the path.
+// PNG is used when writing to stdout without a format.
+func ResolveEncoder(format, path string) (Encoder, error) {
+	if format == "" {
+		if path == StdoutPath {
+			format = "png"
+		} else if format = filepath.Ext(path); format == "" {
+			return Encoder{}, fmt.Errorf("can't determine format of %q, use an extension or specify the format", path)
+		}
+	}
+
+	e, ok := GetEncoder(format)
+	if !ok {
+		return Encoder{}, fmt.Errorf("unsupported format %q (supported: %s)", format, strings.Join(GetEncoderNames(), ", "))
+	}
+
+	return e, nil
+}
+
+// writeOutput creates the file at the path, or uses stdout if the path is
+// StdoutPath, and passes it to write.
+func writeOutput(path string, write func(w io.Writer) error) error {
+	if path == StdoutPath {
+		return write(os.Stdout)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// SaveImage encodes the image and writes it to the path, or to stdout if
+// the path is StdoutPath.
+func SaveImage(path string, e Encoder, img image.Image, opts EncodeOptions) error {
+	return writeOutput(path, func(w io.Writer) error {
+		return e.Encode(w, img, opts)
+	})
+}
+
+// SaveAnimation encodes the frames as an animation and writes them to the
+// path, or to stdout if the path is StdoutPath.
+func SaveAnimation(path string, e Encoder, frames []mosaic.AnimationFrame, opts EncodeOptions) error {
+	if e.EncodeAnimation == nil {
+		return fmt.Errorf("format %q doesn't support animations", e.Name)
+	}
+
+	return writeOutput(path, func(w io.Writer) error {
+		return e.EncodeAnimation(w, frames, opts)
+	})
+}
+
+// isFormat checks whether the explicit format, or if it's empty, the
+// extension of the path selects the format with the given name.
+func isFormat(name, format, path string) bool {
+	if format == "" && path != StdoutPath {
+		format = filepath.Ext(path)
+	}
+
+	return strings.TrimPrefix(strings.ToLower(format), ".") == name
+}
+
+// IsSVG checks whether the explicit format, or if it's empty, the
+// extension of the path selects SVGFormat.
+func IsSVG(format, path string) bool {
+	return isFormat(SVGFormat, format, path)
+}
+
+// IsPDF checks whether the explicit format, or if it's empty, the
+// extension of the path selects PDFFormat.
+func IsPDF(format, path string) bool {
+	return isFormat(PDFFormat, format, path)
+}
+
+// SaveSVG writes the composition as an SVG document to the path, or to
+// stdout if the path is StdoutPath.
+func SaveSVG(ctx context.Context, path string, composer mosaic.ComposerInfo, width, height int, opts mosaic.Options, images ...image.Image) error {
+	return writeOutput(path, func(w io.Writer) error {
+		return composer.ComposeSVG(ctx, w, width, height, opts, images...)
+	})
+}
+
+// IsFramePattern checks whether the path contains a verb like %03d which
+// is replaced by the index of a frame.
+func IsFramePattern(path string) bool {
+	first := fmt.Sprintf(path, 0)
+	// invalid and missing verbs are reported in the result
+	return !strings.Contains(first, "%!") && first != fmt.Sprintf(path, 1)
+}
+
+// SaveFrames encodes every frame as a separate image and writes it to the
+// path given by the pattern, see IsFramePattern.
+func SaveFrames(pattern string, e Encoder, frames []mosaic.AnimationFrame, opts EncodeOptions) error {
+	if !IsFramePattern(pattern) {
+		return fmt.Errorf("path %q must contain a verb like %%03d for the frame index", pattern)
+	}
+
+	for i, frame := range frames {
+		if err := SaveImage(fmt.Sprintf(pattern, i), e, frame.Image, opts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func encodeJPEG(w io.Writer, img image.Image, opts EncodeOptions) error {
+	quality := opts.Quality
+	if quality <= 0 {
+		quality = DefaultEncodeOptions.Quality
+	}
+
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+}
+
+func encodeGIF(w io.Writer, img image.Image, opts EncodeOptions) error {
+	gifOpts := gif.Options{
+		NumColors: opts.Colors,
+		Drawer:    draw.FloydSteinberg,
+	}
+
+	if gifOpts.NumColors <= 0 {
+		gifOpts.NumColors = DefaultEncodeOptions.Colors
+	}
+
+	if opts.Quantize {
+		gifOpts.Quantizer = palette.MedianCut{}
+	}
+
+	return gif.Encode(w, img, &gifOpts)
+}
+
+func encodeGIFAnimation(w io.Writer, frames []mosaic.AnimationFrame, opts EncodeOptions) error {
+	colors := opts.Colors
+	if colors <= 0 {
+		colors = DefaultEncodeOptions.Colors
+	}
+
+	images := make([]image.Image, len(frames))
+	for i, frame := range frames {
+		images[i] = frame.Image
+	}
+
+	// a shared palette keeps the colors from flickering between frames
+	p := palette.Shared(colors, images...)
+
+	// GIF counts the repetitions after the first play, -1 plays it once
+	var anim gif.GIF
+	switch {
+	case opts.Loops == 1:
+		anim.LoopCount = -1
+	case opts.Loops > 1:
+		anim.LoopCount = opts.Loops - 1
+	}
+
+	for _, frame := range frames {
+		b := frame.Image.Bounds()
+		paletted := image.NewPaletted(b, p)
+		draw.FloydSteinberg.Draw(paletted, b, frame.Image, b.Min)
+
+		anim.Image = append(anim.Image, paletted)
+		anim.Delay = append(anim.Delay, int(frame.Delay/(10*time.Millisecond)))
+	}
+
+	return gif.EncodeAll(w, &anim)
+}
+
+func encodeAPNG(w io.Writer, frames []mosaic.AnimationFrame, opts EncodeOptions) error {
+	anim := apng.APNG{LoopCount: opts.Loops}
+	for _, frame := range frames {
+		anim.Image = append(anim.Image, frame.Image)
+		anim.Delay = append(anim.Delay, frame.Delay)
+	}
+
+	return apng.EncodeAll(w, &anim)
+}
+
+func init() {
+	RegisterEncoder(Encoder{
+		Name:        "png",
+		Extensions:  []string{"apng"},
+		ContentType: "image/png",
+		Encode: func(w io.Writer, img image.Image, _ EncodeOptions) error {
+			return png.Encode(w, img)
+		},
+		EncodeAnimation: encodeAPNG,
+	})
+	RegisterEncoder(Encoder{
+		Name:        "jpeg",
+		Extensions:  []string{"jpg"},
+		ContentType: "image/jpeg",
+		Encode:      encodeJPEG,
+	})
+	RegisterEncoder(Encoder{
+		Name:            "gif",
+		ContentType:     "image/gif",
+		Encode:          encodeGIF,
+		EncodeAnimation: encodeGIFAnimation,
+	})
+	RegisterEncoder(Encoder{
+		Name:        "webp",
+		ContentType: "image/webp",
+		Encode: func(w io.Writer, img image.Image, _ EncodeOptions) error {
+			return webp.Encode(w, img)
+		},
+	})
+	RegisterEncoder(Encoder{
+		Name:        "tiff",
+		Extensions:  []string{"tif"},
+		ContentType: "image/tiff",
+		Encode: func(w io.Writer, img image.Image, _ EncodeOptions) error {
+			return tiff.Encode(w, img, &tiff.Options{Compression: tiff.Deflate})
+		},
+	})
+	RegisterEncoder(Encoder{
+		Name:        "bmp",
+		ContentType: "image/bmp",
+		Encode: func(w io.Writer, img image.Image, _ EncodeOptions) error {
+			return bmp.Encode(w, img)
+		},
+	})
+}
@@ -0,0 +1,145 @@
+package mosaicc
+
+import (
+	"bytes"
+	"github.com/gieseladev/mosaic"
+	"github.com/stretchr/testify/assert"
+	_ "golang.org/x/image/webp"
+	"image"
+	"image/color"
+	"image/gif"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResolveEncoder(t *testing.T) {
+	tests := map[[2]string]string{
+		{"", "cover.jpg"}:    "jpeg",
+		{"", "cover.JPEG"}:   "jpeg",
+		{"", "cover.tif"}:    "tiff",
+		{"", StdoutPath}:     "png",
+		{"gif", "cover"}:     "gif",
+		{"webp", "a.png"}:    "webp",
+		{".bmp", StdoutPath}: "bmp",
+	}
+
+	for args, expected := range tests {
+		e, err := ResolveEncoder(args[0], args[1])
+		if assert.NoError(t, err, args) {
+			assert.Equal(t, expected, e.Name, args)
+		}
+	}
+
+	_, err := ResolveEncoder("", "cover")
+	assert.Error(t, err)
+
+	_, err = ResolveEncoder("xyz", "cover.png")
+	assert.Error(t, err)
+}
+
+func TestEncoders(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 8))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7)
+	}
+
+	for _, name := range GetEncoderNames() {
+		e, _ := GetEncoder(name)
+
+		var buf bytes.Buffer
+		if !assert.NoError(t, e.Encode(&buf, img, DefaultEncodeOptions), name) {
+			continue
+		}
+
+		decoded, format, err := image.Decode(&buf)
+		if assert.NoError(t, err, name) {
+			assert.Equal(t, name, format)
+			assert.Equal(t, img.Bounds(), decoded.Bounds(), name)
+		}
+	}
+}
+
+func TestEncodeGIF_Quantize(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for i := 0; i < len(img.Pix); i += 4 {
+		copy(img.Pix[i:], []byte{200, 100, 50, 255})
+	}
+
+	var buf bytes.Buffer
+	err := encodeGIF(&buf, img, EncodeOptions{Colors: 4, Quantize: true})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	decoded, err := gif.Decode(&buf)
+	if assert.NoError(t, err) {
+		assert.Equal(t, color.RGBA{200, 100, 50, 255}, color.RGBAModel.Convert(decoded.At(3, 3)))
+	}
+}
+
+func TestEncodeGIFAnimation(t *testing.T) {
+	red, blue := image.NewNRGBA(image.Rect(0, 0, 4, 4)), image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for i := 0; i < len(red.Pix); i += 4 {
+		copy(red.Pix[i:], []byte{255, 0, 0, 255})
+		copy(blue.Pix[i:], []byte{0, 0, 255, 255})
+	}
+
+	frames := []mosaic.AnimationFrame{
+		{Image: red, Delay: time.Second},
+		{Image: blue, Delay: 250 * time.Millisecond},
+	}
+
+	for loops, loopCount := range map[int]int{0: 0, 1: -1, 3: 2} {
+		var buf bytes.Buffer
+		if !assert.NoError(t, encodeGIFAnimation(&buf, frames, EncodeOptions{Loops: loops})) {
+			return
+		}
+
+		decoded, err := gif.DecodeAll(&buf)
+		if assert.NoError(t, err) && assert.Len(t, decoded.Image, 2) {
+			assert.Equal(t, []int{100, 25}, decoded.Delay)
+			assert.Equal(t, loopCount, decoded.LoopCount)
+			assert.Equal(t, color.RGBA{0, 0, 255, 255}, color.RGBAModel.Convert(decoded.Image[1].At(2, 2)))
+		}
+	}
+}
+
+func TestSaveAnimation(t *testing.T) {
+	e, _ := GetEncoder("jpeg")
+	assert.Error(t, SaveAnimation(StdoutPath, e, nil, DefaultEncodeOptions))
+}
+
+func TestSaveFrames(t *testing.T) {
+	assert.True(t, IsFramePattern("frame-%03d.png"))
+	assert.False(t, IsFramePattern("frame.png"))
+
+	dir, err := ioutil.TempDir("", "mosaic-frames")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	frames := []mosaic.AnimationFrame{{Image: img}, {Image: img}}
+
+	e, _ := GetEncoder("png")
+	pattern := filepath.Join(dir, "frame-%02d.png")
+	if assert.NoError(t, SaveFrames(pattern, e, frames, DefaultEncodeOptions)) {
+		assert.FileExists(t, filepath.Join(dir, "frame-00.png"))
+		assert.FileExists(t, filepath.Join(dir, "frame-01.png"))
+	}
+
+	assert.Error(t, SaveFrames(filepath.Join(dir, "frame.png"), e, frames, DefaultEncodeOptions))
+}
+
+func TestIsSVG(t *testing.T) {
+	assert.True(t, IsSVG("", "cover.SVG"))
+	assert.True(t, IsSVG("svg", StdoutPath))
+	assert.False(t, IsSVG("png", "cover.svg"))
+	assert.False(t, IsSVG("", StdoutPath))
+	assert.True(t, IsPDF("", "poster.pdf"))
+	assert.False(t, IsPDF("svg", "poster.pdf"))
+}
@@ -0,0 +1,46 @@
+package mosaicc
+
+import (
+	"fmt"
+	"github.com/gieseladev/mosaic"
+	"html"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// WriteImageMap writes an HTML image map with the given name for the
+// layout to w. Each region links to the href of its image.
+func WriteImageMap(w io.Writer, name string, layout mosaic.Layout, hrefs []string) error {
+	var b strings.Builder
+
+	_, _ = fmt.Fprintf(&b, "<map name=\"%s\">\n", html.EscapeString(name))
+
+	// browsers use the first matching area, but later regions are drawn on
+	// top of earlier ones.
+	for i := len(layout.Regions) - 1; i >= 0; i-- {
+		region := layout.Regions[i]
+		if region.Image >= len(hrefs) {
+			return fmt.Errorf("no href for image %d", region.Image)
+		}
+
+		vertices := region.Shape.Outline().Vertices
+		coords := make([]string, 0, 2*len(vertices))
+		for _, v := range vertices {
+			coords = append(coords,
+				strconv.Itoa(int(math.Round(v.X))),
+				strconv.Itoa(int(math.Round(v.Y))),
+			)
+		}
+
+		href := html.EscapeString(hrefs[region.Image])
+		_, _ = fmt.Fprintf(&b, "  <area shape=\"poly\" coords=\"%s\" href=\"%s\" alt=\"%s\">\n",
+			strings.Join(coords, ","), href, href)
+	}
+
+	b.WriteString("</map>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
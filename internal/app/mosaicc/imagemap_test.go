@@ -0,0 +1,34 @@
+package mosaicc
+
+import (
+	"github.com/gieseladev/mosaic"
+	"github.com/gieseladev/mosaic/pkg/geom"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestWriteImageMap(t *testing.T) {
+	layout := mosaic.Layout{
+		Width:  20,
+		Height: 10,
+		Regions: []mosaic.Region{
+			{Image: 0, Shape: mosaic.RectShape{Rect: geom.Rect(0, 0, 10, 10)}},
+			{Image: 1, Shape: mosaic.RectShape{Rect: geom.Rect(10, 0, 20, 10)}},
+		},
+	}
+
+	var b strings.Builder
+	err := WriteImageMap(&b, "cover", layout, []string{"a.jpg", "b&c.jpg"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, `<map name="cover">
+  <area shape="poly" coords="10,0,20,0,20,10,10,10" href="b&amp;c.jpg" alt="b&amp;c.jpg">
+  <area shape="poly" coords="0,0,10,0,10,10,0,10" href="a.jpg" alt="a.jpg">
+</map>
+`, b.String())
+
+	assert.Error(t, WriteImageMap(&b, "cover", layout, []string{"a.jpg"}))
+}
@@ -1,72 +1,575 @@
 package mosaicc
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"github.com/fogleman/gg"
+	"github.com/gieseladev/mosaic/pkg/palette"
+	_ "golang.org/x/image/webp"
 	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/url"
+	"os"
 	"sort"
 	"strings"
+	"time"
 )
 
-func loadImageFromURL(u string) (image.Image, error) {
-	resp, err := http.Get(u)
+var (
+	// ErrTooLarge is returned when the encoded image exceeds
+	// Loader.MaxBytes.
+	ErrTooLarge = errors.New("image exceeds the maximum size")
+	// ErrTooManyPixels is returned when the dimensions of the image exceed
+	// Loader.MaxDimension or Loader.MaxPixels.
+	ErrTooManyPixels = errors.New("image exceeds the maximum dimensions")
+	// ErrNotRemote is returned by a Loader which is restricted to remote
+	// images when the location isn't an HTTP URL.
+	ErrNotRemote = errors.New("only http and https urls are allowed")
+)
+
+// Failure policies of a Loader.
+const (
+	// PolicyFail aborts loading as soon as one image can't be loaded.
+	PolicyFail = "fail"
+	// PolicySkip leaves out images which can't be loaded. Loading only
+	// fails if none of the images can be loaded.
+	PolicySkip = "skip"
+	// PolicyPlaceholder replaces images which can't be loaded with a
+	// placeholder.
+	PolicyPlaceholder = "placeholder"
+)
+
+// FailurePolicies contains the names of all failure policies.
+var FailurePolicies = []string{PolicyFail, PolicySkip, PolicyPlaceholder}
+
+// CheckFailurePolicy returns an error if the policy is unknown.
+func CheckFailurePolicy(policy string) error {
+	for _, p := range FailurePolicies {
+		if policy == p {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown failure policy %q", policy)
+}
+
+// A StatusError is returned when a server responds with a status code
+// other than 200.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// A ContentTypeError is returned when a server responds with a content type
+// which isn't an image.
+type ContentTypeError struct {
+	ContentType string
+}
+
+func (e *ContentTypeError) Error() string {
+	return fmt.Sprintf("unexpected content type %q", e.ContentType)
+}
+
+// A LoadError is returned when the image at a location can't be loaded.
+type LoadError struct {
+	Location string
+	Err      error
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("couldn't load image %q: %v", e.Location, e.Err)
+}
+
+// LoadErrors is returned by Loader.LoadAll when some of the images couldn't
+// be loaded. The errors are in the order of the locations.
+type LoadErrors []*LoadError
+
+func (e LoadErrors) Error() string {
+	errStrings := make([]string, len(e))
+	for i, err := range e {
+		errStrings[i] = err.Error()
+	}
+
+	return fmt.Sprintf("some images couldn't be loaded:\n%s", strings.Join(errStrings, "\n"))
+}
+
+// A Loader loads images from URLs and files.
+type Loader struct {
+	// Client is used for remote images. If it's nil http.DefaultClient is
+	// used.
+	Client *http.Client
+	// Timeout limits each request, including reading the body. 0 disables
+	// the timeout.
+	Timeout time.Duration
+
+	// MaxBytes is the maximum size of an encoded image. 0 disables the
+	// limit.
+	MaxBytes int64
+	// MaxDimension is the maximum width and height of an image and
+	// MaxPixels the maximum amount of pixels. They're checked before the
+	// image is decoded. 0 disables the limit.
+	MaxDimension int
+	MaxPixels    int
+
+	// TargetSize is the size of the canvas the images are composed on. If
+	// it's set, larger images are downscaled, see DownscaleToCover.
+	TargetSize image.Point
+
+	// Cache stores remote images on disk. It's optional.
+	Cache *Cache
+
+	// Policy decides how LoadAll handles images which can't be loaded. If
+	// it's empty, the loaded images are returned along with the LoadErrors.
+	Policy string
+	// Placeholder replaces images which couldn't be loaded if the Policy is
+	// PolicyPlaceholder. If it's nil, a placeholder filled with the
+	// dominant color of the other images is used.
+	Placeholder image.Image
+	// OnFailure is called by LoadAll for every image which couldn't be
+	// loaded unless the Policy is PolicyFail, in the order of the locations.
+	OnFailure func(err *LoadError)
+
+	// Workers is the maximum amount of images LoadAll loads at once and
+	// MaxPerHost the maximum amount of concurrent requests to a single
+	// host. 0 disables the limit.
+	Workers    int
+	MaxPerHost int
+	// OnProgress is called by LoadAll whenever an image finished loading.
+	// The calls happen one after another in the order the images finish.
+	OnProgress func(p Progress)
+
+	// Stdin is read for the StdinLocation. If it's nil os.Stdin is used.
+	Stdin io.Reader
+	// RemoteOnly restricts the loader to HTTP URLs. Files, data URIs and
+	// the StdinLocation are rejected with ErrNotRemote.
+	RemoteOnly bool
+
+	// Retries is the amount of times a request is retried after a network
+	// error or a server error. RetryDelay is the delay before the first
+	// retry and doubles for every further retry.
+	Retries    int
+	RetryDelay time.Duration
+}
+
+// NewLoader creates a loader with reasonable limits.
+func NewLoader() *Loader {
+	return &Loader{
+		Timeout: 30 * time.Second,
+
+		MaxBytes:     32 << 20,
+		MaxDimension: 16384,
+		MaxPixels:    64 << 20,
+
+		Workers:    8,
+		MaxPerHost: 4,
+
+		Retries:    2,
+		RetryDelay: 250 * time.Millisecond,
+	}
+}
+
+// DefaultLoader is the loader used by LoadImage and LoadImages.
+var DefaultLoader = NewLoader()
+
+// isRemote checks whether the location is an HTTP URL. Everything else is
+// treated as a file path.
+func isRemote(location string) bool {
+	u, err := url.Parse(location)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// readAll reads at most MaxBytes from the reader.
+func (l *Loader) readAll(r io.Reader) ([]byte, error) {
+	if l.MaxBytes <= 0 {
+		return ioutil.ReadAll(r)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(r, l.MaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > l.MaxBytes {
+		return nil, ErrTooLarge
+	}
+
+	return data, nil
+}
+
+// decode checks the dimensions of the encoded image and decodes it, see
+// DecodeImage.
+func (l *Loader) decode(data []byte) (image.Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if l.MaxDimension > 0 && (config.Width > l.MaxDimension || config.Height > l.MaxDimension) {
+		return nil, ErrTooManyPixels
+	}
+
+	if l.MaxPixels > 0 && config.Width*config.Height > l.MaxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	img, err := DecodeImage(bytes.NewReader(data))
 	if err != nil {
-		return nil, nil
+		return nil, err
 	}
 
-	img, _, err := image.Decode(resp.Body)
-	_ = resp.Body.Close()
-	return img, err
+	return DownscaleToCover(img, l.TargetSize.X, l.TargetSize.Y), nil
 }
 
-// LoadImage loads an image from the given location.
-// The location can be either a url, or a filepath pointing
-// to an image.
-func LoadImage(location string) (image.Image, error) {
-	_, err := url.ParseRequestURI(location)
+// checkContentType makes sure the content type is an image. Missing and
+// generic content types are accepted as the data is sniffed when decoding.
+func checkContentType(contentType string) error {
+	if contentType == "" {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return &ContentTypeError{ContentType: contentType}
+	}
+
+	if strings.HasPrefix(mediaType, "image/") || mediaType == "application/octet-stream" {
+		return nil
+	}
+
+	return &ContentTypeError{ContentType: contentType}
+}
+
+// temporary checks whether a failed request should be retried.
+func temporary(err error) bool {
+	if statusErr, ok := err.(*StatusError); ok {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+
+	_, isURLErr := err.(*url.Error)
+	return isURLErr && !isAddressError(err)
+}
+
+// fetch performs a single request for the URL and returns the body. If a
+// cache is used, fresh entries are returned without a request and stale
+// ones are revalidated. The header is only returned for new responses,
+// which should be cached once they're decoded successfully.
+func (l *Loader) fetch(ctx context.Context, u string) ([]byte, http.Header, error) {
+	var entry CacheEntry
+	var cached []byte
+	var isCached bool
+	if l.Cache != nil {
+		entry, cached, isCached = l.Cache.Get(u)
+		if isCached && entry.Fresh(time.Now()) {
+			return cached, nil, nil
+		}
+	}
+
+	if l.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if isCached {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		} else if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	client := l.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if isCached && resp.StatusCode == http.StatusNotModified {
+		_ = l.Cache.Revalidate(u, resp.Header)
+		return cached, nil, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	if err := checkContentType(resp.Header.Get("Content-Type")); err != nil {
+		return nil, nil, err
+	}
+
+	if l.MaxBytes > 0 && resp.ContentLength > l.MaxBytes {
+		return nil, nil, ErrTooLarge
+	}
+
+	data, err := l.readAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, resp.Header, nil
+}
+
+// loadURL fetches the URL, retrying temporary failures.
+func (l *Loader) loadURL(ctx context.Context, u string) ([]byte, http.Header, error) {
+	delay := l.RetryDelay
+	for attempt := 0; ; attempt++ {
+		data, header, err := l.fetch(ctx, u)
+		if err == nil || attempt >= l.Retries || !temporary(err) {
+			return data, header, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+	}
+}
+
+// loadFile reads the file at the path.
+func (l *Loader) loadFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	return l.readAll(f)
+}
+
+// loadDataURI returns the data of the data URI.
+func (l *Loader) loadDataURI(location string) ([]byte, error) {
+	mediaType, data, err := decodeDataURI(location)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkContentType(mediaType); err != nil {
+		return nil, err
+	}
+
+	if l.MaxBytes > 0 && int64(len(data)) > l.MaxBytes {
+		return nil, ErrTooLarge
+	}
+
+	return data, nil
+}
+
+// Load loads the image at the location, which is either an HTTP URL, a data
+// URI, StdinLocation or a file path. Errors are returned as a *LoadError.
+func (l *Loader) Load(ctx context.Context, location string) (image.Image, error) {
+	var data []byte
+	var header http.Header
+	var err error
+	switch {
+	case l.RemoteOnly && !isRemote(location):
+		err = ErrNotRemote
+	case location == StdinLocation:
+		stdin := l.Stdin
+		if stdin == nil {
+			stdin = os.Stdin
+		}
+
+		data, err = l.readAll(stdin)
+	case isRemote(location):
+		data, header, err = l.loadURL(ctx, location)
+	case isDataURI(location):
+		data, err = l.loadDataURI(location)
+	default:
+		data, err = l.loadFile(location)
+	}
+
+	var img image.Image
 	if err == nil {
-		return loadImageFromURL(location)
-	} else {
-		return gg.LoadImage(location)
+		img, err = l.decode(data)
+	}
+
+	if err != nil {
+		return nil, &LoadError{Location: location, Err: err}
 	}
+
+	// only responses which could be decoded are cached, failing to cache
+	// them doesn't affect loading the image
+	if l.Cache != nil && header != nil {
+		_ = l.Cache.Put(location, header, data)
+	}
+
+	return img, nil
 }
 
-// LoadImages loads the given images in parallel.
-func LoadImages(locations []string) ([]image.Image, error) {
+// placeholderSize is the width and height of generated placeholders.
+const placeholderSize = 64
+
+// placeholderColor is used for generated placeholders if none of the
+// images could be loaded.
+var placeholderColor = color.NRGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff}
+
+// placeholder returns the image used in place of images which couldn't be
+// loaded. Unless a Placeholder is set, it's filled with the dominant color
+// of the loaded images.
+func (l *Loader) placeholder(loaded []image.Image) image.Image {
+	if l.Placeholder != nil {
+		return l.Placeholder
+	}
+
+	c := color.Color(placeholderColor)
+	if dominant := palette.Dominant(1, loaded...); len(dominant) > 0 {
+		c = dominant[0]
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, placeholderSize, placeholderSize))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.ZP, draw.Src)
+	return img
+}
+
+// Progress describes an image which LoadAll finished loading.
+type Progress struct {
+	// Index is the index of the location.
+	Index    int
+	Location string
+	// Err is set if the image couldn't be loaded.
+	Err *LoadError
+
+	// Done is the amount of finished images including this one and Total
+	// the amount of locations.
+	Done, Total int
+}
+
+// hostOf returns the host of remote locations and an empty string for all
+// other locations.
+func hostOf(location string) string {
+	if !isRemote(location) {
+		return ""
+	}
+
+	u, _ := url.Parse(location)
+	return strings.ToLower(u.Host)
+}
+
+// LoadAll loads the images in parallel and returns them in the order of
+// the locations. Failures are handled according to the Policy.
+//
+// At most Workers images are loaded at once, at most MaxPerHost of them
+// from the same host. Locations are started in order, but a location
+// waiting for its host doesn't hold back the ones after it. When the
+// context is cancelled, the outstanding loads are aborted and the error of
+// the context is returned.
+func (l *Loader) LoadAll(ctx context.Context, locations []string) ([]image.Image, error) {
 	type LoadResult struct {
 		Index int
 		Image image.Image
-		Err   error
+		Err   *LoadError
+	}
+
+	if l.Policy != "" {
+		if err := CheckFailurePolicy(l.Policy); err != nil {
+			return nil, err
+		}
 	}
 
-	resultChan := make(chan LoadResult)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	hosts := make([]string, len(locations))
 	for i, location := range locations {
-		go func(i int, location string) {
-			img, err := LoadImage(location)
+		hosts[i] = hostOf(location)
+	}
+
+	// buffered so that abandoned loads don't block
+	resultChan := make(chan LoadResult, len(locations))
+	start := func(i int) {
+		go func() {
+			result := LoadResult{Index: i}
+
+			img, err := l.Load(ctx, locations[i])
 			if err != nil {
-				err = fmt.Errorf("couldn't load image %q: %v", location, err)
+				result.Err = err.(*LoadError)
+			} else {
+				result.Image = img
 			}
 
-			resultChan <- LoadResult{
-				Index: i,
-				Image: img,
-				Err:   err,
+			resultChan <- result
+		}()
+	}
+
+	pending := make([]int, len(locations))
+	for i := range pending {
+		pending[i] = i
+	}
+
+	var running int
+	perHost := make(map[string]int)
+
+	// startPending starts the pending locations in order as long as there
+	// are free workers and their hosts aren't busy.
+	startPending := func() {
+		waiting := pending[:0]
+		for _, i := range pending {
+			host := hosts[i]
+			if (l.Workers > 0 && running >= l.Workers) || (host != "" && l.MaxPerHost > 0 && perHost[host] >= l.MaxPerHost) {
+				waiting = append(waiting, i)
+				continue
 			}
-		}(i, location)
+
+			running++
+			perHost[host]++
+			start(i)
+		}
+
+		pending = waiting
 	}
 
-	errs := make([]error, 0)
 	results := make([]LoadResult, 0, len(locations))
-	for i := 0; i < len(locations); i++ {
-		result := <-resultChan
-		if result.Err == nil {
-			results = append(results, result)
-		} else {
-			errs = append(errs, result.Err)
+	for len(results) < len(locations) {
+		startPending()
+
+		var result LoadResult
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case result = <-resultChan:
+		}
+
+		running--
+		perHost[hosts[result.Index]]--
+
+		if l.OnProgress != nil {
+			l.OnProgress(Progress{
+				Index:    result.Index,
+				Location: locations[result.Index],
+				Err:      result.Err,
+				Done:     len(results) + 1,
+				Total:    len(locations),
+			})
+		}
+
+		if result.Err != nil && l.Policy == PolicyFail {
+			// abort the outstanding loads
+			return nil, LoadErrors{result.Err}
 		}
 
+		results = append(results, result)
 	}
 
 	// preserve original order
@@ -74,20 +577,58 @@ func LoadImages(locations []string) ([]image.Image, error) {
 		return results[i].Index < results[j].Index
 	})
 
-	images := make([]image.Image, len(results))
-	for i, result := range results {
-		images[i] = result.Image
+	var errs LoadErrors
+	images := make([]image.Image, 0, len(results))
+	for _, result := range results {
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+		} else {
+			images = append(images, result.Image)
+		}
 	}
 
-	var combinedErr error
-	if len(errs) > 0 {
-		errStrings := make([]string, len(errs))
-		for i, err := range errs {
-			errStrings[i] = err.Error()
+	if len(errs) == 0 {
+		return images, nil
+	}
+
+	if l.OnFailure != nil {
+		for _, err := range errs {
+			l.OnFailure(err)
 		}
+	}
 
-		combinedErr = fmt.Errorf("some images couldn't be loaded:\n%s", strings.Join(errStrings, "\n"))
+	switch l.Policy {
+	case PolicySkip:
+		if len(images) == 0 {
+			return nil, errs
+		}
+
+		return images, nil
+	case PolicyPlaceholder:
+		placeholder := l.placeholder(images)
+
+		all := make([]image.Image, len(results))
+		for i, result := range results {
+			if result.Err != nil {
+				all[i] = placeholder
+			} else {
+				all[i] = result.Image
+			}
+		}
+
+		return all, nil
+	default:
+		return images, errs
 	}
+}
 
-	return images, combinedErr
+// LoadImage loads an image from the given location using the
+// DefaultLoader, see Loader.Load.
+func LoadImage(location string) (image.Image, error) {
+	return DefaultLoader.Load(context.Background(), location)
+}
+
+// LoadImages loads the given images in parallel using the DefaultLoader.
+func LoadImages(locations []string) ([]image.Image, error) {
+	return DefaultLoader.LoadAll(context.Background(), locations)
 }
@@ -0,0 +1,287 @@
+package mosaicc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func encodeTestPNG(w, h int) []byte {
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, w, h)))
+	return buf.Bytes()
+}
+
+func TestLoader_Load(t *testing.T) {
+	small := encodeTestPNG(4, 4)
+	large := encodeTestPNG(64, 8)
+
+	var flakyCalls int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/small.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(small)
+	})
+	mux.HandleFunc("/large.png", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(large)
+	})
+	mux.HandleFunc("/page.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write(small)
+	})
+	mux.HandleFunc("/flaky.png", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&flakyCalls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		_, _ = w.Write(small)
+	})
+	mux.HandleFunc("/slow.png", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	l := NewLoader()
+	l.RetryDelay = time.Millisecond
+	l.Timeout = 50 * time.Millisecond
+	l.MaxDimension = 32
+
+	load := func(location string) error {
+		_, err := l.Load(context.Background(), location)
+		return err
+	}
+
+	img, err := l.Load(context.Background(), server.URL+"/small.png")
+	if assert.NoError(t, err) {
+		assert.Equal(t, image.Rect(0, 0, 4, 4), img.Bounds())
+	}
+
+	assert.NoError(t, load(server.URL+"/flaky.png"))
+	assert.Equal(t, int32(3), flakyCalls)
+
+	tests := map[string]error{
+		"/missing.png": &StatusError{StatusCode: http.StatusNotFound},
+		"/page.html":   &ContentTypeError{ContentType: "text/html"},
+		"/large.png":   ErrTooManyPixels,
+	}
+
+	for path, expected := range tests {
+		assert.Equal(t, &LoadError{Location: server.URL + path, Err: expected}, load(server.URL+path), path)
+	}
+
+	err = load(server.URL + "/slow.png")
+	if assert.IsType(t, &LoadError{}, err) {
+		assert.Equal(t, server.URL+"/slow.png", err.(*LoadError).Location)
+	}
+
+	l.MaxBytes = 16
+	assert.Equal(t, &LoadError{Location: server.URL + "/small.png", Err: ErrTooLarge}, load(server.URL+"/small.png"))
+}
+
+func TestLoader_LoadFile(t *testing.T) {
+	path, err := filepath.Abs(filepath.Join(testInputDir, testImageNames[0]))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	img, err := LoadImage(path)
+	if assert.NoError(t, err) {
+		assert.False(t, img.Bounds().Empty())
+		assert.IsType(t, &image.NRGBA{}, img)
+	}
+
+	l := NewLoader()
+	l.TargetSize = image.Pt(32, 32)
+	img, err = l.Load(context.Background(), path)
+	if assert.NoError(t, err) {
+		b := img.Bounds()
+		assert.True(t, b.Dx() == 32 || b.Dy() == 32, "not downscaled: %v", b)
+	}
+
+	_, err = LoadImage(filepath.Join(testInputDir, "missing.jpg"))
+	assert.IsType(t, &LoadError{}, err)
+}
+
+func TestLoader_RemoteOnly(t *testing.T) {
+	l := NewLoader()
+	l.RemoteOnly = true
+
+	for _, location := range []string{
+		filepath.Join(testInputDir, testImageNames[0]),
+		"http:" + filepath.Join(testInputDir, testImageNames[0]),
+		"data:image/png;base64,",
+		StdinLocation,
+	} {
+		_, err := l.Load(context.Background(), location)
+		if assert.IsType(t, &LoadError{}, err, location) {
+			assert.Equal(t, ErrNotRemote, err.(*LoadError).Err, location)
+		}
+	}
+}
+
+func TestLoader_LoadAll(t *testing.T) {
+	locations := []string{
+		filepath.Join(testInputDir, "missing-a.jpg"),
+		filepath.Join(testInputDir, testImageNames[0]),
+		filepath.Join(testInputDir, "missing-b.jpg"),
+		filepath.Join(testInputDir, testImageNames[1]),
+	}
+
+	images, err := LoadImages(locations)
+	assert.Len(t, images, 2)
+	if assert.IsType(t, LoadErrors{}, err) {
+		assert.Len(t, err.(LoadErrors), 2)
+	}
+
+	l := NewLoader()
+	l.Policy = PolicyFail
+	images, err = l.LoadAll(context.Background(), locations)
+	assert.Nil(t, images)
+	if assert.IsType(t, LoadErrors{}, err) {
+		assert.Len(t, err.(LoadErrors), 1)
+	}
+
+	l.Policy = PolicySkip
+
+	var failed []string
+	l.OnFailure = func(err *LoadError) {
+		failed = append(failed, err.Location)
+	}
+
+	images, err = l.LoadAll(context.Background(), locations)
+	assert.NoError(t, err)
+	assert.Len(t, images, 2)
+	assert.Equal(t, []string{locations[0], locations[2]}, failed)
+
+	_, err = l.LoadAll(context.Background(), locations[:1])
+	assert.IsType(t, LoadErrors{}, err)
+
+	l.Policy = PolicyPlaceholder
+	images, err = l.LoadAll(context.Background(), locations)
+	if assert.NoError(t, err) && assert.Len(t, images, 4) {
+		assert.Equal(t, image.Rect(0, 0, placeholderSize, placeholderSize), images[0].Bounds())
+		assert.Equal(t, images[0], images[2])
+	}
+
+	l.Placeholder = image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	images, err = l.LoadAll(context.Background(), locations[:1])
+	if assert.NoError(t, err) && assert.Len(t, images, 1) {
+		assert.Equal(t, l.Placeholder, images[0])
+	}
+
+	l.Policy = "ignore"
+	_, err = l.LoadAll(context.Background(), locations)
+	assert.Error(t, err)
+}
+
+// concurrencyServer serves a small image and records the maximum amount of
+// concurrent requests.
+type concurrencyServer struct {
+	*httptest.Server
+	active, max *int32
+}
+
+func newConcurrencyServer(total, totalMax *int32) concurrencyServer {
+	s := concurrencyServer{active: new(int32), max: new(int32)}
+	small := encodeTestPNG(2, 2)
+
+	updateMax := func(max *int32, v int32) {
+		for {
+			old := atomic.LoadInt32(max)
+			if v <= old || atomic.CompareAndSwapInt32(max, old, v) {
+				return
+			}
+		}
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		updateMax(s.max, atomic.AddInt32(s.active, 1))
+		updateMax(totalMax, atomic.AddInt32(total, 1))
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(s.active, -1)
+		atomic.AddInt32(total, -1)
+
+		_, _ = w.Write(small)
+	}))
+
+	return s
+}
+
+func TestLoader_LoadAllConcurrency(t *testing.T) {
+	var total, totalMax int32
+	a := newConcurrencyServer(&total, &totalMax)
+	defer a.Close()
+	b := newConcurrencyServer(&total, &totalMax)
+	defer b.Close()
+
+	var locations []string
+	for i := 0; i < 6; i++ {
+		locations = append(locations, fmt.Sprintf("%s/%d.png", a.URL, i))
+	}
+	for i := 0; i < 6; i++ {
+		locations = append(locations, fmt.Sprintf("%s/%d.png", b.URL, i))
+	}
+
+	l := NewLoader()
+	l.Workers = 4
+	l.MaxPerHost = 2
+
+	var progress []Progress
+	l.OnProgress = func(p Progress) {
+		progress = append(progress, p)
+	}
+
+	images, err := l.LoadAll(context.Background(), locations)
+	assert.NoError(t, err)
+	assert.Len(t, images, len(locations))
+
+	assert.True(t, *a.max <= 2 && *b.max <= 2, "per host limit exceeded")
+	assert.True(t, totalMax <= 4, "worker limit exceeded")
+	// hosts are loaded in parallel even though the locations are ordered
+	assert.True(t, totalMax > 2, "hosts weren't loaded in parallel")
+
+	if assert.Len(t, progress, len(locations)) {
+		seen := make(map[int]bool)
+		for i, p := range progress {
+			assert.Equal(t, i+1, p.Done)
+			assert.Equal(t, len(locations), p.Total)
+			assert.Equal(t, locations[p.Index], p.Location)
+			seen[p.Index] = true
+		}
+
+		assert.Len(t, seen, len(locations))
+	}
+}
+
+func TestLoader_LoadAllCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	l := NewLoader()
+	l.Policy = PolicySkip
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := l.LoadAll(ctx, []string{server.URL + "/a.png", server.URL + "/b.png"})
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.True(t, time.Since(start) < time.Second)
+}
@@ -0,0 +1,50 @@
+package mosaicc
+
+import (
+	"github.com/disintegration/imaging"
+	"image"
+	"io"
+	"math"
+)
+
+// DecodeImage decodes an image, rotates it according to its EXIF
+// orientation and converts it to NRGBA.
+func DecodeImage(r io.Reader) (*image.NRGBA, error) {
+	img, err := imaging.Decode(r, imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, err
+	}
+
+	return ToNRGBA(img), nil
+}
+
+// ToNRGBA converts the image to NRGBA with its bounds starting at the
+// origin. This way composers don't have to deal with CMYK, grayscale or
+// paletted images. Images which already are NRGBA are returned as is.
+func ToNRGBA(img image.Image) *image.NRGBA {
+	if nrgba, ok := img.(*image.NRGBA); ok && nrgba.Rect.Min == image.ZP {
+		return nrgba
+	}
+
+	return imaging.Clone(img)
+}
+
+// DownscaleToCover downscales the image to the smallest size which still
+// covers a canvas of the given size while keeping its aspect ratio.
+// Composers never draw an image larger than that, so the result looks the
+// same. Images which are already small enough are returned as is.
+func DownscaleToCover(img *image.NRGBA, width, height int) *image.NRGBA {
+	b := img.Bounds()
+	if width <= 0 || height <= 0 || b.Empty() {
+		return img
+	}
+
+	scale := math.Max(float64(width)/float64(b.Dx()), float64(height)/float64(b.Dy()))
+	if scale >= 1 {
+		return img
+	}
+
+	w := int(math.Ceil(float64(b.Dx()) * scale))
+	h := int(math.Ceil(float64(b.Dy()) * scale))
+	return imaging.Resize(img, w, h, imaging.Linear)
+}
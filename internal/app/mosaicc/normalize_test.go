@@ -0,0 +1,77 @@
+package mosaicc
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// encodeTestJPEG encodes the image as JPEG with an EXIF orientation.
+func encodeTestJPEG(img image.Image, orientation byte) []byte {
+	var buf bytes.Buffer
+	_ = jpeg.Encode(&buf, img, nil)
+	data := buf.Bytes()
+
+	exif := []byte("Exif\x00\x00" +
+		// big endian TIFF header with the IFD at offset 8
+		"MM\x00\x2a\x00\x00\x00\x08" +
+		// one entry: orientation, short, count 1
+		"\x00\x01" + "\x01\x12\x00\x03\x00\x00\x00\x01\x00" + string(orientation) + "\x00\x00" +
+		// no next IFD
+		"\x00\x00\x00\x00")
+
+	segment := append([]byte{0xff, 0xe1, byte((len(exif) + 2) >> 8), byte(len(exif) + 2)}, exif...)
+
+	// insert the segment right after the start of image marker
+	return append(append(append([]byte{}, data[:2]...), segment...), data[2:]...)
+}
+
+func TestDecodeImage(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 40, 20))
+	// white left half
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			src.SetGray(x, y, color.Gray{Y: 0xff})
+		}
+	}
+
+	img, err := DecodeImage(bytes.NewReader(encodeTestJPEG(src, 1)))
+	if assert.NoError(t, err) {
+		assert.Equal(t, image.Rect(0, 0, 40, 20), img.Bounds())
+	}
+
+	// rotated 90° clockwise, so the white half is at the top
+	img, err = DecodeImage(bytes.NewReader(encodeTestJPEG(src, 6)))
+	if assert.NoError(t, err) {
+		assert.Equal(t, image.Rect(0, 0, 20, 40), img.Bounds())
+		assert.True(t, img.NRGBAAt(10, 5).R > 0xf0)
+		assert.True(t, img.NRGBAAt(10, 35).R < 0x10)
+	}
+
+	_, err = DecodeImage(bytes.NewReader([]byte("not an image")))
+	assert.Error(t, err)
+}
+
+func TestToNRGBA(t *testing.T) {
+	nrgba := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	assert.True(t, nrgba == ToNRGBA(nrgba))
+
+	cmyk := image.NewCMYK(image.Rect(1, 1, 3, 3))
+	cmyk.SetCMYK(1, 1, color.CMYK{C: 0xff})
+
+	converted := ToNRGBA(cmyk)
+	assert.Equal(t, image.Rect(0, 0, 2, 2), converted.Bounds())
+	assert.Equal(t, color.NRGBA{G: 0xff, B: 0xff, A: 0xff}, converted.NRGBAAt(0, 0))
+}
+
+func TestDownscaleToCover(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 400, 200))
+
+	assert.Equal(t, image.Rect(0, 0, 200, 100), DownscaleToCover(img, 100, 100).Bounds())
+	assert.Equal(t, image.Rect(0, 0, 200, 100), DownscaleToCover(img, 200, 50).Bounds())
+	assert.True(t, img == DownscaleToCover(img, 500, 100))
+	assert.True(t, img == DownscaleToCover(img, 0, 0))
+}
@@ -0,0 +1,202 @@
+package mosaicc
+
+import (
+	"context"
+	"fmt"
+	"github.com/gieseladev/mosaic"
+	"github.com/gieseladev/mosaic/pkg/geom"
+	"github.com/gieseladev/mosaic/pkg/pdf"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// PDFFormat is the format of PDF output. Like SVGs, PDFs are written from
+// the layout of a composition.
+const PDFFormat = "pdf"
+
+// MillimetresPerInch is the amount of millimetres in an inch.
+const MillimetresPerInch = 25.4
+
+// Crop marks start cropMarkOffset millimetres away from the trimmed print,
+// or outside of the bleed if it's larger.
+const (
+	cropMarkOffset = 3.
+	cropMarkLength = 5.
+	// cropMarkWidth is the line width of the crop marks in points.
+	cropMarkWidth = .25
+)
+
+// A PaperSize is the size of a sheet of paper in millimetres.
+type PaperSize struct {
+	Name          string
+	Width, Height float64
+}
+
+// PaperSizes contains the paper sizes known by ParsePaperSize.
+var PaperSizes = []PaperSize{
+	{"A0", 841, 1189},
+	{"A1", 594, 841},
+	{"A2", 420, 594},
+	{"A3", 297, 420},
+	{"A4", 210, 297},
+	{"A5", 148, 210},
+	{"A6", 105, 148},
+	{"letter", 215.9, 279.4},
+	{"legal", 215.9, 355.6},
+	{"tabloid", 279.4, 431.8},
+}
+
+// GetPaperSizeNames returns the names of all paper sizes.
+func GetPaperSizeNames() []string {
+	names := make([]string, len(PaperSizes))
+	for i, size := range PaperSizes {
+		names[i] = size.Name
+	}
+
+	return names
+}
+
+// ParsePaperSize returns the width and height in millimetres of the paper
+// size with the given name, which is case insensitive, or of explicit
+// dimensions like "300x200".
+func ParsePaperSize(value string) (float64, float64, error) {
+	for _, size := range PaperSizes {
+		if strings.EqualFold(value, size.Name) {
+			return size.Width, size.Height, nil
+		}
+	}
+
+	parts := strings.Split(strings.ToLower(value), "x")
+	if len(parts) == 2 {
+		w, errW := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		h, errH := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if errW == nil && errH == nil && w > 0 && h > 0 {
+			return w, h, nil
+		}
+	}
+
+	return 0, 0, fmt.Errorf("invalid paper size %q, use WIDTHxHEIGHT in millimetres or one of %s", value, strings.Join(GetPaperSizeNames(), ", "))
+}
+
+// PrintOptions describe the physical size of a printed composition.
+type PrintOptions struct {
+	// Width and Height are the size of the trimmed print in millimetres.
+	Width, Height float64
+	// DPI is the resolution the composition is laid out at.
+	DPI float64
+	// Bleed is how far the composition extends beyond the trimmed size
+	// in millimetres.
+	Bleed float64
+	// CropMarks adds marks around the print showing where to trim it.
+	CropMarks bool
+}
+
+// Validate checks whether the options describe a printable page.
+func (p PrintOptions) Validate() error {
+	if p.Width <= 0 || p.Height <= 0 {
+		return fmt.Errorf("print size must be positive")
+	}
+
+	if p.DPI <= 0 {
+		return fmt.Errorf("DPI must be positive")
+	}
+
+	if p.Bleed < 0 {
+		return fmt.Errorf("bleed must not be negative")
+	}
+
+	return nil
+}
+
+// PixelSize returns the size of the composition including the bleed in
+// pixels.
+func (p PrintOptions) PixelSize() (int, int) {
+	toPixels := func(mm float64) int {
+		return int(math.Max(1, math.Round((mm+2*p.Bleed)/MillimetresPerInch*p.DPI)))
+	}
+
+	return toPixels(p.Width), toPixels(p.Height)
+}
+
+// points converts millimetres to points.
+func points(mm float64) float64 {
+	return mm / MillimetresPerInch * pdf.PointsPerInch
+}
+
+// margin returns the space around the trimmed print in millimetres.
+func (p PrintOptions) margin() float64 {
+	if !p.CropMarks {
+		return p.Bleed
+	}
+
+	return math.Max(p.Bleed, cropMarkOffset) + cropMarkLength
+}
+
+// Page creates the page for the print with the trim and bleed box set.
+func (p PrintOptions) Page() *pdf.Page {
+	margin := points(p.margin())
+	width, height := points(p.Width), points(p.Height)
+
+	page := pdf.NewPage(width+2*margin, height+2*margin)
+	page.TrimBox = geom.Rect(margin, margin, margin+width, margin+height)
+	page.BleedBox = page.TrimBox.Inset(-points(p.Bleed))
+
+	if p.CropMarks {
+		drawCropMarks(page, page.TrimBox, points(math.Max(p.Bleed, cropMarkOffset)), points(cropMarkLength))
+	}
+
+	return page
+}
+
+// drawCropMarks draws lines extending the edges of the trim box at each
+// corner.
+func drawCropMarks(page *pdf.Page, trim geom.Rectangle, offset, length float64) {
+	page.SetColor(color.Black)
+	page.SetLineWidth(cropMarkWidth)
+
+	for _, corner := range trim.Vertices() {
+		dx, dy := 1., 1.
+		if corner.X == trim.Min.X {
+			dx = -1
+		}
+		if corner.Y == trim.Min.Y {
+			dy = -1
+		}
+
+		page.MoveTo(corner.Add(geom.Pt(dx*offset, 0)))
+		page.LineTo(corner.Add(geom.Pt(dx*(offset+length), 0)))
+		page.MoveTo(corner.Add(geom.Pt(0, dy*offset)))
+		page.LineTo(corner.Add(geom.Pt(0, dy*(offset+length))))
+	}
+
+	page.Stroke()
+}
+
+// WritePDF writes the composition as a PDF document to w. The composition
+// is laid out at the PixelSize of the print options and covers the bleed
+// box of the page.
+func WritePDF(ctx context.Context, w io.Writer, composer mosaic.ComposerInfo, print PrintOptions, opts mosaic.Options, images ...image.Image) error {
+	if err := print.Validate(); err != nil {
+		return err
+	}
+
+	page := print.Page()
+	width, height := print.PixelSize()
+	if err := composer.ComposePDF(ctx, page, page.BleedBox, width, height, opts, images...); err != nil {
+		return err
+	}
+
+	return page.Encode(w)
+}
+
+// SavePDF writes the composition as a PDF document to the path, or to
+// stdout if the path is StdoutPath.
+func SavePDF(ctx context.Context, path string, composer mosaic.ComposerInfo, print PrintOptions, opts mosaic.Options, images ...image.Image) error {
+	return writeOutput(path, func(w io.Writer) error {
+		return WritePDF(ctx, w, composer, print, opts, images...)
+	})
+}
@@ -0,0 +1,68 @@
+package mosaicc
+
+import (
+	"bytes"
+	"context"
+	"github.com/gieseladev/mosaic"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestParsePaperSize(t *testing.T) {
+	w, h, err := ParsePaperSize("a3")
+	if assert.NoError(t, err) {
+		assert.Equal(t, [2]float64{297, 420}, [2]float64{w, h})
+	}
+
+	w, h, err = ParsePaperSize("300x 200.5")
+	if assert.NoError(t, err) {
+		assert.Equal(t, [2]float64{300, 200.5}, [2]float64{w, h})
+	}
+
+	for _, invalid := range []string{"", "A9", "300", "0x10", "ax3"} {
+		_, _, err = ParsePaperSize(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestPrintOptions_PixelSize(t *testing.T) {
+	print := PrintOptions{Width: 210, Height: 297, DPI: 300}
+	w, h := print.PixelSize()
+	assert.Equal(t, [2]int{2480, 3508}, [2]int{w, h})
+
+	print.Bleed = 3
+	w, h = print.PixelSize()
+	assert.Equal(t, [2]int{2551, 3579}, [2]int{w, h})
+}
+
+func TestPrintOptions_Page(t *testing.T) {
+	page := PrintOptions{Width: 25.4, Height: 50.8, DPI: 72, Bleed: 2.54}.Page()
+	assert.InDelta(t, 72+2*7.2, page.Width, 1e-9)
+	assert.InDelta(t, 7.2, page.TrimBox.Min.X, 1e-9)
+	assert.InDelta(t, 0, page.BleedBox.Min.X, 1e-9)
+
+	// the crop marks are drawn outside of the bleed
+	page = PrintOptions{Width: 25.4, Height: 50.8, DPI: 72, Bleed: 2.54, CropMarks: true}.Page()
+	assert.InDelta(t, 72+2*(cropMarkOffset+cropMarkLength)/MillimetresPerInch*72, page.Width, 1e-9)
+}
+
+func TestWritePDF(t *testing.T) {
+	composer, ok := mosaic.GetComposer("tiles-perfect")
+	if !assert.True(t, ok) {
+		return
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.ZP, draw.Src)
+
+	var buf bytes.Buffer
+	print := PrintOptions{Width: 10, Height: 10, DPI: 100, CropMarks: true}
+	if assert.NoError(t, WritePDF(context.Background(), &buf, composer, print, nil, img)) {
+		assert.Contains(t, buf.String(), "/TrimBox ")
+	}
+
+	assert.Error(t, WritePDF(context.Background(), &buf, composer, PrintOptions{}, nil, img))
+}
@@ -0,0 +1,541 @@
+package mosaicc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/fogleman/gg"
+	"github.com/gieseladev/mosaic"
+	"image"
+	"math/rand"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrNoComposer is returned when no composer is suitable for the amount of
+// images.
+var ErrNoComposer = errors.New("no composer suitable for the amount of images")
+
+// FindComposer returns the composer with the given id. If the id is empty
+// or "random", a composer suitable for the image count is chosen randomly
+// using the seed, see mosaic.ChooseComposer.
+func FindComposer(id string, imageCount int, seed int64) (mosaic.ComposerInfo, error) {
+	if id == "" || id == "random" {
+		composer, ok := mosaic.ChooseComposer(imageCount, rand.New(rand.NewSource(seed)))
+		if !ok {
+			return mosaic.ComposerInfo{}, ErrNoComposer
+		}
+
+		return composer, nil
+	}
+
+	composer, ok := mosaic.GetComposer(id)
+	if !ok {
+		return mosaic.ComposerInfo{}, fmt.Errorf("no composer %q found", id)
+	}
+
+	return composer, nil
+}
+
+// optionJSON is the JSON representation of a mosaic.Option.
+type optionJSON struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Type        string      `json:"type"`
+	Default     interface{} `json:"default"`
+	Min         *float64    `json:"min,omitempty"`
+	Max         *float64    `json:"max,omitempty"`
+}
+
+// composerJSON is the JSON representation of a mosaic.ComposerInfo.
+type composerJSON struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+
+	ImageCountHuman        string `json:"image_count_human,omitempty"`
+	RecommendedImageCounts []int  `json:"recommended_image_counts"`
+
+	Options []optionJSON `json:"options"`
+}
+
+func newComposerJSON(composer mosaic.ComposerInfo) composerJSON {
+	allOptions := composer.AllOptions()
+	options := make([]optionJSON, len(allOptions))
+	for i, o := range allOptions {
+		options[i] = optionJSON{
+			Name:        o.Name,
+			Description: o.Description,
+			Type:        o.Type.String(),
+			Default:     o.Default,
+		}
+
+		if o.HasRange() {
+			min, max := o.Min, o.Max
+			options[i].Min, options[i].Max = &min, &max
+		}
+	}
+
+	return composerJSON{
+		Id:          composer.Id,
+		Name:        composer.Name,
+		Description: composer.Description,
+
+		ImageCountHuman:        composer.ImageCountHuman,
+		RecommendedImageCounts: composer.RecommendedImageCounts,
+
+		Options: options,
+	}
+}
+
+// ComposeRequest is the JSON body of a composition request.
+type ComposeRequest struct {
+	// Images contains the URLs of the images to use.
+	Images []string `json:"images"`
+
+	Composer string         `json:"composer"`
+	Options  mosaic.Options `json:"options"`
+
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Format string `json:"format"`
+	// Quality is the JPEG quality, see EncodeOptions.
+	Quality int `json:"quality"`
+	// Seed is used to choose a random composer. It defaults to a seed
+	// derived from the images, see mosaic.ImageSeed.
+	Seed *int64 `json:"seed"`
+	// OnError is one of the FailurePolicies and decides what happens to
+	// images which can't be loaded. Defaults to PolicyFail.
+	OnError string `json:"on_error"`
+
+	// Title and Caption are drawn on top of the composition, see
+	// mosaic.TextOverlay.
+	Title         string `json:"title"`
+	Caption       string `json:"caption"`
+	TextPlacement string `json:"text_placement"`
+	TextStyle     string `json:"text_style"`
+	TextColor     string `json:"text_color"`
+}
+
+// textOverlay returns the text overlay of the request.
+func (req ComposeRequest) textOverlay() (mosaic.TextOverlay, error) {
+	overlay := mosaic.TextOverlay{
+		Title:     req.Title,
+		Caption:   req.Caption,
+		Placement: req.TextPlacement,
+		Style:     req.TextStyle,
+	}
+
+	if req.TextColor != "" {
+		textColor, err := mosaic.ParseColor(req.TextColor)
+		if err != nil {
+			return overlay, badRequest("%v", err)
+		}
+
+		overlay.Color = textColor
+	}
+
+	if err := overlay.Validate(); err != nil {
+		return overlay, badRequest("%v", err)
+	}
+
+	return overlay, nil
+}
+
+// A Server provides compositions over HTTP.
+//
+// It handles the following routes:
+//
+//	GET  /composers  lists the available composers as JSON.
+//	POST /compose    creates a composition. The request is either a JSON
+//	                 encoded ComposeRequest, or a multipart form with the
+//	                 fields of a ComposeRequest where images can be
+//	                 uploaded as files in the "image" field and options are
+//	                 passed as "opt" fields of the form key=value.
+type Server struct {
+	// MaxImages is the maximum amount of images in a single request.
+	MaxImages int
+	// MaxDimension is the maximum width and height of a composition.
+	MaxDimension int
+	// MaxUploadSize is the maximum size of a request body in bytes.
+	MaxUploadSize int64
+	// Loader loads the images given by URL. Its limits apply to uploaded
+	// images as well.
+	Loader *Loader
+	// Downscale enables downscaling the images to the size of the
+	// composition before composing them, see DownscaleToCover.
+	Downscale bool
+
+	mux *http.ServeMux
+}
+
+// NewServer creates a new server with reasonable limits.
+func NewServer() *Server {
+	// the server never reads images from its own disk and doesn't connect
+	// to internal services
+	loader := NewLoader()
+	loader.RemoteOnly = true
+	loader.Client = NewPublicClient()
+
+	s := &Server{
+		MaxImages:     64,
+		MaxDimension:  4096,
+		MaxUploadSize: 64 << 20,
+		Loader:        loader,
+	}
+
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("/composers", s.handleComposers)
+	s.mux.HandleFunc("/compose", s.handleCompose)
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// HTTPServer creates an HTTP server listening on the address which serves
+// s. Its timeouts keep slow clients from holding on to connections.
+func (s *Server) HTTPServer(addr string) *http.Server {
+	return &http.Server{
+		Addr:    addr,
+		Handler: s,
+
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       time.Minute,
+		// leaves enough time to load the images and compose them
+		WriteTimeout: 3 * time.Minute,
+		IdleTimeout:  2 * time.Minute,
+	}
+}
+
+// httpError is an error with an associated status code.
+type httpError struct {
+	Status int
+	Err    error
+}
+
+func (e *httpError) Error() string {
+	return e.Err.Error()
+}
+
+func badRequest(format string, a ...interface{}) error {
+	return &httpError{Status: http.StatusBadRequest, Err: fmt.Errorf(format, a...)}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if httpErr, ok := err.(*httpError); ok {
+		status = httpErr.Status
+	}
+
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func (s *Server) handleComposers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, &httpError{Status: http.StatusMethodNotAllowed, Err: errors.New("method not allowed")})
+		return
+	}
+
+	composers := mosaic.GetComposers()
+	resp := make([]composerJSON, len(composers))
+	for i, composer := range composers {
+		resp[i] = newComposerJSON(composer)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// checkImageURL makes sure the location is a remote URL and not a path on
+// the server. Like the loader, it requires a host, so URLs like
+// "http:etc/passwd" aren't mistaken for paths.
+func checkImageURL(location string) error {
+	if !isRemote(location) {
+		return badRequest("invalid image url %q", location)
+	}
+
+	return nil
+}
+
+// parseMultipartRequest parses a multipart form into a compose request and
+// returns the uploaded images.
+func (s *Server) parseMultipartRequest(r *http.Request) (ComposeRequest, []image.Image, error) {
+	var req ComposeRequest
+
+	if err := r.ParseMultipartForm(s.MaxUploadSize); err != nil {
+		return req, nil, badRequest("invalid multipart form: %v", err)
+	}
+
+	form := r.MultipartForm
+	value := func(key string) string {
+		if values := form.Value[key]; len(values) > 0 {
+			return values[0]
+		}
+
+		return ""
+	}
+
+	req.Images = form.Value["image"]
+	req.Composer = value("composer")
+	req.Format = value("format")
+	req.Title = value("title")
+	req.Caption = value("caption")
+	req.TextPlacement = value("text_placement")
+	req.TextStyle = value("text_style")
+	req.TextColor = value("text_color")
+	req.OnError = value("on_error")
+
+	for key, dst := range map[string]*int{"width": &req.Width, "height": &req.Height, "quality": &req.Quality} {
+		if v := value(key); v != "" {
+			i, err := strconv.Atoi(v)
+			if err != nil {
+				return req, nil, badRequest("invalid %s %q", key, v)
+			}
+
+			*dst = i
+		}
+	}
+
+	if v := value("seed"); v != "" {
+		seed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return req, nil, badRequest("invalid seed %q", v)
+		}
+
+		req.Seed = &seed
+	}
+
+	raw, err := ParseOptionArgs(form.Value["opt"])
+	if err != nil {
+		return req, nil, badRequest("%v", err)
+	}
+
+	req.Options = make(mosaic.Options, len(raw))
+	for k, v := range raw {
+		req.Options[k] = v
+	}
+
+	var uploads []image.Image
+	for _, header := range form.File["image"] {
+		f, err := header.Open()
+		if err != nil {
+			return req, nil, err
+		}
+
+		// uploads are subject to the same limits as the loaded images
+		data, err := s.Loader.readAll(f)
+		_ = f.Close()
+
+		var img image.Image
+		if err == nil {
+			img, err = s.Loader.decode(data)
+		}
+
+		if err != nil {
+			return req, nil, badRequest("couldn't decode uploaded image %q: %v", header.Filename, err)
+		}
+
+		uploads = append(uploads, img)
+	}
+
+	return req, uploads, nil
+}
+
+func (s *Server) parseRequest(w http.ResponseWriter, r *http.Request) (ComposeRequest, []image.Image, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.MaxUploadSize)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		return s.parseMultipartRequest(r)
+	}
+
+	var req ComposeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return req, nil, badRequest("invalid request body: %v", err)
+	}
+
+	return req, nil, nil
+}
+
+// resolveOptions converts the options of the request to the values
+// expected by the composer. Options from multipart forms are strings and
+// need to be parsed.
+func resolveOptions(composer mosaic.ComposerInfo, opts mosaic.Options) (mosaic.Options, error) {
+	resolved := make(mosaic.Options, len(opts))
+	for name, value := range opts {
+		o, ok := composer.Option(name)
+		if !ok {
+			return nil, badRequest("unknown option %q for composer %q", name, composer.Id)
+		}
+
+		if s, isString := value.(string); isString && o.Type != mosaic.OptionString {
+			parsed, err := o.Parse(s)
+			if err != nil {
+				return nil, badRequest("%v", err)
+			}
+
+			value = parsed
+		}
+
+		resolved[name] = value
+	}
+
+	validated, err := composer.ValidateOptions(resolved)
+	if err != nil {
+		return nil, badRequest("%v", err)
+	}
+
+	return validated, nil
+}
+
+// composition is a composed image and how it should be encoded.
+type composition struct {
+	Image   image.Image
+	Encoder Encoder
+	Options EncodeOptions
+}
+
+func (s *Server) compose(w http.ResponseWriter, r *http.Request) (*composition, error) {
+	req, images, err := s.parseRequest(w, r)
+	if err != nil {
+		return nil, err
+	}
+
+	format := req.Format
+	if format == "" {
+		format = "png"
+	}
+
+	encoder, ok := GetEncoder(format)
+	if !ok {
+		return nil, badRequest("unsupported format %q", req.Format)
+	}
+
+	encodeOpts := DefaultEncodeOptions
+	if req.Quality != 0 {
+		if req.Quality < 1 || req.Quality > 100 {
+			return nil, badRequest("quality must be between 1 and 100")
+		}
+
+		encodeOpts.Quality = req.Quality
+	}
+
+	overlay, err := req.textOverlay()
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := req.Width, req.Height
+	if width == 0 && height == 0 {
+		width, height = 512, 512
+	} else if width == 0 {
+		width = height
+	} else if height == 0 {
+		height = width
+	}
+
+	if width < 0 || height < 0 || width > s.MaxDimension || height > s.MaxDimension {
+		return nil, badRequest("dimensions must be between 1 and %d", s.MaxDimension)
+	}
+
+	if len(req.Images)+len(images) == 0 {
+		return nil, badRequest("at least one image required")
+	}
+
+	if len(req.Images)+len(images) > s.MaxImages {
+		return nil, badRequest("at most %d images allowed", s.MaxImages)
+	}
+
+	for _, location := range req.Images {
+		if err := checkImageURL(location); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.OnError != "" {
+		if err := CheckFailurePolicy(req.OnError); err != nil {
+			return nil, badRequest("%v", err)
+		}
+	}
+
+	if s.Downscale {
+		for i, img := range images {
+			images[i] = DownscaleToCover(ToNRGBA(img), width, height)
+		}
+	}
+
+	if len(req.Images) > 0 {
+		loader := *s.Loader
+		loader.Policy = req.OnError
+		if loader.Policy == "" {
+			loader.Policy = PolicyFail
+		}
+		if s.Downscale {
+			loader.TargetSize = image.Pt(width, height)
+		}
+
+		loaded, err := loader.LoadAll(r.Context(), req.Images)
+		if err != nil {
+			return nil, &httpError{Status: http.StatusBadGateway, Err: err}
+		}
+
+		images = append(loaded, images...)
+	}
+
+	seed := mosaic.ImageSeed(images...)
+	if req.Seed != nil {
+		seed = *req.Seed
+	}
+
+	composer, err := FindComposer(req.Composer, len(images), seed)
+	if err != nil {
+		return nil, badRequest("%v", err)
+	}
+
+	opts, err := resolveOptions(composer, req.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	imgCount := composer.RecommendImageCount(len(images))
+	if imgCount == 0 {
+		return nil, badRequest("composer %q can't use %d images", composer.Id, len(images))
+	}
+
+	dc := gg.NewContext(width, height)
+	if err := composer.ComposeContext(r.Context(), dc, opts, images[:imgCount]...); err != nil {
+		return nil, err
+	}
+
+	if err := overlay.Draw(dc); err != nil {
+		return nil, err
+	}
+
+	return &composition{Image: dc.Image(), Encoder: encoder, Options: encodeOpts}, nil
+}
+
+func (s *Server) handleCompose(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, &httpError{Status: http.StatusMethodNotAllowed, Err: errors.New("method not allowed")})
+		return
+	}
+
+	c, err := s.compose(w, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", c.Encoder.ContentType)
+	_ = c.Encoder.Encode(w, c.Image, c.Options)
+}
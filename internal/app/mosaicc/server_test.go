@@ -0,0 +1,230 @@
+package mosaicc
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+const testInputDir = "../../../test/data/input"
+
+var testImageNames = []string{
+	"b-martinez-744134.jpg",
+	"j-crop-764891.jpg",
+	"j-han-456323.jpg",
+	"m-spiske-78531.jpg",
+}
+
+// newTestServers starts a file server serving the test images and a
+// composition server.
+func newTestServers() (files, server *httptest.Server, closeAll func()) {
+	files = httptest.NewServer(http.FileServer(http.Dir(testInputDir)))
+
+	// the file server listens on a loopback address
+	s := NewServer()
+	s.Loader.Client = NewPublicClient(mustParseNetworks("127.0.0.0/8", "::1/128")...)
+	server = httptest.NewServer(s)
+
+	return files, server, func() {
+		files.Close()
+		server.Close()
+	}
+}
+
+func postJSON(t *testing.T, url string, body interface{}) (*http.Response, bool) {
+	data, err := json.Marshal(body)
+	if !assert.NoError(t, err) {
+		return nil, false
+	}
+
+	resp, err := http.Post(url, "application/json", bytes.NewReader(data))
+	return resp, assert.NoError(t, err)
+}
+
+func TestServer_Composers(t *testing.T) {
+	_, server, closeAll := newTestServers()
+	defer closeAll()
+
+	resp, err := http.Get(server.URL + "/composers")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var composers []composerJSON
+	if !assert.NoError(t, json.NewDecoder(resp.Body).Decode(&composers)) {
+		return
+	}
+
+	ids := make([]string, len(composers))
+	for i, c := range composers {
+		ids[i] = c.Id
+	}
+
+	assert.Contains(t, ids, "tiles-perfect")
+	assert.Contains(t, ids, "tiles-diamond")
+}
+
+func TestServer_ComposeJSON(t *testing.T) {
+	files, server, closeAll := newTestServers()
+	defer closeAll()
+
+	urls := make([]string, len(testImageNames))
+	for i, name := range testImageNames {
+		urls[i] = files.URL + "/" + name
+	}
+
+	resp, ok := postJSON(t, server.URL+"/compose", ComposeRequest{
+		Images:   urls,
+		Composer: "tiles-perfect",
+		Options:  map[string]interface{}{"gutter": 4},
+		Width:    64,
+		Height:   32,
+		Format:   "jpg",
+		Title:    "Playlist",
+	})
+	if !ok {
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "image/jpeg", resp.Header.Get("Content-Type"))
+
+	img, format, err := image.Decode(resp.Body)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "jpeg", format)
+		assert.Equal(t, image.Rect(0, 0, 64, 32), img.Bounds())
+	}
+}
+
+func TestServer_ComposeMultipart(t *testing.T) {
+	files, server, closeAll := newTestServers()
+	defer closeAll()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	for _, name := range testImageNames[:2] {
+		part, err := mw.CreateFormFile("image", name)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		f, err := os.Open(testInputDir + "/" + name)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		_, err = io.Copy(part, f)
+		_ = f.Close()
+		assert.NoError(t, err)
+	}
+
+	_ = mw.WriteField("image", files.URL+"/"+testImageNames[2])
+	_ = mw.WriteField("composer", "circles-pie")
+	_ = mw.WriteField("width", "40")
+	_ = mw.WriteField("opt", "crop=entropy")
+	_ = mw.Close()
+
+	resp, err := http.Post(server.URL+"/compose", mw.FormDataContentType(), &body)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "image/png", resp.Header.Get("Content-Type"))
+
+	img, _, err := image.Decode(resp.Body)
+	if assert.NoError(t, err) {
+		assert.Equal(t, image.Rect(0, 0, 40, 40), img.Bounds())
+	}
+}
+
+func TestServer_ComposeErrors(t *testing.T) {
+	files, server, closeAll := newTestServers()
+	defer closeAll()
+
+	imageURL := files.URL + "/" + testImageNames[0]
+
+	tests := map[string]struct {
+		Request ComposeRequest
+		Status  int
+	}{
+		"no images":         {ComposeRequest{}, http.StatusBadRequest},
+		"local path":        {ComposeRequest{Images: []string{testInputDir + "/" + testImageNames[0]}}, http.StatusBadRequest},
+		"url without host":  {ComposeRequest{Images: []string{"http:" + testInputDir + "/" + testImageNames[0]}}, http.StatusBadRequest},
+		"unknown composer":  {ComposeRequest{Images: []string{imageURL}, Composer: "unknown"}, http.StatusBadRequest},
+		"unknown format":    {ComposeRequest{Images: []string{imageURL}, Format: "xyz"}, http.StatusBadRequest},
+		"invalid quality":   {ComposeRequest{Images: []string{imageURL}, Format: "jpeg", Quality: 101}, http.StatusBadRequest},
+		"invalid placement": {ComposeRequest{Images: []string{imageURL}, Title: "x", TextPlacement: "top"}, http.StatusBadRequest},
+		"invalid option":    {ComposeRequest{Images: []string{imageURL}, Composer: "tiles-perfect", Options: map[string]interface{}{"gutter": -1}}, http.StatusBadRequest},
+		"too large":         {ComposeRequest{Images: []string{imageURL}, Width: 100000}, http.StatusBadRequest},
+		"invalid policy":    {ComposeRequest{Images: []string{imageURL}, OnError: "ignore"}, http.StatusBadRequest},
+		"missing image":     {ComposeRequest{Images: []string{files.URL + "/missing.jpg"}}, http.StatusBadGateway},
+		"all images failed": {ComposeRequest{Images: []string{files.URL + "/missing.jpg"}, OnError: PolicySkip}, http.StatusBadGateway},
+	}
+
+	for name, test := range tests {
+		resp, ok := postJSON(t, server.URL+"/compose", test.Request)
+		if !ok {
+			continue
+		}
+
+		_ = resp.Body.Close()
+		assert.Equal(t, test.Status, resp.StatusCode, name)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"), name)
+	}
+}
+
+func TestServer_ComposeInternalAddress(t *testing.T) {
+	files, _, closeAll := newTestServers()
+	defer closeAll()
+
+	server := httptest.NewServer(NewServer())
+	defer server.Close()
+
+	resp, ok := postJSON(t, server.URL+"/compose", ComposeRequest{Images: []string{files.URL + "/" + testImageNames[0]}})
+	if !ok {
+		return
+	}
+
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+}
+
+func TestServer_ComposeMultipartTooLarge(t *testing.T) {
+	s := NewServer()
+	s.Loader.MaxDimension = 16
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("image", "large.png")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, _ = part.Write(encodeTestPNG(32, 8))
+	_ = mw.Close()
+
+	resp, err := http.Post(server.URL+"/compose", mw.FormDataContentType(), &body)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
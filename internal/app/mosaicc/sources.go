@@ -0,0 +1,189 @@
+package mosaicc
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// StdinLocation is the location referring to an image read from stdin.
+const StdinLocation = "-"
+
+// ImageExtensions are the file extensions of the images loaded from
+// directories.
+var ImageExtensions = []string{".bmp", ".gif", ".jpeg", ".jpg", ".png", ".tif", ".tiff", ".webp"}
+
+// ErrStdinReused is returned by ExpandLocations when stdin is given more
+// than once.
+var ErrStdinReused = errors.New("stdin can only be used for a single image")
+
+// isDataURI checks whether the location is a data URI.
+func isDataURI(location string) bool {
+	return strings.HasPrefix(location, "data:")
+}
+
+// decodeDataURI returns the media type and the data of a data URI.
+func decodeDataURI(location string) (string, []byte, error) {
+	comma := strings.IndexByte(location, ',')
+	if comma < 0 {
+		return "", nil, errors.New("invalid data uri")
+	}
+
+	params := strings.Split(location[len("data:"):comma], ";")
+	mediaType, isBase64 := params[0], params[len(params)-1] == "base64"
+	data := location[comma+1:]
+
+	if isBase64 {
+		decoded, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			// some encoders omit the padding
+			decoded, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(data, "="))
+		}
+
+		return mediaType, decoded, err
+	}
+
+	unescaped, err := url.PathUnescape(data)
+	return mediaType, []byte(unescaped), err
+}
+
+// isImageFile checks whether the file name has one of the ImageExtensions.
+func isImageFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range ImageExtensions {
+		if ext == e {
+			return true
+		}
+	}
+
+	return false
+}
+
+// imagesInDir returns the paths of all images in the directory sorted by
+// their name.
+func imagesInDir(dir string) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, info := range infos {
+		if !info.IsDir() && isImageFile(info.Name()) {
+			paths = append(paths, filepath.Join(dir, info.Name()))
+		}
+	}
+
+	return paths, nil
+}
+
+// isGlob checks whether the location contains glob meta characters.
+func isGlob(location string) bool {
+	return strings.ContainsAny(location, "*?[")
+}
+
+// ExpandLocations resolves directories to the images inside of them and
+// glob patterns to the files they match, both in lexical order. URLs, data
+// URIs and StdinLocation are kept as is.
+func ExpandLocations(locations []string) ([]string, error) {
+	var expanded []string
+	var usedStdin bool
+
+	for _, location := range locations {
+		switch {
+		case location == StdinLocation:
+			if usedStdin {
+				return nil, ErrStdinReused
+			}
+
+			usedStdin = true
+			expanded = append(expanded, location)
+		case isRemote(location) || isDataURI(location):
+			expanded = append(expanded, location)
+		default:
+			if info, err := os.Stat(location); err == nil && info.IsDir() {
+				paths, err := imagesInDir(location)
+				if err != nil {
+					return nil, err
+				}
+
+				expanded = append(expanded, paths...)
+				continue
+			}
+
+			if isGlob(location) {
+				matches, err := filepath.Glob(location)
+				if err != nil {
+					return nil, fmt.Errorf("invalid pattern %q: %v", location, err)
+				}
+
+				if len(matches) == 0 {
+					return nil, fmt.Errorf("no files match %q", location)
+				}
+
+				sort.Strings(matches)
+				expanded = append(expanded, matches...)
+				continue
+			}
+
+			expanded = append(expanded, location)
+		}
+	}
+
+	return expanded, nil
+}
+
+// ReadLocationList reads one location per line. Empty lines and lines
+// starting with # are skipped.
+func ReadLocationList(r io.Reader) ([]string, error) {
+	var locations []string
+
+	scanner := bufio.NewScanner(r)
+	// data URIs can be long
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		locations = append(locations, line)
+	}
+
+	return locations, scanner.Err()
+}
+
+// LoadLocationList reads the list of locations in the file, see
+// ReadLocationList. Relative file paths are resolved relative to the
+// directory of the list.
+func LoadLocationList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	locations, err := ReadLocationList(f)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read list %q: %v", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for i, location := range locations {
+		if location == StdinLocation || isRemote(location) || isDataURI(location) || filepath.IsAbs(location) {
+			continue
+		}
+
+		locations[i] = filepath.Join(dir, location)
+	}
+
+	return locations, nil
+}
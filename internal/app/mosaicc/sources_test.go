@@ -0,0 +1,96 @@
+package mosaicc
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandLocations(t *testing.T) {
+	dir := filepath.Join(testInputDir, "..", "layouts")
+
+	locations, err := ExpandLocations([]string{
+		"http://example.com/a.png",
+		testInputDir,
+		filepath.Join(testInputDir, "j-*.jpg"),
+		"-",
+		"data:image/png;base64,AAAA",
+		dir,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	inputs, _ := filepath.Glob(filepath.Join(testInputDir, "*.jpg"))
+	jImages, _ := filepath.Glob(filepath.Join(testInputDir, "j-*.jpg"))
+
+	expected := []string{"http://example.com/a.png"}
+	expected = append(expected, inputs...)
+	expected = append(expected, jImages...)
+	expected = append(expected, "-", "data:image/png;base64,AAAA")
+	assert.Equal(t, expected, locations)
+
+	_, err = ExpandLocations([]string{"-", "-"})
+	assert.Equal(t, ErrStdinReused, err)
+
+	_, err = ExpandLocations([]string{filepath.Join(testInputDir, "*.xyz")})
+	assert.Error(t, err)
+
+	// missing files are reported when loading them
+	locations, err = ExpandLocations([]string{"missing.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"missing.png"}, locations)
+}
+
+func TestLoadLocationList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mosaic-list")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	list := "# covers\n\na.png\n  /abs/b.png  \nhttps://example.com/c.png\n-\n"
+	path := filepath.Join(dir, "list.txt")
+	if !assert.NoError(t, ioutil.WriteFile(path, []byte(list), 0644)) {
+		return
+	}
+
+	locations, err := LoadLocationList(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "a.png"), "/abs/b.png", "https://example.com/c.png", "-"}, locations)
+}
+
+func TestLoader_Sources(t *testing.T) {
+	png := encodeTestPNG(3, 2)
+	l := NewLoader()
+	l.Stdin = bytes.NewReader(png)
+
+	img, err := l.Load(context.Background(), StdinLocation)
+	if assert.NoError(t, err) {
+		assert.Equal(t, image.Rect(0, 0, 3, 2), img.Bounds())
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(png)
+	for _, uri := range []string{
+		"data:image/png;base64," + encoded,
+		"data:;base64," + strings.TrimRight(encoded, "="),
+	} {
+		img, err = l.Load(context.Background(), uri)
+		if assert.NoError(t, err) {
+			assert.Equal(t, image.Rect(0, 0, 3, 2), img.Bounds())
+		}
+	}
+
+	_, err = l.Load(context.Background(), "data:text/plain,hello")
+	assert.Equal(t, &LoadError{Location: "data:text/plain,hello", Err: &ContentTypeError{ContentType: "text/plain"}}, err)
+
+	_, err = l.Load(context.Background(), "data:image/png")
+	assert.IsType(t, &LoadError{}, err)
+}
@@ -0,0 +1,321 @@
+package mosaic
+
+import (
+	"context"
+	"errors"
+	"github.com/disintegration/imaging"
+	"github.com/fogleman/gg"
+	"github.com/gieseladev/mosaic/pkg/geom"
+	"image"
+	"image/color"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// ErrNoLayout is returned by ComposerInfo.Layout if the composer doesn't
+// describe its compositions using a Layout.
+var ErrNoLayout = errors.New("composer doesn't provide a layout")
+
+// A Region is an area of a composition showing a single image.
+type Region struct {
+	// Image is the index of the image shown in the region.
+	Image int
+	// Shape clips the image.
+	Shape Shape
+	// Rect is the area the image is fitted into before it's clipped.
+	Rect geom.Rectangle
+	// Anchor is the relative point of the image which is kept when it's
+	// cropped. If it's nil, the cropper of the composition is used.
+	Anchor *geom.Point
+}
+
+// Contains checks whether the point is part of the region.
+func (r Region) Contains(p geom.Point) bool {
+	return r.Shape.Contains(p)
+}
+
+// A Layout describes where the images of a composition are drawn.
+type Layout struct {
+	Width, Height int
+
+	// Background fills the canvas before the regions are drawn. If it's nil
+	// the canvas is left untouched.
+	Background color.Color
+
+	// Regions are drawn in order, later regions are drawn on top of
+	// earlier ones.
+	Regions []Region
+}
+
+// RegionAt returns the top most region containing the point.
+func (l Layout) RegionAt(p geom.Point) (Region, bool) {
+	for i := len(l.Regions) - 1; i >= 0; i-- {
+		if l.Regions[i].Contains(p) {
+			return l.Regions[i], true
+		}
+	}
+
+	return Region{}, false
+}
+
+// A LayoutComposer is a composer which describes its compositions using a
+// Layout.
+type LayoutComposer interface {
+	ContextComposer
+
+	// Layout creates the layout of a composition with the given size and
+	// amount of images.
+	Layout(width, height int, opts Options, imageCount int) (Layout, error)
+}
+
+// A LayoutFunc is a LayoutComposer which itself is a function creating the
+// layout. The layout is drawn using Render.
+type LayoutFunc func(width, height int, opts Options, imageCount int) (Layout, error)
+
+// Layout calls the underlying function with the given arguments.
+func (f LayoutFunc) Layout(width, height int, opts Options, imageCount int) (Layout, error) {
+	return f(width, height, opts, imageCount)
+}
+
+// Compose renders the layout with a background context.
+func (f LayoutFunc) Compose(dc *gg.Context, opts Options, images ...image.Image) error {
+	return f.ComposeContext(context.Background(), dc, opts, images...)
+}
+
+// ComposeContext creates the layout for the context and renders it.
+func (f LayoutFunc) ComposeContext(ctx context.Context, dc *gg.Context, opts Options, images ...image.Image) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	layout, err := f(dc.Width(), dc.Height(), opts, len(images))
+	if err != nil {
+		return err
+	}
+
+	return Render(ctx, dc, layout, opts, images...)
+}
+
+// pixelRect returns the smallest pixel rectangle containing the rectangle.
+func pixelRect(r geom.Rectangle) image.Rectangle {
+	return image.Rect(
+		int(math.Floor(r.Min.X)), int(math.Floor(r.Min.Y)),
+		int(math.Ceil(r.Max.X)), int(math.Ceil(r.Max.Y)),
+	)
+}
+
+// rectFromPixels converts a pixel rectangle to a rectangle.
+func rectFromPixels(r image.Rectangle) geom.Rectangle {
+	return geom.Rect(float64(r.Min.X), float64(r.Min.Y), float64(r.Max.X), float64(r.Max.Y))
+}
+
+// needsMask checks whether the image of the region has to be clipped.
+// Regions whose shape is a pixel aligned rectangle covering the entire
+// image can be drawn directly.
+func (r Region) needsMask() bool {
+	s, ok := r.Shape.(RectShape)
+	if !ok || s.radius() > 0 || s.Rect != r.Rect {
+		return true
+	}
+
+	return rectFromPixels(pixelRect(s.Rect)) != s.Rect
+}
+
+// fitImages crops and resizes the images of all regions in parallel.
+func fitImages(ctx context.Context, regions []Region, cropper Cropper, images []image.Image) []image.Image {
+	fitted := make([]image.Image, len(regions))
+
+	workers := runtime.NumCPU()
+	if workers > len(regions) {
+		workers = len(regions)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				region := regions[i]
+				rect := pixelRect(region.Rect)
+				if rect.Empty() || ctx.Err() != nil {
+					continue
+				}
+
+				c := cropper
+				if region.Anchor != nil {
+					c = AnchorCropper(*region.Anchor)
+				}
+
+				fitted[i] = fill(c, images[region.Image], rect.Dx(), rect.Dy())
+			}
+		}()
+	}
+
+	for i := range regions {
+		if ctx.Err() != nil {
+			break
+		}
+
+		jobs <- i
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	return fitted
+}
+
+// cropRegion returns the part of the image of the region which Render
+// would show, but in the resolution of the image instead of resized to
+// the region. It also returns the pixel rectangle the crop is stretched
+// to. The image is nil if nothing is shown.
+func cropRegion(region Region, cropper Cropper, images []image.Image) (*image.NRGBA, image.Rectangle) {
+	rect := pixelRect(region.Rect)
+	img := images[region.Image]
+	if rect.Empty() || img.Bounds().Empty() {
+		return nil, rect
+	}
+
+	if region.Anchor != nil {
+		cropper = AnchorCropper(*region.Anchor)
+	}
+
+	return imaging.Crop(img, cropper.Crop(img, rect.Dx(), rect.Dy())), rect
+}
+
+// vectorLayout validates the options and returns the layout of the
+// composition for vector output together with the background, which is nil
+// if there's none.
+func (ci ComposerInfo) vectorLayout(width, height int, opts Options, images []image.Image) (Layout, image.Image, Options, error) {
+	opts, err := ci.ValidateOptions(opts)
+	if err != nil {
+		return Layout{}, nil, nil, err
+	}
+
+	layout, err := ci.Layout(width, height, opts, len(images))
+	if err != nil {
+		return Layout{}, nil, nil, err
+	}
+
+	name := opts.Str("background")
+	if name == BackgroundNone || len(images) == 0 {
+		return layout, nil, opts, nil
+	}
+
+	dc := gg.NewContext(width, height)
+	if err := DrawBackground(dc, name, images...); err != nil {
+		return Layout{}, nil, nil, err
+	}
+
+	return layout, dc.Image(), opts, nil
+}
+
+// fillBackground fills the canvas with the background color of a layout.
+// If gapsOnly is set, only the parts of the canvas outside of the regions
+// are filled, so that a background drawn before shows through transparent
+// images.
+func fillBackground(dc *gg.Context, c color.Color, regions []Region, gapsOnly bool) {
+	if c == nil {
+		return
+	}
+
+	if _, _, _, a := c.RGBA(); a == 0 {
+		return
+	}
+
+	dc.SetColor(c)
+	if !gapsOnly {
+		dc.Clear()
+		return
+	}
+
+	bounds := image.Rect(0, 0, dc.Width(), dc.Height())
+	gaps := image.NewAlpha(bounds)
+	for i := range gaps.Pix {
+		gaps.Pix[i] = 0xff
+	}
+
+	maskDC := gg.NewContext(dc.Width(), dc.Height())
+	for _, region := range regions {
+		maskDC.Clear()
+		region.Shape.Path(maskDC)
+		maskDC.Fill()
+		mask := maskDC.AsMask()
+
+		area := pixelRect(region.Shape.Bounds()).Intersect(bounds)
+		for y := area.Min.Y; y < area.Max.Y; y++ {
+			for x := area.Min.X; x < area.Max.X; x++ {
+				i := gaps.PixOffset(x, y)
+				if covered := mask.Pix[i]; 0xff-covered < gaps.Pix[i] {
+					gaps.Pix[i] = 0xff - covered
+				}
+			}
+		}
+	}
+
+	_ = dc.SetMask(gaps)
+	dc.DrawRectangle(0, 0, float64(dc.Width()), float64(dc.Height()))
+	dc.Fill()
+	dc.ResetClip()
+}
+
+// Render draws the images into the regions of the layout using the
+// cropper and compositing mode selected by the options.
+func Render(ctx context.Context, dc *gg.Context, layout Layout, opts Options, images ...image.Image) error {
+	for _, region := range layout.Regions {
+		if region.Image < 0 || region.Image >= len(images) {
+			return ErrInvalidImageCount
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	fillBackground(dc, layout.Background, layout.Regions, hasBackground(opts))
+
+	fitted := fitImages(ctx, layout.Regions, opts.Cropper(), images)
+	if opts.Str("compositing") == CompositingCoverage {
+		return renderCoverage(ctx, dc, layout.Regions, fitted)
+	}
+
+	var maskDC *gg.Context
+	defer dc.ResetClip()
+
+	for i, region := range layout.Regions {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		img := fitted[i]
+		if img == nil {
+			continue
+		}
+
+		rect := pixelRect(region.Rect)
+
+		if !region.needsMask() {
+			dc.ResetClip()
+			dc.DrawImage(img, rect.Min.X, rect.Min.Y)
+			continue
+		}
+
+		if maskDC == nil {
+			maskDC = gg.NewContext(dc.Width(), dc.Height())
+		}
+
+		maskDC.Clear()
+		region.Shape.Path(maskDC)
+		maskDC.Fill()
+
+		_ = dc.SetMask(maskDC.AsMask())
+		dc.DrawImage(img, rect.Min.X, rect.Min.Y)
+	}
+
+	return ctx.Err()
+}
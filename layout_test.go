@@ -0,0 +1,154 @@
+package mosaic
+
+import (
+	"context"
+	"github.com/fogleman/gg"
+	"github.com/gieseladev/mosaic/pkg/geom"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestShapes_Contains(t *testing.T) {
+	rounded := RectShape{Rect: geom.Rect(0, 0, 10, 10), Radius: 4}
+	assert.True(t, rounded.Contains(geom.Pt(5, 5)))
+	assert.True(t, rounded.Contains(geom.Pt(5, 0)))
+	assert.False(t, rounded.Contains(geom.Pt(.5, .5)))
+	assert.False(t, rounded.Contains(geom.Pt(11, 5)))
+
+	circle := CircleShape{Center: geom.Pt(5, 5), Radius: 5}
+	assert.True(t, circle.Contains(geom.Pt(9, 5)))
+	assert.False(t, circle.Contains(geom.Pt(9, 9)))
+
+	slice := SliceShape{Center: geom.Pt(0, 0), Radius: 10, Start: 0, End: geom.HalfPi}
+	assert.True(t, slice.Contains(geom.Pt(3, 3)))
+	assert.False(t, slice.Contains(geom.Pt(-3, 3)))
+	assert.False(t, slice.Contains(geom.Pt(9, 9)))
+	assert.Equal(t, geom.Rect(0, 0, 10, 10), slice.Bounds())
+
+	full := SliceShape{Radius: 1, Start: 0, End: geom.TwoPi}
+	assert.True(t, full.Contains(geom.Pt(-.5, -.5)))
+}
+
+func TestShapes_Outline(t *testing.T) {
+	shapes := []Shape{
+		RectShape{Rect: geom.Rect(0, 0, 10, 10), Radius: 3},
+		CircleShape{Center: geom.Pt(5, 5), Radius: 5},
+		SliceShape{Center: geom.Pt(5, 5), Radius: 5, Start: 1, End: 2},
+		PolygonShape{geom.Poly(geom.Pt(0, 0), geom.Pt(10, 0), geom.Pt(0, 10))},
+	}
+
+	for _, s := range shapes {
+		outline := s.Outline().BoundingRect()
+		bounds := s.Bounds()
+		assert.InDelta(t, bounds.Min.X, outline.Min.X, .5, "%T", s)
+		assert.InDelta(t, bounds.Max.Y, outline.Max.Y, .5, "%T", s)
+	}
+}
+
+func TestComposerInfo_Layout(t *testing.T) {
+	composer, ok := GetComposer("tiles-perfect")
+	if !assert.True(t, ok) {
+		return
+	}
+
+	layout, err := composer.Layout(100, 100, nil, 4)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Len(t, layout.Regions, 4)
+
+	region, ok := layout.RegionAt(geom.Pt(75, 25))
+	if assert.True(t, ok) {
+		assert.Equal(t, 1, region.Image)
+		assert.Equal(t, geom.Rect(50, 0, 100, 50), region.Rect)
+	}
+
+	_, err = composer.Layout(100, 100, Options{"gutter": -1}, 4)
+	assert.IsType(t, &OptionError{}, err)
+
+	_, err = ComposerInfo{Composer: ComposerFunc(nil)}.Layout(100, 100, nil, 4)
+	assert.Equal(t, ErrNoLayout, err)
+}
+
+func TestBuiltinComposerLayouts(t *testing.T) {
+	for _, composer := range GetComposers() {
+		count := composer.RecommendImageCount(13)
+		layout, err := composer.Layout(60, 40, nil, count)
+		if !assert.NoError(t, err, composer.Id) {
+			continue
+		}
+
+		assert.Equal(t, 60, layout.Width, composer.Id)
+		assert.Equal(t, 40, layout.Height, composer.Id)
+
+		seen := make(map[int]bool)
+		for _, region := range layout.Regions {
+			seen[region.Image] = true
+
+			// every region must show at least some part of its image
+			center := region.Shape.Bounds().Center()
+			assert.True(t, region.Contains(center), "%s region %d", composer.Id, region.Image)
+		}
+
+		assert.Len(t, seen, count, composer.Id)
+	}
+}
+
+func TestRender(t *testing.T) {
+	layout := Layout{
+		Width:      20,
+		Height:     10,
+		Background: color.White,
+		Regions: []Region{
+			{Image: 0, Shape: RectShape{Rect: geom.Rect(0, 0, 10, 10)}, Rect: geom.Rect(0, 0, 10, 10)},
+			{Image: 1, Shape: CircleShape{Center: geom.Pt(15, 5), Radius: 3}, Rect: geom.Rect(10, 0, 20, 10)},
+		},
+	}
+
+	dc := gg.NewContext(20, 10)
+	err := Render(context.Background(), dc, layout, nil, solidImages(2)...)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	img := dc.Image()
+	assert.Equal(t, color.RGBA{R: 10, A: 255}, img.At(5, 5))
+	assert.Equal(t, color.RGBA{R: 20, A: 255}, img.At(15, 5))
+	assert.Equal(t, color.RGBA{R: 255, G: 255, B: 255, A: 255}, img.At(19, 0))
+
+	layout.Regions[1].Image = 2
+	err = Render(context.Background(), dc, layout, nil, solidImages(2)...)
+	assert.Equal(t, ErrInvalidImageCount, err)
+}
+
+func TestRender_Anchor(t *testing.T) {
+	// left half red, right half blue
+	src := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 20; x++ {
+			if x < 10 {
+				src.Set(x, y, color.NRGBA{R: 255, A: 255})
+			} else {
+				src.Set(x, y, color.NRGBA{B: 255, A: 255})
+			}
+		}
+	}
+
+	rect := geom.Rect(0, 0, 10, 10)
+	right := geom.Pt(1, .5)
+	layout := Layout{
+		Width:   10,
+		Height:  10,
+		Regions: []Region{{Shape: RectShape{Rect: rect}, Rect: rect, Anchor: &right}},
+	}
+
+	dc := gg.NewContext(10, 10)
+	if assert.NoError(t, Render(context.Background(), dc, layout, nil, src)) {
+		_, _, b, _ := dc.Image().At(5, 5).RGBA()
+		assert.Equal(t, uint32(math.MaxUint16), b)
+	}
+}
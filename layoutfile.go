@@ -0,0 +1,367 @@
+package mosaic
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gieseladev/mosaic/pkg/geom"
+	"gopkg.in/yaml.v2"
+	"image/color"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LayoutFileExtensions are the file extensions recognised by
+// LoadLayoutDir.
+var LayoutFileExtensions = []string{".yaml", ".yml", ".json"}
+
+// A LayoutFileError is returned when a layout file can't be loaded.
+type LayoutFileError struct {
+	Path string
+	Err  error
+}
+
+func (e *LayoutFileError) Error() string {
+	return fmt.Sprintf("layout file %q: %v", e.Path, e.Err)
+}
+
+// CircleSpec describes a circle of a LayoutFile.
+type CircleSpec struct {
+	// Center is the relative position of the center.
+	Center [2]float64 `yaml:"center"`
+	// Radius is relative to the shorter side of the canvas.
+	Radius float64 `yaml:"radius"`
+}
+
+// A RegionSpec describes a region of a LayoutFile. Exactly one of Rect,
+// Polygon and Circle has to be set.
+//
+// All coordinates are relative to the canvas, 0 being the left or top edge
+// and 1 the right or bottom edge.
+type RegionSpec struct {
+	// Image is the index of the image shown in the region. It defaults to
+	// the index of the region.
+	Image *int `yaml:"image"`
+
+	// Rect contains the coordinates of the top left and bottom right
+	// corner (x0, y0, x1, y1).
+	Rect []float64 `yaml:"rect"`
+	// Radius is the corner radius of the Rect relative to the shorter side
+	// of the canvas.
+	Radius float64 `yaml:"radius"`
+
+	// Polygon contains the vertices of a polygon.
+	Polygon [][2]float64 `yaml:"polygon"`
+
+	Circle *CircleSpec `yaml:"circle"`
+
+	// Dest is the rectangle the image is fitted into (x0, y0, x1, y1).
+	// It defaults to the bounds of the shape.
+	Dest []float64 `yaml:"dest"`
+
+	// Anchor is the relative point of the image which is kept when it's
+	// cropped, see Region.Anchor.
+	Anchor []float64 `yaml:"anchor"`
+}
+
+// A LayoutFile is a user defined composer described in JSON or YAML.
+type LayoutFile struct {
+	Id          string `yaml:"id"`
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+
+	ImageCountHuman        string `yaml:"image_count_human"`
+	RecommendedImageCounts []int  `yaml:"recommended_image_counts"`
+
+	// Background is the color the canvas is filled with.
+	Background string `yaml:"background"`
+
+	Regions []RegionSpec `yaml:"regions"`
+}
+
+// ParseLayoutFile parses a layout file in the JSON or YAML format.
+func ParseLayoutFile(data []byte) (LayoutFile, error) {
+	var lf LayoutFile
+	if err := yaml.UnmarshalStrict(data, &lf); err != nil {
+		return lf, err
+	}
+
+	return lf, lf.Validate()
+}
+
+// scaleRect converts relative rectangle coordinates to a rectangle.
+func scaleRect(coords []float64, w, h float64) (geom.Rectangle, error) {
+	if len(coords) != 4 {
+		return geom.Rectangle{}, errors.New("rectangle needs 4 coordinates (x0, y0, x1, y1)")
+	}
+
+	return geom.Rect(coords[0]*w, coords[1]*h, coords[2]*w, coords[3]*h), nil
+}
+
+// shape returns the shape of the region for a canvas of the given size.
+func (r RegionSpec) shape(w, h float64) (Shape, error) {
+	var shapes []Shape
+	s := math.Min(w, h)
+
+	if r.Rect != nil {
+		rect, err := scaleRect(r.Rect, w, h)
+		if err != nil {
+			return nil, err
+		}
+
+		shapes = append(shapes, RectShape{Rect: rect, Radius: r.Radius * s})
+	}
+
+	if r.Polygon != nil {
+		if len(r.Polygon) < 3 {
+			return nil, errors.New("polygon needs at least 3 vertices")
+		}
+
+		vertices := make([]geom.Point, len(r.Polygon))
+		for i, v := range r.Polygon {
+			vertices[i] = geom.Pt(v[0]*w, v[1]*h)
+		}
+
+		shapes = append(shapes, PolygonShape{geom.Poly(vertices...)})
+	}
+
+	if r.Circle != nil {
+		shapes = append(shapes, CircleShape{
+			Center: geom.Pt(r.Circle.Center[0]*w, r.Circle.Center[1]*h),
+			Radius: r.Circle.Radius * s,
+		})
+	}
+
+	if len(shapes) != 1 {
+		return nil, errors.New("region needs exactly one of rect, polygon and circle")
+	}
+
+	return shapes[0], nil
+}
+
+// region returns the region for a canvas of the given size.
+func (r RegionSpec) region(index int, w, h float64) (Region, error) {
+	shape, err := r.shape(w, h)
+	if err != nil {
+		return Region{}, err
+	}
+
+	region := Region{Image: index, Shape: shape, Rect: shape.Bounds()}
+	if r.Image != nil {
+		region.Image = *r.Image
+	}
+
+	if region.Image < 0 {
+		return region, errors.New("image index must not be negative")
+	}
+
+	if r.Dest != nil {
+		if region.Rect, err = scaleRect(r.Dest, w, h); err != nil {
+			return region, err
+		}
+	}
+
+	if r.Anchor != nil {
+		if len(r.Anchor) != 2 {
+			return region, errors.New("anchor needs 2 coordinates (x, y)")
+		}
+
+		anchor := geom.Pt(r.Anchor[0], r.Anchor[1])
+		region.Anchor = &anchor
+	}
+
+	return region, nil
+}
+
+// imageSlots returns the amount of images used by the layout.
+func (lf LayoutFile) imageSlots() int {
+	var slots int
+	for i, r := range lf.Regions {
+		index := i
+		if r.Image != nil {
+			index = *r.Image
+		}
+
+		if index+1 > slots {
+			slots = index + 1
+		}
+	}
+
+	return slots
+}
+
+// background returns the parsed background color or nil if none is set.
+func (lf LayoutFile) background() (color.Color, error) {
+	if lf.Background == "" {
+		return nil, nil
+	}
+
+	return ParseColor(lf.Background)
+}
+
+// Validate checks the layout file for errors.
+func (lf LayoutFile) Validate() error {
+	if lf.Id == "" {
+		return errors.New("id required")
+	}
+
+	if len(lf.Regions) == 0 {
+		return errors.New("at least one region required")
+	}
+
+	if _, err := lf.background(); err != nil {
+		return err
+	}
+
+	for i, r := range lf.Regions {
+		if _, err := r.region(i, 1, 1); err != nil {
+			return fmt.Errorf("region %d: %v", i, err)
+		}
+	}
+
+	for _, count := range lf.RecommendedImageCounts {
+		if count < 1 {
+			return fmt.Errorf("invalid recommended image count %d", count)
+		}
+	}
+
+	return nil
+}
+
+// Layout creates the layout for a canvas of the given size. Regions whose
+// image index isn't below the image count are left out.
+func (lf LayoutFile) Layout(width, height int, opts Options, imageCount int) (Layout, error) {
+	background, err := lf.background()
+	if err != nil {
+		return Layout{}, err
+	}
+
+	layout := Layout{Width: width, Height: height, Background: background}
+	for i, r := range lf.Regions {
+		region, err := r.region(i, float64(width), float64(height))
+		if err != nil {
+			return Layout{}, fmt.Errorf("region %d: %v", i, err)
+		}
+
+		if region.Image < imageCount {
+			layout.Regions = append(layout.Regions, region)
+		}
+	}
+
+	if len(layout.Regions) == 0 {
+		return layout, ErrInvalidImageCount
+	}
+
+	return layout, nil
+}
+
+// ComposerInfo returns the composer described by the layout file.
+// If no image counts are recommended, the amount of images used by the
+// regions is recommended.
+func (lf LayoutFile) ComposerInfo() ComposerInfo {
+	counts := lf.RecommendedImageCounts
+	if len(counts) == 0 {
+		counts = []int{lf.imageSlots()}
+	}
+
+	minCount := counts[0]
+	for _, c := range counts[1:] {
+		if c < minCount {
+			minCount = c
+		}
+	}
+
+	name := lf.Name
+	if name == "" {
+		name = lf.Id
+	}
+
+	return ComposerInfo{
+		Composer:    LayoutFunc(lf.Layout),
+		Id:          lf.Id,
+		Name:        name,
+		Description: lf.Description,
+
+		ImageCountHuman: lf.ImageCountHuman,
+		CheckImageCount: func(count int) bool {
+			return count >= minCount
+		},
+
+		RecommendedImageCounts: counts,
+	}
+}
+
+// LoadLayoutFile loads the layout file at the given path.
+func LoadLayoutFile(path string) (ComposerInfo, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ComposerInfo{}, &LayoutFileError{Path: path, Err: err}
+	}
+
+	lf, err := ParseLayoutFile(data)
+	if err != nil {
+		return ComposerInfo{}, &LayoutFileError{Path: path, Err: err}
+	}
+
+	return lf.ComposerInfo(), nil
+}
+
+// isLayoutFile checks whether the file name has one of the
+// LayoutFileExtensions.
+func isLayoutFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range LayoutFileExtensions {
+		if ext == e {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LoadLayoutDir loads all layout files in the directory in the order of
+// their file names.
+func LoadLayoutDir(dir string) ([]ComposerInfo, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, &LayoutFileError{Path: dir, Err: err}
+	}
+
+	var composers []ComposerInfo
+	for _, info := range infos {
+		if info.IsDir() || !isLayoutFile(info.Name()) {
+			continue
+		}
+
+		composer, err := LoadLayoutFile(filepath.Join(dir, info.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		composers = append(composers, composer)
+	}
+
+	return composers, nil
+}
+
+// LoadLayouts loads the layout file or all layout files in the directory
+// at the given path.
+func LoadLayouts(path string) ([]ComposerInfo, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, &LayoutFileError{Path: path, Err: err}
+	}
+
+	if info.IsDir() {
+		return LoadLayoutDir(path)
+	}
+
+	composer, err := LoadLayoutFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return []ComposerInfo{composer}, nil
+}
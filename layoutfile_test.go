@@ -0,0 +1,84 @@
+package mosaic
+
+import (
+	"github.com/fogleman/gg"
+	"github.com/gieseladev/mosaic/pkg/geom"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseLayoutFile(t *testing.T) {
+	lf, err := ParseLayoutFile([]byte(`
+id: split
+regions:
+  - rect: [0, 0, 0.5, 1]
+  - image: 0
+    polygon: [[0.5, 0], [1, 0], [1, 1]]
+    dest: [0.5, 0, 1, 1]
+    anchor: [1, 0]
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, 1, lf.imageSlots())
+
+	layout, err := lf.Layout(100, 50, nil, 1)
+	if !assert.NoError(t, err) || !assert.Len(t, layout.Regions, 2) {
+		return
+	}
+
+	assert.Equal(t, RectShape{Rect: geom.Rect(0, 0, 50, 50)}, layout.Regions[0].Shape)
+	assert.Equal(t, geom.Rect(50, 0, 100, 50), layout.Regions[1].Rect)
+	assert.Equal(t, 0, layout.Regions[1].Image)
+	if assert.NotNil(t, layout.Regions[1].Anchor) {
+		assert.Equal(t, geom.Pt(1, 0), *layout.Regions[1].Anchor)
+	}
+
+	invalid := []string{
+		`regions: [{rect: [0, 0, 1, 1]}]`,
+		`id: x`,
+		`{id: x, regions: [{rect: [0, 0, 1]}]}`,
+		`{id: x, regions: [{rect: [0, 0, 1, 1], circle: {radius: 1}}]}`,
+		`{id: x, regions: [{polygon: [[0, 0], [1, 1]]}]}`,
+		`{id: x, regions: [{rect: [0, 0, 1, 1]}], background: nope}`,
+		`{id: x, regions: [{rect: [0, 0, 1, 1], unknown: 1}]}`,
+	}
+
+	for _, data := range invalid {
+		_, err := ParseLayoutFile([]byte(data))
+		assert.Error(t, err, data)
+	}
+}
+
+func TestLoadLayouts(t *testing.T) {
+	composers, err := LoadLayouts("test/data/layouts")
+	if !assert.NoError(t, err) || !assert.Len(t, composers, 2) {
+		return
+	}
+
+	polaroid, target := composers[0], composers[1]
+	assert.Equal(t, "polaroid", polaroid.Id)
+	assert.Equal(t, []int{4}, polaroid.RecommendedImageCounts)
+
+	assert.Equal(t, "target", target.Id)
+	assert.Equal(t, 3, target.RecommendImageCount(5))
+	assert.Equal(t, 0, target.RecommendImageCount(1))
+
+	// the region of the third image is left out
+	layout, err := target.Layout(40, 40, nil, 2)
+	if assert.NoError(t, err) {
+		assert.Len(t, layout.Regions, 2)
+	}
+
+	dc := gg.NewContext(40, 40)
+	assert.NoError(t, target.Compose(dc, nil, solidImages(3)...))
+
+	_, err = LoadLayouts("test/data/layouts/missing.yaml")
+	assert.IsType(t, &LayoutFileError{}, err)
+
+	_, err = LoadLayoutDir("test/data/layouts/missing")
+	if assert.IsType(t, &LayoutFileError{}, err) {
+		assert.Equal(t, "test/data/layouts/missing", err.(*LayoutFileError).Path)
+	}
+}
@@ -0,0 +1,263 @@
+package mosaic
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// OptionType is the type of value an Option holds.
+type OptionType int
+
+const (
+	// OptionInt is the type of options holding an int.
+	OptionInt OptionType = iota
+	// OptionFloat is the type of options holding a float64.
+	OptionFloat
+	// OptionBool is the type of options holding a bool.
+	OptionBool
+	// OptionString is the type of options holding a string.
+	OptionString
+	// OptionColor is the type of options holding a color.Color.
+	// Strings are parsed using ParseColor.
+	OptionColor
+)
+
+func (t OptionType) String() string {
+	switch t {
+	case OptionInt:
+		return "int"
+	case OptionFloat:
+		return "float"
+	case OptionBool:
+		return "bool"
+	case OptionString:
+		return "string"
+	case OptionColor:
+		return "color"
+	default:
+		return fmt.Sprintf("OptionType(%d)", int(t))
+	}
+}
+
+// An Option describes a value which can be used to configure a composer.
+type Option struct {
+	Name        string
+	Description string
+
+	Type    OptionType
+	Default interface{}
+
+	// Min and Max limit the range of numeric options (inclusive).
+	// If both are 0 the range isn't limited.
+	Min, Max float64
+
+	// Check optionally performs additional validation of a value which
+	// already has the correct type.
+	Check func(value interface{}) error
+}
+
+// CommonOptions are the options supported by all composers.
+var CommonOptions = []Option{
+	{
+		Name:        "crop",
+		Description: "cropper deciding which part of the images is kept",
+		Type:        OptionString,
+		Default:     "center",
+		Check:       checkCropper,
+	},
+	{
+		Name:        "background",
+		Description: fmt.Sprintf("background drawn behind the images (%s)", strings.Join(Backgrounds, ", ")),
+		Type:        OptionString,
+		Default:     BackgroundNone,
+		Check:       checkBackground,
+	},
+	{
+		Name:        "compositing",
+		Description: fmt.Sprintf("how the edges of adjacent regions are drawn (%s)", strings.Join(CompositingModes, ", ")),
+		Type:        OptionString,
+		Default:     CompositingBlend,
+		Check:       checkCompositing,
+	},
+}
+
+// HasRange checks whether the range of the option is limited.
+func (o Option) HasRange() bool {
+	return o.Min != 0 || o.Max != 0
+}
+
+// An OptionError is returned when an option value is invalid.
+type OptionError struct {
+	Name   string
+	Reason string
+}
+
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("option %q: %s", e.Name, e.Reason)
+}
+
+func (o Option) errorf(format string, a ...interface{}) error {
+	return &OptionError{Name: o.Name, Reason: fmt.Sprintf(format, a...)}
+}
+
+func (o Option) checkRange(v float64) error {
+	if o.HasRange() && (v < o.Min || v > o.Max) {
+		return o.errorf("%v not in range [%v, %v]", v, o.Min, o.Max)
+	}
+
+	return nil
+}
+
+// Validate checks the given value and converts it to the type of the option.
+// Numeric values are converted between ints and floats as long as no
+// information is lost.
+func (o Option) Validate(value interface{}) (interface{}, error) {
+	value, err := o.validateType(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.Check != nil {
+		if err := o.Check(value); err != nil {
+			return nil, o.errorf("%v", err)
+		}
+	}
+
+	return value, nil
+}
+
+func (o Option) validateType(value interface{}) (interface{}, error) {
+	switch o.Type {
+	case OptionInt:
+		var i int
+		switch v := value.(type) {
+		case int:
+			i = v
+		case int64:
+			i = int(v)
+		case float64:
+			if v != math.Trunc(v) {
+				return nil, o.errorf("%v is not an integer", v)
+			}
+			i = int(v)
+		default:
+			return nil, o.errorf("expected int, got %T", value)
+		}
+
+		return i, o.checkRange(float64(i))
+
+	case OptionFloat:
+		var f float64
+		switch v := value.(type) {
+		case float64:
+			f = v
+		case float32:
+			f = float64(v)
+		case int:
+			f = float64(v)
+		case int64:
+			f = float64(v)
+		default:
+			return nil, o.errorf("expected float, got %T", value)
+		}
+
+		return f, o.checkRange(f)
+
+	case OptionBool:
+		b, ok := value.(bool)
+		if !ok {
+			return nil, o.errorf("expected bool, got %T", value)
+		}
+
+		return b, nil
+
+	case OptionString:
+		s, ok := value.(string)
+		if !ok {
+			return nil, o.errorf("expected string, got %T", value)
+		}
+
+		return s, nil
+
+	case OptionColor:
+		switch v := value.(type) {
+		case color.Color:
+			return v, nil
+		case string:
+			c, err := ParseColor(v)
+			if err != nil {
+				return nil, o.errorf("%v", err)
+			}
+
+			return c, nil
+		default:
+			return nil, o.errorf("expected color, got %T", value)
+		}
+
+	default:
+		return nil, o.errorf("unknown option type %v", o.Type)
+	}
+}
+
+// Parse parses the textual representation of a value for the option.
+func (o Option) Parse(s string) (interface{}, error) {
+	var value interface{}
+	var err error
+
+	switch o.Type {
+	case OptionInt:
+		value, err = strconv.Atoi(s)
+	case OptionFloat:
+		value, err = strconv.ParseFloat(s, 64)
+	case OptionBool:
+		value, err = strconv.ParseBool(s)
+	default:
+		value = s
+	}
+
+	if err != nil {
+		return nil, o.errorf("couldn't parse %q as %v", s, o.Type)
+	}
+
+	return o.Validate(value)
+}
+
+// Options holds the values for the options of a composer.
+type Options map[string]interface{}
+
+// Int returns the value of the int option with the given name.
+func (opts Options) Int(name string) int {
+	v, _ := opts[name].(int)
+	return v
+}
+
+// Float returns the value of the float option with the given name.
+func (opts Options) Float(name string) float64 {
+	v, _ := opts[name].(float64)
+	return v
+}
+
+// Bool returns the value of the bool option with the given name.
+func (opts Options) Bool(name string) bool {
+	v, _ := opts[name].(bool)
+	return v
+}
+
+// Str returns the value of the string option with the given name.
+func (opts Options) Str(name string) string {
+	v, _ := opts[name].(string)
+	return v
+}
+
+// Color returns the value of the color option with the given name.
+// If the option isn't set, color.Transparent is returned.
+func (opts Options) Color(name string) color.Color {
+	if v, ok := opts[name].(color.Color); ok {
+		return v
+	}
+
+	return color.Transparent
+}
@@ -0,0 +1,82 @@
+package mosaic
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestOption_Validate(t *testing.T) {
+	o := Option{Name: "count", Type: OptionInt, Min: 1, Max: 10}
+
+	v, err := o.Validate(3.)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, v)
+
+	_, err = o.Validate(3.5)
+	assert.IsType(t, &OptionError{}, err)
+
+	_, err = o.Validate(11)
+	assert.IsType(t, &OptionError{}, err)
+
+	_, err = o.Validate("3")
+	assert.IsType(t, &OptionError{}, err)
+
+	o = Option{Name: "ratio", Type: OptionFloat}
+	v, err = o.Validate(2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2., v)
+}
+
+func TestOption_Parse(t *testing.T) {
+	v, err := Option{Name: "b", Type: OptionBool}.Parse("true")
+	assert.NoError(t, err)
+	assert.Equal(t, true, v)
+
+	v, err = Option{Name: "f", Type: OptionFloat, Max: 1}.Parse(".5")
+	assert.NoError(t, err)
+	assert.Equal(t, .5, v)
+
+	_, err = Option{Name: "i", Type: OptionInt}.Parse("five")
+	assert.IsType(t, &OptionError{}, err)
+}
+
+func TestComposerInfo_ValidateOptions(t *testing.T) {
+	ci := ComposerInfo{
+		Id: "test",
+		Options: []Option{
+			{Name: "a", Type: OptionInt, Default: 1},
+			{Name: "b", Type: OptionFloat, Default: .5, Max: 1},
+		},
+	}
+
+	opts, err := ci.ValidateOptions(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, Options{"crop": "center", "background": "none", "compositing": "blend", "a": 1, "b": .5}, opts)
+
+	opts, err = ci.ParseOptions(map[string]string{"a": "4"})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, opts.Int("a"))
+	assert.Equal(t, .5, opts.Float("b"))
+
+	_, err = ci.ValidateOptions(Options{"c": 1})
+	assert.IsType(t, &OptionError{}, err)
+
+	_, err = ci.ValidateOptions(Options{"b": 2.})
+	assert.IsType(t, &OptionError{}, err)
+
+	_, err = ci.ValidateOptions(Options{"crop": "nonexistent"})
+	assert.IsType(t, &OptionError{}, err)
+
+	_, err = ci.ValidateOptions(Options{"background": "stripes"})
+	assert.IsType(t, &OptionError{}, err)
+
+	_, err = ci.ValidateOptions(Options{"compositing": "add"})
+	assert.IsType(t, &OptionError{}, err)
+}
+
+func TestBuiltinComposerOptionDefaults(t *testing.T) {
+	for _, composer := range GetComposers() {
+		_, err := composer.ValidateOptions(nil)
+		assert.NoError(t, err, composer.Id)
+	}
+}
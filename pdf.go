@@ -0,0 +1,124 @@
+package mosaic
+
+import (
+	"context"
+	"github.com/gieseladev/mosaic/pkg/geom"
+	"github.com/gieseladev/mosaic/pkg/pdf"
+	"image"
+)
+
+// pdfShape adds the shape as a path to the page. Shapes other than the
+// built-in ones are described by their outline.
+func pdfShape(page *pdf.Page, s Shape) {
+	switch s := s.(type) {
+	case RectShape:
+		page.RoundedRectangle(s.Rect, s.radius())
+	case CircleShape:
+		page.Circle(s.Center, s.Radius)
+	case SliceShape:
+		if s.full() {
+			page.Circle(s.Center, s.Radius)
+			return
+		}
+
+		// like drawSlice, the path goes from the center along the arc
+		page.MoveTo(s.Center)
+		page.Arc(s.Center, s.Radius, s.Start, s.End)
+		page.ClosePath()
+	case PolygonShape:
+		page.Polygon(s.Polygon)
+	default:
+		page.Polygon(s.Outline())
+	}
+}
+
+// renderPDF draws the layout stretched to the rectangle of the page. The
+// background image is drawn below the layout if it isn't nil.
+func renderPDF(ctx context.Context, page *pdf.Page, rect geom.Rectangle, layout Layout, background image.Image, opts Options, images ...image.Image) error {
+	for _, region := range layout.Regions {
+		if region.Image < 0 || region.Image >= len(images) {
+			return ErrInvalidImageCount
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// the layout is drawn in its own coordinates
+	page.Push()
+	page.Translate(rect.Min.X, rect.Min.Y)
+	page.Scale(rect.Width()/float64(layout.Width), rect.Height()/float64(layout.Height))
+
+	bounds := geom.Rect(0, 0, float64(layout.Width), float64(layout.Height))
+	if background != nil {
+		if err := page.DrawImage(background, bounds); err != nil {
+			return err
+		}
+	}
+
+	if layout.Background != nil {
+		if _, _, _, a := layout.Background.RGBA(); a > 0 {
+			page.SetColor(layout.Background)
+			page.Rectangle(bounds)
+			if background == nil {
+				page.Fill()
+			} else {
+				// like Render, only the gaps between the regions are
+				// filled, which works as long as the regions don't overlap
+				for _, region := range layout.Regions {
+					pdfShape(page, region.Shape)
+				}
+
+				page.FillEvenOdd()
+			}
+		}
+	}
+
+	cropper := opts.Cropper()
+	for _, region := range layout.Regions {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		cropped, rect := cropRegion(region, cropper, images)
+		if cropped == nil {
+			continue
+		}
+
+		page.Push()
+		if region.needsMask() {
+			pdfShape(page, region.Shape)
+			page.Clip()
+		}
+
+		if err := page.DrawImage(cropped, rectFromPixels(rect)); err != nil {
+			return err
+		}
+
+		page.Pop()
+	}
+
+	page.Pop()
+	return nil
+}
+
+// RenderPDF draws the layout stretched to the rectangle of the page. Like
+// RenderSVG the regions are clipped by their shapes and show the cropped
+// images in their original resolution.
+func RenderPDF(ctx context.Context, page *pdf.Page, rect geom.Rectangle, layout Layout, opts Options, images ...image.Image) error {
+	return renderPDF(ctx, page, rect, layout, nil, opts, images...)
+}
+
+// ComposePDF draws the composition with the given size in pixels
+// stretched to the rectangle of the page. Like ComposeContext it validates
+// the options and draws the background, which is embedded as an image.
+// ErrNoLayout is returned if the composer isn't a LayoutComposer.
+func (ci ComposerInfo) ComposePDF(ctx context.Context, page *pdf.Page, rect geom.Rectangle, width, height int, opts Options, images ...image.Image) error {
+	layout, background, opts, err := ci.vectorLayout(width, height, opts, images)
+	if err != nil {
+		return err
+	}
+
+	return renderPDF(ctx, page, rect, layout, background, opts, images...)
+}
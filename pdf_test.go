@@ -0,0 +1,104 @@
+package mosaic
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"github.com/gieseladev/mosaic/pkg/geom"
+	"github.com/gieseladev/mosaic/pkg/pdf"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"regexp"
+	"testing"
+)
+
+func TestRenderPDF(t *testing.T) {
+	left, right := geom.Rect(0, 0, 10, 20), geom.Rect(10, 0, 20, 20)
+	layout := Layout{
+		Width: 20, Height: 20,
+		Regions: []Region{
+			{Image: 0, Shape: RectShape{Rect: left}, Rect: left},
+			{Image: 1, Shape: SliceShape{Center: geom.Pt(10, 10), Radius: 10, Start: 0, End: geom.HalfPi}, Rect: right},
+		},
+	}
+
+	images := []image.Image{uniformImage(color.Black), uniformImage(color.White)}
+
+	page := pdf.NewPage(40, 40)
+	err := RenderPDF(context.Background(), page, geom.Rect(0, 0, 40, 40), layout, nil, images...)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var buf bytes.Buffer
+	if assert.NoError(t, page.Encode(&buf)) {
+		assert.Contains(t, buf.String(), "/XObject << /Im0 ")
+		assert.Contains(t, buf.String(), "/Im1 ")
+	}
+
+	assert.Equal(t, ErrInvalidImageCount, RenderPDF(context.Background(), page, geom.Rect(0, 0, 40, 40), layout, nil, images[0]))
+}
+
+func TestComposerInfo_ComposePDF(t *testing.T) {
+	composer, ok := GetComposer("circles-pie")
+	if !assert.True(t, ok) {
+		return
+	}
+
+	images := []image.Image{uniformImage(color.Black), uniformImage(color.White)}
+
+	page := pdf.NewPage(100, 100)
+	err := composer.ComposePDF(context.Background(), page, geom.Rect(0, 0, 100, 100), 32, 32, nil, images...)
+	assert.NoError(t, err)
+
+	err = composer.ComposePDF(context.Background(), page, geom.Rect(0, 0, 100, 100), 32, 32, Options{"background": "x"}, images...)
+	assert.Error(t, err)
+}
+
+// pdfContent encodes the page and returns its decompressed content stream.
+func pdfContent(t *testing.T, page *pdf.Page) string {
+	var buf bytes.Buffer
+	if !assert.NoError(t, page.Encode(&buf)) {
+		return ""
+	}
+
+	match := regexp.MustCompile(`(?s)/Filter /FlateDecode /Length \d+ >>\nstream\n(.*?)\nendstream`).FindSubmatch(buf.Bytes())
+	if !assert.NotNil(t, match) {
+		return ""
+	}
+
+	r, err := zlib.NewReader(bytes.NewReader(match[1]))
+	if !assert.NoError(t, err) {
+		return ""
+	}
+
+	content, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	return string(content)
+}
+
+func TestComposerInfo_ComposePDFGutters(t *testing.T) {
+	composer, ok := GetComposer("tiles-perfect")
+	if !assert.True(t, ok) {
+		return
+	}
+
+	images := []image.Image{uniformImage(color.Transparent), uniformImage(color.White)}
+	opts := Options{"gutter": 4, "gutter-color": "#00ff00"}
+
+	page := pdf.NewPage(100, 100)
+	if assert.NoError(t, composer.ComposePDF(context.Background(), page, geom.Rect(0, 0, 100, 100), 32, 32, opts, images...)) {
+		assert.Contains(t, pdfContent(t, page), "re\nf\n")
+	}
+
+	// with a background, the regions are left out of the gutter color
+	opts["background"] = BackgroundSolid
+	page = pdf.NewPage(100, 100)
+	if assert.NoError(t, composer.ComposePDF(context.Background(), page, geom.Rect(0, 0, 100, 100), 32, 32, opts, images...)) {
+		content := pdfContent(t, page)
+		assert.Contains(t, content, "f*\n")
+		assert.NotContains(t, content, "re\nf\n")
+	}
+}
@@ -0,0 +1,5 @@
+// Package apng implements an encoder for animated PNG images.
+//
+// The image/png package can only encode still images. Decoders without
+// support for animations show the first frame.
+package apng
@@ -0,0 +1,303 @@
+package apng
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"io"
+	"time"
+)
+
+const (
+	colorTypeRGB  = 2
+	colorTypeRGBA = 6
+
+	filterNone    = 0
+	filterSub     = 1
+	filterUp      = 2
+	filterAverage = 3
+	filterPaeth   = 4
+	nFilters      = 5
+
+	disposeNone = 0
+	blendSource = 0
+)
+
+var signature = []byte("\x89PNG\r\n\x1a\n")
+
+var (
+	// ErrNoFrames is returned when encoding an animation without frames.
+	ErrNoFrames = errors.New("apng: no frames")
+	// ErrSizeMismatch is returned when the frames don't have the same size.
+	ErrSizeMismatch = errors.New("apng: frames differ in size")
+)
+
+// APNG is an animation made up of full size frames, similar to gif.GIF.
+type APNG struct {
+	Image []image.Image
+	// Delay is how long each frame is shown.
+	Delay []time.Duration
+	// LoopCount is how often the animation is played. 0 loops forever.
+	LoopCount int
+}
+
+type encoder struct {
+	w   io.Writer
+	err error
+	seq uint32
+
+	width, height int
+	bpp           int
+}
+
+func (e *encoder) writeChunk(name string, data []byte) {
+	if e.err != nil {
+		return
+	}
+
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+	copy(header[4:], name)
+
+	crc := crc32.NewIEEE()
+	_, _ = crc.Write(header[4:])
+	_, _ = crc.Write(data)
+
+	footer := make([]byte, 4)
+	binary.BigEndian.PutUint32(footer, crc.Sum32())
+
+	for _, b := range [][]byte{header, data, footer} {
+		if _, e.err = e.w.Write(b); e.err != nil {
+			return
+		}
+	}
+}
+
+// nextSeq returns the next sequence number of the fcTL and fdAT chunks.
+func (e *encoder) nextSeq() uint32 {
+	seq := e.seq
+	e.seq++
+	return seq
+}
+
+// delayFraction converts the delay to the numerator and denominator of
+// the fcTL chunk.
+func delayFraction(delay time.Duration) (uint16, uint16) {
+	num, den := int64(delay/time.Millisecond), int64(1000)
+	for num > 0xffff && den > 1 {
+		num, den = num/10, den/10
+	}
+
+	if num > 0xffff {
+		num = 0xffff
+	}
+
+	return uint16(num), uint16(den)
+}
+
+func (e *encoder) writeFrameControl(delay time.Duration) {
+	data := make([]byte, 26)
+	binary.BigEndian.PutUint32(data[0:], e.nextSeq())
+	binary.BigEndian.PutUint32(data[4:], uint32(e.width))
+	binary.BigEndian.PutUint32(data[8:], uint32(e.height))
+	// the frames always start at the origin
+	num, den := delayFraction(delay)
+	binary.BigEndian.PutUint16(data[20:], num)
+	binary.BigEndian.PutUint16(data[22:], den)
+	data[24], data[25] = disposeNone, blendSource
+
+	e.writeChunk("fcTL", data)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+
+	return x
+}
+
+func paeth(a, b, c uint8) uint8 {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	} else if pb <= pc {
+		return b
+	}
+
+	return c
+}
+
+// filter writes the filtered row to out and returns the sum of the
+// absolute values of the result, which is used to pick the best filter.
+func filter(out, cur, prev []byte, bpp int, kind byte) int {
+	var sum int
+	for i := range cur {
+		var left, upLeft uint8
+		if i >= bpp {
+			left, upLeft = cur[i-bpp], prev[i-bpp]
+		}
+
+		var predicted uint8
+		switch kind {
+		case filterSub:
+			predicted = left
+		case filterUp:
+			predicted = prev[i]
+		case filterAverage:
+			predicted = uint8((int(left) + int(prev[i])) / 2)
+		case filterPaeth:
+			predicted = paeth(left, prev[i], upLeft)
+		}
+
+		out[i] = cur[i] - predicted
+		sum += abs(int(int8(out[i])))
+	}
+
+	return sum
+}
+
+// imageData returns the compressed and filtered pixels of the frame.
+func (e *encoder) imageData(m *image.NRGBA) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+
+	rowLen := e.width * e.bpp
+	cur, prev := make([]byte, rowLen), make([]byte, rowLen)
+	filtered := make([][]byte, nFilters)
+	for i := range filtered {
+		filtered[i] = make([]byte, rowLen+1)
+		filtered[i][0] = byte(i)
+	}
+
+	b := m.Bounds()
+	for y := 0; y < e.height; y++ {
+		row := m.Pix[m.PixOffset(b.Min.X, b.Min.Y+y):]
+		if e.bpp == 4 {
+			copy(cur, row[:rowLen])
+		} else {
+			for x := 0; x < e.width; x++ {
+				copy(cur[3*x:3*x+3], row[4*x:4*x+3])
+			}
+		}
+
+		best, bestSum := filterNone, -1
+		for kind := filterNone; kind < nFilters; kind++ {
+			if sum := filter(filtered[kind][1:], cur, prev, e.bpp, byte(kind)); bestSum < 0 || sum < bestSum {
+				best, bestSum = kind, sum
+			}
+		}
+
+		if _, err := zw.Write(filtered[best]); err != nil {
+			return nil, err
+		}
+
+		cur, prev = prev, cur
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func toNRGBA(m image.Image) *image.NRGBA {
+	if nrgba, ok := m.(*image.NRGBA); ok {
+		return nrgba
+	}
+
+	b := m.Bounds()
+	nrgba := image.NewNRGBA(b)
+	draw.Draw(nrgba, b, m, b.Min, draw.Src)
+	return nrgba
+}
+
+func opaque(m *image.NRGBA) bool {
+	b := m.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := m.Pix[m.PixOffset(b.Min.X, y):]
+		for x := 0; x < b.Dx(); x++ {
+			if row[4*x+3] != 0xff {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// EncodeAll writes the animation to w in the APNG format. All frames must
+// have the same size.
+func EncodeAll(w io.Writer, a *APNG) error {
+	if len(a.Image) == 0 {
+		return ErrNoFrames
+	}
+
+	if len(a.Image) != len(a.Delay) {
+		return errors.New("apng: mismatched image and delay lengths")
+	}
+
+	size := a.Image[0].Bounds().Size()
+	if size.X == 0 || size.Y == 0 {
+		return errors.New("apng: empty image")
+	}
+
+	frames := make([]*image.NRGBA, len(a.Image))
+	isOpaque := true
+	for i, m := range a.Image {
+		if m.Bounds().Size() != size {
+			return ErrSizeMismatch
+		}
+
+		frames[i] = toNRGBA(m)
+		isOpaque = isOpaque && opaque(frames[i])
+	}
+
+	e := &encoder{w: w, width: size.X, height: size.Y, bpp: 4}
+	colorType := byte(colorTypeRGBA)
+	if isOpaque {
+		e.bpp, colorType = 3, colorTypeRGB
+	}
+
+	if _, err := w.Write(signature); err != nil {
+		return err
+	}
+
+	header := make([]byte, 13)
+	binary.BigEndian.PutUint32(header[0:], uint32(size.X))
+	binary.BigEndian.PutUint32(header[4:], uint32(size.Y))
+	// 8 bits per channel without interlacing
+	header[8], header[9] = 8, colorType
+	e.writeChunk("IHDR", header)
+
+	animControl := make([]byte, 8)
+	binary.BigEndian.PutUint32(animControl[0:], uint32(len(frames)))
+	binary.BigEndian.PutUint32(animControl[4:], uint32(a.LoopCount))
+	e.writeChunk("acTL", animControl)
+
+	for i, frame := range frames {
+		data, err := e.imageData(frame)
+		if err != nil {
+			return err
+		}
+
+		e.writeFrameControl(a.Delay[i])
+		if i == 0 {
+			// the first frame doubles as the still image
+			e.writeChunk("IDAT", data)
+		} else {
+			seq := make([]byte, 4)
+			binary.BigEndian.PutUint32(seq, e.nextSeq())
+			e.writeChunk("fdAT", append(seq, data...))
+		}
+	}
+
+	e.writeChunk("IEND", nil)
+	return e.err
+}
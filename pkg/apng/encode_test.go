@@ -0,0 +1,153 @@
+package apng
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+type chunk struct {
+	Name string
+	Data []byte
+}
+
+// readChunks splits the PNG into its chunks and checks their CRCs.
+func readChunks(t *testing.T, data []byte) []chunk {
+	if !assert.Equal(t, signature, data[:len(signature)]) {
+		return nil
+	}
+
+	var chunks []chunk
+	for data = data[len(signature):]; len(data) > 0; {
+		length := binary.BigEndian.Uint32(data)
+		c := chunk{Name: string(data[4:8]), Data: data[8 : 8+length]}
+		assert.Equal(t, crc32.ChecksumIEEE(data[4:8+length]), binary.BigEndian.Uint32(data[8+length:]), "crc of %s", c.Name)
+
+		chunks = append(chunks, c)
+		data = data[12+length:]
+	}
+
+	return chunks
+}
+
+// encodeStill builds a PNG from the header and the image data of a frame.
+func encodeStill(header, data []byte) []byte {
+	var buf bytes.Buffer
+	e := encoder{w: &buf}
+	buf.Write(signature)
+	e.writeChunk("IHDR", header)
+	e.writeChunk("IDAT", data)
+	e.writeChunk("IEND", nil)
+	return buf.Bytes()
+}
+
+func randomImage(rng *rand.Rand, w, h int, alpha bool) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	rng.Read(img.Pix)
+	if !alpha {
+		for i := 3; i < len(img.Pix); i += 4 {
+			img.Pix[i] = 0xff
+		}
+	}
+
+	return img
+}
+
+func assertSameImage(t *testing.T, expected *image.NRGBA, actual image.Image) {
+	b := expected.Bounds()
+	if !assert.Equal(t, b, actual.Bounds()) {
+		return
+	}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if !assert.Equal(t, expected.NRGBAAt(x, y), color.NRGBAModel.Convert(actual.At(x, y)), "pixel (%d, %d)", x, y) {
+				return
+			}
+		}
+	}
+}
+
+func TestEncodeAll(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, alpha := range []bool{false, true} {
+		frames := []image.Image{
+			randomImage(rng, 13, 7, alpha),
+			randomImage(rng, 13, 7, alpha),
+			randomImage(rng, 13, 7, alpha),
+		}
+
+		var buf bytes.Buffer
+		err := EncodeAll(&buf, &APNG{
+			Image:     frames,
+			Delay:     []time.Duration{time.Second, 50 * time.Millisecond, 100 * time.Second},
+			LoopCount: 2,
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		// decoders without animation support show the first frame
+		still, err := png.Decode(bytes.NewReader(buf.Bytes()))
+		if assert.NoError(t, err) {
+			assertSameImage(t, frames[0].(*image.NRGBA), still)
+		}
+
+		chunks := readChunks(t, buf.Bytes())
+		var names []string
+		for _, c := range chunks {
+			names = append(names, c.Name)
+		}
+
+		if !assert.Equal(t, []string{"IHDR", "acTL", "fcTL", "IDAT", "fcTL", "fdAT", "fcTL", "fdAT", "IEND"}, names) {
+			return
+		}
+
+		assert.Equal(t, []byte{0, 0, 0, 3, 0, 0, 0, 2}, chunks[1].Data)
+
+		var seqs []uint32
+		for _, c := range chunks {
+			if c.Name == "fcTL" || c.Name == "fdAT" {
+				seqs = append(seqs, binary.BigEndian.Uint32(c.Data))
+			}
+		}
+
+		assert.Equal(t, []uint32{0, 1, 2, 3, 4}, seqs)
+
+		delay := func(fcTL []byte) (uint16, uint16) {
+			return binary.BigEndian.Uint16(fcTL[20:]), binary.BigEndian.Uint16(fcTL[22:])
+		}
+
+		num, den := delay(chunks[4].Data)
+		assert.Equal(t, [2]uint16{50, 1000}, [2]uint16{num, den})
+		num, den = delay(chunks[6].Data)
+		assert.Equal(t, [2]uint16{10000, 100}, [2]uint16{num, den})
+
+		for i, c := range []chunk{chunks[5], chunks[7]} {
+			decoded, err := png.Decode(bytes.NewReader(encodeStill(chunks[0].Data, c.Data[4:])))
+			if assert.NoError(t, err) {
+				assertSameImage(t, frames[i+1].(*image.NRGBA), decoded)
+			}
+		}
+	}
+}
+
+func TestEncodeAll_Errors(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Equal(t, ErrNoFrames, EncodeAll(&buf, &APNG{}))
+
+	small, large := image.NewNRGBA(image.Rect(0, 0, 1, 1)), image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	assert.Equal(t, ErrSizeMismatch, EncodeAll(&buf, &APNG{
+		Image: []image.Image{small, large},
+		Delay: []time.Duration{0, 0},
+	}))
+	assert.Error(t, EncodeAll(&buf, &APNG{Image: []image.Image{small}}))
+}
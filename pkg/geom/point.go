@@ -88,3 +88,9 @@ func (p Point) RotateAround(angle float64, origin Point) Point {
 		Rotate(angle).
 		Add(origin)
 }
+
+// Lerp linearly interpolates between the point and the other one. t = 0
+// returns the point and t = 1 the other one.
+func (p Point) Lerp(other Point, t float64) Point {
+	return p.Add(other.Sub(p).Mul(t))
+}
@@ -1,5 +1,7 @@
 package geom
 
+import "math"
+
 // A Polygon represents any polygon.
 type Polygon struct {
 	Vertices []Point
@@ -10,6 +12,19 @@ func Poly(points ...Point) Polygon {
 	return Polygon{Vertices: points}
 }
 
+// RegularPoly creates a regular polygon with the given amount of sides
+// whose vertices lie on a circle. The first vertex is placed at the given
+// angle.
+func RegularPoly(center Point, radius float64, sides int, angle float64) Polygon {
+	vertices := make([]Point, sides)
+	step := TwoPi / float64(sides)
+	for i := range vertices {
+		vertices[i] = PtFromPolar(radius, angle+float64(i)*step).Add(center)
+	}
+
+	return Poly(vertices...)
+}
+
 // Empty checks whether the polygon contains no points
 func (pg Polygon) Empty() bool {
 	return len(pg.Vertices) == 0
@@ -20,6 +35,24 @@ func (pg Polygon) BoundingRect() Rectangle {
 	return RectContainingPoints(pg.Vertices...)
 }
 
+// Contains checks whether the point lies inside the polygon.
+func (pg Polygon) Contains(p Point) bool {
+	inside := false
+
+	j := len(pg.Vertices) - 1
+	for i, a := range pg.Vertices {
+		b := pg.Vertices[j]
+		if (a.Y > p.Y) != (b.Y > p.Y) &&
+			p.X < (b.X-a.X)*(p.Y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+
+		j = i
+	}
+
+	return inside
+}
+
 // mapVertices returns a polygon with the given function applied to each
 // vertex.
 func (pg Polygon) mapVertices(f func(vertex Point) Point) Polygon {
@@ -73,3 +106,128 @@ func (pg Polygon) ScaleFrom(factor float64, origin Point) Polygon {
 func (pg Polygon) ScaleFromCenter(factor float64) Polygon {
 	return pg.ScaleFrom(factor, pg.Center())
 }
+
+// Area returns the signed area of the polygon. It's positive if the
+// vertices go clockwise on a canvas whose y axis points down.
+func (pg Polygon) Area() float64 {
+	var area float64
+	j := len(pg.Vertices) - 1
+	for i, a := range pg.Vertices {
+		b := pg.Vertices[j]
+		area += b.X*a.Y - a.X*b.Y
+		j = i
+	}
+
+	return area / 2
+}
+
+// Perimeter returns the length of the outline of the polygon.
+func (pg Polygon) Perimeter() float64 {
+	var length float64
+	j := len(pg.Vertices) - 1
+	for i, a := range pg.Vertices {
+		d, _ := a.Sub(pg.Vertices[j]).Polar()
+		length += d
+		j = i
+	}
+
+	return length
+}
+
+// Resample returns a polygon with n vertices spread evenly along the
+// outline of the polygon, starting at its first vertex.
+func (pg Polygon) Resample(n int) Polygon {
+	if pg.Empty() || n <= 0 {
+		return Polygon{}
+	}
+
+	perimeter := pg.Perimeter()
+	if perimeter == 0 {
+		vertices := make([]Point, n)
+		for i := range vertices {
+			vertices[i] = pg.Vertices[0]
+		}
+
+		return Poly(vertices...)
+	}
+
+	step := perimeter / float64(n)
+	vertices := make([]Point, 0, n)
+
+	// walk along the edges, placing a vertex every step
+	var walked float64
+	for i, a := range pg.Vertices {
+		b := pg.Vertices[(i+1)%len(pg.Vertices)]
+		edge, _ := b.Sub(a).Polar()
+
+		for len(vertices) < n && float64(len(vertices))*step <= walked+edge {
+			t := 0.
+			if edge > 0 {
+				t = (float64(len(vertices))*step - walked) / edge
+			}
+
+			vertices = append(vertices, a.Lerp(b, t))
+		}
+
+		walked += edge
+	}
+
+	// rounding errors may leave the last vertices out
+	for len(vertices) < n {
+		vertices = append(vertices, pg.Vertices[0])
+	}
+
+	return Poly(vertices...)
+}
+
+// Lerp interpolates between the polygon and the other one, see Point.Lerp.
+// Polygons with a different amount of vertices or a different winding are
+// resampled and reordered so that every vertex moves to the nearest
+// corresponding vertex of the other polygon.
+func (pg Polygon) Lerp(other Polygon, t float64) Polygon {
+	a, b := pg, other
+	if len(a.Vertices) != len(b.Vertices) {
+		n := len(a.Vertices)
+		if len(b.Vertices) > n {
+			n = len(b.Vertices)
+		}
+
+		a, b = a.Resample(n), b.Resample(n)
+	}
+
+	if a.Empty() {
+		return Polygon{}
+	}
+
+	if (a.Area() < 0) != (b.Area() < 0) {
+		reversed := make([]Point, len(b.Vertices))
+		for i, v := range b.Vertices {
+			reversed[len(reversed)-1-i] = v
+		}
+
+		b = Poly(reversed...)
+	}
+
+	// rotate the vertices of the other polygon to minimize the distance
+	// the vertices travel
+	n := len(a.Vertices)
+	bestOffset, bestDistance := 0, math.Inf(1)
+	for offset := 0; offset < n; offset++ {
+		var distance float64
+		for i, v := range a.Vertices {
+			d := b.Vertices[(i+offset)%n].Sub(v)
+			distance += d.X*d.X + d.Y*d.Y
+		}
+
+		if distance < bestDistance {
+			bestOffset, bestDistance = offset, distance
+		}
+	}
+
+	vertices := make([]Point, n)
+	for i, v := range a.Vertices {
+		vertices[i] = v.Lerp(b.Vertices[(i+bestOffset)%n], t)
+	}
+
+	return Poly(vertices...)
+}
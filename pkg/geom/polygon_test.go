@@ -2,9 +2,24 @@ package geom
 
 import (
 	"github.com/stretchr/testify/assert"
+	"math"
 	"testing"
 )
 
+func TestRegularPoly(t *testing.T) {
+	square := RegularPoly(Pt(1, 1), math.Sqrt2, 4, QuarterPi)
+	if assert.Len(t, square.Vertices, 4) {
+		assert.InDelta(t, 2, square.Vertices[0].X, 1e-9)
+		assert.InDelta(t, 2, square.Vertices[0].Y, 1e-9)
+		assert.InDelta(t, 0, square.Vertices[2].X, 1e-9)
+		assert.InDelta(t, 0, square.Vertices[2].Y, 1e-9)
+	}
+
+	hex := RegularPoly(Pt(0, 0), 1, 6, 0).BoundingRect()
+	assert.InDelta(t, 2, hex.Width(), 1e-9)
+	assert.InDelta(t, math.Sqrt(3), hex.Height(), 1e-9)
+}
+
 func TestPolygon_Center(t *testing.T) {
 	assert.Equal(t, Pt(1, 1), Poly(
 		Pt(0, 2), Pt(2, 2),
@@ -26,3 +41,46 @@ func TestPolygon_Translate(t *testing.T) {
 		Pt(1, 1), Pt(2, 1),
 	).ScaleFromCenter(2))
 }
+
+func TestPolygon_Contains(t *testing.T) {
+	triangle := Poly(Pt(0, 0), Pt(4, 0), Pt(0, 4))
+	assert.True(t, triangle.Contains(Pt(1, 1)))
+	assert.False(t, triangle.Contains(Pt(3, 3)))
+	assert.False(t, triangle.Contains(Pt(-1, 1)))
+	assert.False(t, Polygon{}.Contains(Pt(0, 0)))
+}
+
+func TestPolygon_Area(t *testing.T) {
+	square := Poly(Pt(0, 0), Pt(2, 0), Pt(2, 2), Pt(0, 2))
+	assert.Equal(t, 4., square.Area())
+	assert.Equal(t, -4., Poly(Pt(0, 0), Pt(0, 2), Pt(2, 2), Pt(2, 0)).Area())
+	assert.Equal(t, 8., square.Perimeter())
+}
+
+func TestPolygon_Resample(t *testing.T) {
+	square := Poly(Pt(0, 0), Pt(2, 0), Pt(2, 2), Pt(0, 2))
+
+	resampled := square.Resample(8)
+	assert.Equal(t, []Point{
+		Pt(0, 0), Pt(1, 0), Pt(2, 0), Pt(2, 1),
+		Pt(2, 2), Pt(1, 2), Pt(0, 2), Pt(0, 1),
+	}, resampled.Vertices)
+
+	assert.Len(t, square.Resample(7).Vertices, 7)
+	assert.True(t, Polygon{}.Resample(4).Empty())
+}
+
+func TestPolygon_Lerp(t *testing.T) {
+	a := Poly(Pt(0, 0), Pt(2, 0), Pt(2, 2), Pt(0, 2))
+	// same square starting at a different vertex with the opposite winding
+	b := Poly(Pt(4, 2), Pt(4, 4), Pt(6, 4), Pt(6, 2)).Translate(Pt(0, -2))
+
+	mid := a.Lerp(b, .5)
+	assert.Equal(t, []Point{Pt(2, 0), Pt(4, 0), Pt(4, 2), Pt(2, 2)}, mid.Vertices)
+
+	assert.Equal(t, a.Vertices, a.Lerp(b, 0).Vertices)
+
+	// a triangle morphing into a square is resampled
+	triangle := Poly(Pt(0, 0), Pt(2, 0), Pt(1, 2))
+	assert.Len(t, triangle.Lerp(a, .5).Vertices, 4)
+}
@@ -11,6 +11,16 @@ type Rectangle struct {
 	Min, Max Point
 }
 
+// Rect creates a new rectangle with the given corner coordinates.
+// The coordinates are swapped if necessary such that Min is the top left
+// corner.
+func Rect(x0, y0, x1, y1 float64) Rectangle {
+	return Rectangle{
+		Min: Pt(math.Min(x0, x1), math.Min(y0, y1)),
+		Max: Pt(math.Max(x0, x1), math.Max(y0, y1)),
+	}
+}
+
 // RectWithSideLengths creates a new rectangle with the given size.
 func RectWithSideLengths(p Point) Rectangle {
 	return Rectangle{Max: p}
@@ -89,6 +99,12 @@ func (r Rectangle) Center() Point {
 		Mul(.5)
 }
 
+// Contains checks whether the point lies inside the rectangle.
+func (r Rectangle) Contains(p Point) bool {
+	return r.Min.X <= p.X && p.X < r.Max.X &&
+		r.Min.Y <= p.Y && p.Y < r.Max.Y
+}
+
 // GrowToContain returns a new rectangle expanded to contain the given points.
 // If the rectangle already contains the points, a copy is returned.
 func (r Rectangle) GrowToContain(points ...Point) Rectangle {
@@ -113,6 +129,16 @@ func (r Rectangle) GrowToContain(points ...Point) Rectangle {
 	return r
 }
 
+// Inset returns a new rectangle shrunk by the given amount on all sides.
+// A negative amount grows the rectangle.
+func (r Rectangle) Inset(amount float64) Rectangle {
+	d := Pt(amount, amount)
+	return Rectangle{
+		Min: r.Min.Add(d),
+		Max: r.Max.Sub(d),
+	}
+}
+
 // Translate moves the rectangle around by the given point.
 func (r Rectangle) Translate(p Point) Rectangle {
 	return Rectangle{
@@ -177,3 +203,9 @@ func (r Rectangle) InnerCenterSquare() Rectangle {
 		Max: c.Add(diag),
 	}
 }
+
+// Lerp linearly interpolates the corners of the rectangle towards the
+// other one, see Point.Lerp.
+func (r Rectangle) Lerp(other Rectangle, t float64) Rectangle {
+	return Rectangle{Min: r.Min.Lerp(other.Min, t), Max: r.Max.Lerp(other.Max, t)}
+}
@@ -0,0 +1,30 @@
+package geom
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRect(t *testing.T) {
+	assert.Equal(t, Rectangle{Min: Pt(1, 2), Max: Pt(3, 4)}, Rect(3, 2, 1, 4))
+}
+
+func TestRectangle_Inset(t *testing.T) {
+	r := Rect(0, 0, 10, 6).Inset(2)
+	assert.Equal(t, Rect(2, 2, 8, 4), r)
+	assert.Equal(t, Rect(-1, -1, 11, 7), Rect(0, 0, 10, 6).Inset(-1))
+}
+
+func TestRectangle_Contains(t *testing.T) {
+	r := Rect(0, 0, 10, 6)
+	assert.True(t, r.Contains(Pt(0, 0)))
+	assert.True(t, r.Contains(Pt(9.5, 5)))
+	assert.False(t, r.Contains(Pt(10, 5)))
+	assert.False(t, r.Contains(Pt(5, -1)))
+}
+
+func TestRectangle_Lerp(t *testing.T) {
+	a, b := Rect(0, 0, 2, 2), Rect(2, 4, 6, 8)
+	assert.Equal(t, Rect(1, 2, 4, 5), a.Lerp(b, .5))
+	assert.Equal(t, b, a.Lerp(b, 1))
+}
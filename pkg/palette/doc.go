@@ -0,0 +1,3 @@
+// Package palette contains utilities for deriving color palettes from
+// images.
+package palette
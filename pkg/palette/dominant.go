@@ -0,0 +1,132 @@
+package palette
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+const (
+	// minClusters is the minimum amount of clusters the colors are grouped
+	// into by Dominant.
+	minClusters = 8
+	// kMeansIterations is the maximum amount of iterations used to refine
+	// the clusters.
+	kMeansIterations = 10
+)
+
+// cluster is a group of similar colors.
+type cluster struct {
+	center [3]float64
+	sum    [3]float64
+	size   int
+}
+
+func (c *cluster) distance(col color.NRGBA) float64 {
+	dr := c.center[0] - float64(col.R)
+	dg := c.center[1] - float64(col.G)
+	db := c.center[2] - float64(col.B)
+	return dr*dr + dg*dg + db*db
+}
+
+// kMeans groups the colors into clusters. The clusters are initialised
+// using the median cut algorithm.
+func kMeans(colors []color.NRGBA, k int) []cluster {
+	boxes := cut(colors, k)
+	clusters := make([]cluster, len(boxes))
+	for i, b := range boxes {
+		avg := b.average()
+		clusters[i].center = [3]float64{float64(avg.R), float64(avg.G), float64(avg.B)}
+	}
+
+	for iteration := 0; iteration < kMeansIterations; iteration++ {
+		for i := range clusters {
+			clusters[i].sum = [3]float64{}
+			clusters[i].size = 0
+		}
+
+		for _, col := range colors {
+			best, bestDist := 0, clusters[0].distance(col)
+			for i := 1; i < len(clusters); i++ {
+				if d := clusters[i].distance(col); d < bestDist {
+					best, bestDist = i, d
+				}
+			}
+
+			c := &clusters[best]
+			c.sum[0] += float64(col.R)
+			c.sum[1] += float64(col.G)
+			c.sum[2] += float64(col.B)
+			c.size++
+		}
+
+		moved := false
+		for i := range clusters {
+			c := &clusters[i]
+			if c.size == 0 {
+				continue
+			}
+
+			center := [3]float64{c.sum[0] / float64(c.size), c.sum[1] / float64(c.size), c.sum[2] / float64(c.size)}
+			if center != c.center {
+				c.center, moved = center, true
+			}
+		}
+
+		if !moved {
+			break
+		}
+	}
+
+	return clusters
+}
+
+// Dominant returns up to n colors which are the most common in the images,
+// ordered by how many pixels they represent. The images are weighted
+// equally regardless of their size and transparent pixels are ignored.
+//
+// The colors are found by k-means clustering initialised using median cut.
+func Dominant(n int, images ...image.Image) []color.NRGBA {
+	if n <= 0 || len(images) == 0 {
+		return nil
+	}
+
+	var colors []color.NRGBA
+	for _, img := range images {
+		for _, c := range samples(img, maxSamples/len(images)) {
+			if c.A > 0 {
+				colors = append(colors, c)
+			}
+		}
+	}
+
+	if len(colors) == 0 {
+		return nil
+	}
+
+	k := n
+	if k < minClusters {
+		k = minClusters
+	}
+
+	clusters := kMeans(colors, k)
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return clusters[i].size > clusters[j].size
+	})
+
+	var dominant []color.NRGBA
+	for _, c := range clusters {
+		if c.size == 0 || len(dominant) == n {
+			break
+		}
+
+		dominant = append(dominant, color.NRGBA{
+			R: uint8(c.center[0] + .5),
+			G: uint8(c.center[1] + .5),
+			B: uint8(c.center[2] + .5),
+			A: 0xff,
+		})
+	}
+
+	return dominant
+}
@@ -0,0 +1,30 @@
+package palette
+
+import (
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestDominant(t *testing.T) {
+	red := color.NRGBA{255, 0, 0, 255}
+	blue := color.NRGBA{0, 0, 255, 255}
+	green := color.NRGBA{0, 255, 0, 255}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 20, 20))
+	draw.Draw(img, image.Rect(0, 0, 15, 20), image.NewUniform(red), image.ZP, draw.Src)
+	draw.Draw(img, image.Rect(15, 0, 20, 20), image.NewUniform(blue), image.ZP, draw.Src)
+
+	assert.Equal(t, []color.NRGBA{red}, Dominant(1, img))
+	assert.Equal(t, []color.NRGBA{red, blue}, Dominant(4, img))
+
+	other := image.NewNRGBA(image.Rect(0, 0, 100, 100))
+	draw.Draw(other, other.Bounds(), image.NewUniform(green), image.ZP, draw.Src)
+	assert.Equal(t, []color.NRGBA{green, red, blue}, Dominant(3, img, other))
+
+	transparent := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	assert.Empty(t, Dominant(2, transparent))
+	assert.Empty(t, Dominant(0, img))
+}
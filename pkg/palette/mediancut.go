@@ -0,0 +1,181 @@
+package palette
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+// maxSamples is the maximum amount of pixels considered by the MedianCut
+// quantizer. Larger images are sampled uniformly.
+const maxSamples = 1 << 16
+
+// MedianCut is a draw.Quantizer which uses the median cut algorithm to
+// find the most representative colors of an image.
+type MedianCut struct{}
+
+// box is a set of colors which is split along its longest axis.
+type box []color.NRGBA
+
+func channel(c color.NRGBA, i int) uint8 {
+	switch i {
+	case 0:
+		return c.R
+	case 1:
+		return c.G
+	case 2:
+		return c.B
+	default:
+		return c.A
+	}
+}
+
+// longestAxis returns the channel with the largest range and its size.
+func (b box) longestAxis() (int, int) {
+	var lo, hi [4]uint8
+	for i := range lo {
+		lo[i] = 0xff
+	}
+
+	for _, c := range b {
+		for i := 0; i < 4; i++ {
+			v := channel(c, i)
+			if v < lo[i] {
+				lo[i] = v
+			}
+			if v > hi[i] {
+				hi[i] = v
+			}
+		}
+	}
+
+	axis, size := 0, -1
+	for i := range lo {
+		if s := int(hi[i]) - int(lo[i]); s > size {
+			axis, size = i, s
+		}
+	}
+
+	return axis, size
+}
+
+// average returns the mean color of the box.
+func (b box) average() color.NRGBA {
+	var sum [4]int
+	for _, c := range b {
+		sum[0] += int(c.R)
+		sum[1] += int(c.G)
+		sum[2] += int(c.B)
+		sum[3] += int(c.A)
+	}
+
+	n := len(b)
+	return color.NRGBA{
+		R: uint8((sum[0] + n/2) / n),
+		G: uint8((sum[1] + n/2) / n),
+		B: uint8((sum[2] + n/2) / n),
+		A: uint8((sum[3] + n/2) / n),
+	}
+}
+
+// samples returns up to max colors of the image.
+func samples(m image.Image, max int) []color.NRGBA {
+	b := m.Bounds()
+	step := 1
+	for b.Dx()*b.Dy()/(step*step) > max {
+		step++
+	}
+
+	colors := make([]color.NRGBA, 0, (b.Dx()/step+1)*(b.Dy()/step+1))
+	for y := b.Min.Y; y < b.Max.Y; y += step {
+		for x := b.Min.X; x < b.Max.X; x += step {
+			colors = append(colors, color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA))
+		}
+	}
+
+	return colors
+}
+
+// cut splits the colors into up to n boxes.
+func cut(colors []color.NRGBA, n int) []box {
+	boxes := []box{colors}
+	for len(boxes) < n {
+		// split the box with the largest range
+		best, bestAxis, bestSize := -1, 0, 0
+		for i, b := range boxes {
+			if len(b) < 2 {
+				continue
+			}
+
+			if axis, size := b.longestAxis(); size > bestSize {
+				best, bestAxis, bestSize = i, axis, size
+			}
+		}
+
+		if best < 0 {
+			break
+		}
+
+		b := boxes[best]
+		sort.Slice(b, func(i, j int) bool {
+			return channel(b[i], bestAxis) < channel(b[j], bestAxis)
+		})
+
+		median := len(b) / 2
+		boxes[best] = b[:median]
+		boxes = append(boxes, b[median:])
+	}
+
+	return boxes
+}
+
+// Quantize appends up to cap(p) - len(p) colors representing the image to
+// p.
+func (MedianCut) Quantize(p color.Palette, m image.Image) color.Palette {
+	n := cap(p) - len(p)
+	if n <= 0 {
+		return p
+	}
+
+	colors := samples(m, maxSamples)
+	if len(colors) == 0 {
+		return p
+	}
+
+	for _, b := range cut(colors, n) {
+		p = append(p, b.average())
+	}
+
+	return p
+}
+
+// Shared returns up to n colors representing all of the images using the
+// median cut algorithm. The images are weighted equally regardless of their
+// size. It's useful for animations whose frames should share a palette.
+func Shared(n int, images ...image.Image) color.Palette {
+	if n <= 0 || len(images) == 0 {
+		return nil
+	}
+
+	var colors []color.NRGBA
+	for _, img := range images {
+		colors = append(colors, samples(img, maxSamples/len(images))...)
+	}
+
+	if len(colors) == 0 {
+		return nil
+	}
+
+	// the median of a box may split identical colors, so some boxes can
+	// end up with the same average
+	seen := make(map[color.NRGBA]bool, n)
+	p := make(color.Palette, 0, n)
+	for _, b := range cut(colors, n) {
+		if c := b.average(); !seen[c] {
+			seen[c] = true
+			p = append(p, c)
+		}
+	}
+
+	return p
+}
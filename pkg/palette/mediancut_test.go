@@ -0,0 +1,40 @@
+package palette
+
+import (
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestMedianCut_Quantize(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 20, 20))
+	draw.Draw(img, image.Rect(0, 0, 10, 20), image.NewUniform(color.NRGBA{255, 0, 0, 255}), image.ZP, draw.Src)
+	draw.Draw(img, image.Rect(10, 0, 20, 20), image.NewUniform(color.NRGBA{0, 0, 255, 255}), image.ZP, draw.Src)
+
+	p := MedianCut{}.Quantize(make(color.Palette, 0, 16), img)
+	assert.Len(t, p, 2)
+	assert.Contains(t, p, color.NRGBA{255, 0, 0, 255})
+	assert.Contains(t, p, color.NRGBA{0, 0, 255, 255})
+
+	p = MedianCut{}.Quantize(make(color.Palette, 0, 1), img)
+	assert.Equal(t, color.Palette{color.NRGBA{128, 0, 128, 255}}, p)
+}
+
+func TestShared(t *testing.T) {
+	red := image.NewUniform(color.NRGBA{255, 0, 0, 255})
+	blue := image.NewUniform(color.NRGBA{0, 0, 255, 255})
+
+	a, b := image.NewNRGBA(image.Rect(0, 0, 10, 10)), image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	draw.Draw(a, a.Bounds(), red, image.ZP, draw.Src)
+	draw.Draw(b, b.Bounds(), blue, image.ZP, draw.Src)
+
+	p := Shared(16, a, b)
+	assert.Len(t, p, 2)
+	assert.Contains(t, p, color.NRGBA{255, 0, 0, 255})
+	assert.Contains(t, p, color.NRGBA{0, 0, 255, 255})
+
+	assert.Nil(t, Shared(16))
+	assert.Nil(t, Shared(0, a))
+}
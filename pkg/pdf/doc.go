@@ -0,0 +1,7 @@
+// Package pdf writes single page PDF documents made up of vector paths and
+// images.
+//
+// Coordinates are given in points (1/72 inch) with the origin in the top
+// left corner of the page and the y axis pointing down, like in the rest
+// of mosaic.
+package pdf
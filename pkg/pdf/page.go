@@ -0,0 +1,453 @@
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"github.com/gieseladev/mosaic/pkg/geom"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// PointsPerInch is the amount of points in an inch.
+const PointsPerInch = 72
+
+// DefaultJPEGQuality is the quality used for opaque images if the page
+// doesn't specify one.
+const DefaultJPEGQuality = 90
+
+// ErrEmptyPage is returned when encoding a page without a size.
+var ErrEmptyPage = errors.New("pdf: page has no size")
+
+// xObject is an encoded image.
+type xObject struct {
+	dict string
+	data []byte
+	// mask is the alpha channel of the image, it's nil for opaque images.
+	mask *xObject
+}
+
+// A Page is a single page PDF document. Paths are built using the path
+// methods and then filled, stroked or used as a clip path, similar to
+// gg.Context.
+type Page struct {
+	// Width and Height are the size of the page in points.
+	Width, Height float64
+
+	// TrimBox is the area of the finished page after trimming and BleedBox
+	// the area the contents extend to for trimming. They're omitted if
+	// they're empty.
+	TrimBox, BleedBox geom.Rectangle
+
+	// JPEGQuality is the quality of opaque images, which are embedded as
+	// JPEG. Images with transparency are compressed losslessly.
+	JPEGQuality int
+
+	content  bytes.Buffer
+	images   []xObject
+	alphas   []float64
+	hasPoint bool
+}
+
+// NewPage creates a new page with the given size in points.
+func NewPage(width, height float64) *Page {
+	return &Page{Width: width, Height: height, JPEGQuality: DefaultJPEGQuality}
+}
+
+// num formats the number for the content stream.
+func num(v float64) string {
+	v = math.Round(v*1e4) / 1e4
+	if v == 0 {
+		// avoid negative zero
+		return "0"
+	}
+
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func (p *Page) op(operator string, operands ...float64) {
+	for _, v := range operands {
+		p.content.WriteString(num(v))
+		p.content.WriteByte(' ')
+	}
+
+	p.content.WriteString(operator)
+	p.content.WriteByte('\n')
+}
+
+// Push saves the graphics state, including the clip path.
+func (p *Page) Push() {
+	p.op("q")
+}
+
+// Pop restores the graphics state saved by the last Push.
+func (p *Page) Pop() {
+	p.op("Q")
+}
+
+// Translate moves the origin of the following operations.
+func (p *Page) Translate(x, y float64) {
+	p.op("cm", 1, 0, 0, 1, x, y)
+}
+
+// Scale scales the following operations.
+func (p *Page) Scale(x, y float64) {
+	p.op("cm", x, 0, 0, y, 0, 0)
+}
+
+// MoveTo starts a new sub path at the point.
+func (p *Page) MoveTo(pt geom.Point) {
+	p.op("m", pt.X, pt.Y)
+	p.hasPoint = true
+}
+
+// LineTo adds a line to the point. If there's no current point it's
+// equivalent to MoveTo.
+func (p *Page) LineTo(pt geom.Point) {
+	if !p.hasPoint {
+		p.MoveTo(pt)
+		return
+	}
+
+	p.op("l", pt.X, pt.Y)
+}
+
+// CubicTo adds a cubic bezier curve with the control points c1 and c2.
+func (p *Page) CubicTo(c1, c2, pt geom.Point) {
+	if !p.hasPoint {
+		p.MoveTo(c1)
+	}
+
+	p.op("c", c1.X, c1.Y, c2.X, c2.Y, pt.X, pt.Y)
+}
+
+// ClosePath closes the current sub path.
+func (p *Page) ClosePath() {
+	if p.hasPoint {
+		p.op("h")
+	}
+}
+
+// Arc adds a circle arc going clockwise from the angle start to end. Like
+// gg.Context.DrawArc it's connected to the current point by a line.
+func (p *Page) Arc(center geom.Point, radius, start, end float64) {
+	p.LineTo(geom.PtFromPolar(radius, start).Add(center))
+
+	// bezier curves approximate arcs of up to a quarter circle well
+	segments := int(math.Ceil(math.Abs(end-start) / geom.HalfPi))
+	for i := 0; i < segments; i++ {
+		a0 := start + (end-start)*float64(i)/float64(segments)
+		a1 := start + (end-start)*float64(i+1)/float64(segments)
+		k := 4. / 3 * math.Tan((a1-a0)/4) * radius
+
+		p0 := geom.PtFromPolar(radius, a0).Add(center)
+		p3 := geom.PtFromPolar(radius, a1).Add(center)
+		c1 := p0.Add(geom.Pt(-math.Sin(a0), math.Cos(a0)).Mul(k))
+		c2 := p3.Sub(geom.Pt(-math.Sin(a1), math.Cos(a1)).Mul(k))
+		p.CubicTo(c1, c2, p3)
+	}
+}
+
+// Circle adds a circle as a new sub path.
+func (p *Page) Circle(center geom.Point, radius float64) {
+	p.MoveTo(center.Add(geom.Pt(radius, 0)))
+	p.Arc(center, radius, 0, geom.TwoPi)
+	p.ClosePath()
+}
+
+// Rectangle adds a rectangle as a new sub path.
+func (p *Page) Rectangle(r geom.Rectangle) {
+	p.op("re", r.Min.X, r.Min.Y, r.Width(), r.Height())
+	p.hasPoint = true
+}
+
+// RoundedRectangle adds a rectangle with rounded corners as a new sub
+// path.
+func (p *Page) RoundedRectangle(r geom.Rectangle, radius float64) {
+	if radius <= 0 {
+		p.Rectangle(r)
+		return
+	}
+
+	inner := r.Inset(radius)
+	p.MoveTo(geom.Pt(inner.Min.X, r.Min.Y))
+	p.Arc(inner.TopRight(), radius, 3*geom.HalfPi, geom.TwoPi)
+	p.Arc(inner.BottomRight(), radius, 0, geom.HalfPi)
+	p.Arc(inner.BottomLeft(), radius, geom.HalfPi, math.Pi)
+	p.Arc(inner.TopLeft(), radius, math.Pi, 3*geom.HalfPi)
+	p.ClosePath()
+}
+
+// Polygon adds the polygon as a new sub path.
+func (p *Page) Polygon(pg geom.Polygon) {
+	if pg.Empty() {
+		return
+	}
+
+	p.MoveTo(pg.Vertices[0])
+	for _, v := range pg.Vertices[1:] {
+		p.LineTo(v)
+	}
+
+	p.ClosePath()
+}
+
+// SetColor sets the color used to fill and stroke paths.
+func (p *Page) SetColor(c color.Color) {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	r, g, b := float64(n.R)/0xff, float64(n.G)/0xff, float64(n.B)/0xff
+	p.op("rg", r, g, b)
+	p.op("RG", r, g, b)
+
+	alpha := math.Round(float64(n.A)/0xff*1e3) / 1e3
+	index := -1
+	for i, a := range p.alphas {
+		if a == alpha {
+			index = i
+		}
+	}
+
+	if index < 0 {
+		index = len(p.alphas)
+		p.alphas = append(p.alphas, alpha)
+	}
+
+	fmt.Fprintf(&p.content, "/GS%d gs\n", index)
+}
+
+// SetLineWidth sets the width of stroked lines.
+func (p *Page) SetLineWidth(width float64) {
+	p.op("w", width)
+}
+
+// Fill fills the current path and clears it.
+func (p *Page) Fill() {
+	p.op("f")
+	p.hasPoint = false
+}
+
+// FillEvenOdd fills the current path using the even-odd rule, so areas
+// enclosed by the path an even number of times stay empty, and clears it.
+func (p *Page) FillEvenOdd() {
+	p.op("f*")
+	p.hasPoint = false
+}
+
+// Stroke strokes the current path and clears it.
+func (p *Page) Stroke() {
+	p.op("S")
+	p.hasPoint = false
+}
+
+// Clip intersects the clip path with the current path and clears it. Use
+// Push and Pop to reset the clip path.
+func (p *Page) Clip() {
+	p.op("W n")
+	p.hasPoint = false
+}
+
+// compress deflates the data.
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func toNRGBA(m image.Image) *image.NRGBA {
+	if nrgba, ok := m.(*image.NRGBA); ok {
+		return nrgba
+	}
+
+	b := m.Bounds()
+	nrgba := image.NewNRGBA(b)
+	draw.Draw(nrgba, b, m, b.Min, draw.Src)
+	return nrgba
+}
+
+// encodeImage converts the image to an image XObject.
+func (p *Page) encodeImage(m image.Image) (xObject, error) {
+	img := toNRGBA(m)
+	b := img.Bounds()
+	dict := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /BitsPerComponent 8", b.Dx(), b.Dy())
+
+	if img.Opaque() {
+		quality := p.JPEGQuality
+		if quality <= 0 {
+			quality = DefaultJPEGQuality
+		}
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return xObject{}, err
+		}
+
+		return xObject{dict: dict + " /ColorSpace /DeviceRGB /Filter /DCTDecode", data: buf.Bytes()}, nil
+	}
+
+	rgb := make([]byte, 0, 3*b.Dx()*b.Dy())
+	alpha := make([]byte, 0, b.Dx()*b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(b.Min.X, y):]
+		for x := 0; x < b.Dx(); x++ {
+			rgb = append(rgb, row[4*x:4*x+3]...)
+			alpha = append(alpha, row[4*x+3])
+		}
+	}
+
+	rgb, err := compress(rgb)
+	if err != nil {
+		return xObject{}, err
+	}
+
+	alpha, err = compress(alpha)
+	if err != nil {
+		return xObject{}, err
+	}
+
+	return xObject{
+		dict: dict + " /ColorSpace /DeviceRGB /Filter /FlateDecode",
+		data: rgb,
+		mask: &xObject{dict: dict + " /ColorSpace /DeviceGray /Filter /FlateDecode", data: alpha},
+	}, nil
+}
+
+// DrawImage draws the image stretched to the rectangle.
+func (p *Page) DrawImage(img image.Image, r geom.Rectangle) error {
+	if img.Bounds().Empty() {
+		return nil
+	}
+
+	obj, err := p.encodeImage(img)
+	if err != nil {
+		return err
+	}
+
+	p.images = append(p.images, obj)
+
+	// images are drawn into the unit square with the first row at the top
+	// of the PDF coordinate system, which is flipped here.
+	p.Push()
+	p.op("cm", r.Width(), 0, 0, -r.Height(), r.Min.X, r.Max.Y)
+	fmt.Fprintf(&p.content, "/Im%d Do\n", len(p.images)-1)
+	p.Pop()
+	return nil
+}
+
+// box formats the rectangle as a PDF rectangle with the origin at the
+// bottom left.
+func (p *Page) box(r geom.Rectangle) string {
+	return fmt.Sprintf("[%s %s %s %s]", num(r.Min.X), num(p.Height-r.Max.Y), num(r.Max.X), num(p.Height-r.Min.Y))
+}
+
+// countingWriter keeps track of the offset for the cross reference table.
+type countingWriter struct {
+	w      *bufio.Writer
+	offset int
+}
+
+func (cw *countingWriter) Write(b []byte) (int, error) {
+	n, err := cw.w.Write(b)
+	cw.offset += n
+	return n, err
+}
+
+// Encode writes the page as a PDF document to w.
+func (p *Page) Encode(w io.Writer) error {
+	if p.Width <= 0 || p.Height <= 0 {
+		return ErrEmptyPage
+	}
+
+	// the content stream flips the y axis so the origin is at the top
+	content := fmt.Sprintf("1 0 0 -1 0 %s cm\n", num(p.Height)) + p.content.String()
+	compressed, err := compress([]byte(content))
+	if err != nil {
+		return err
+	}
+
+	// the catalog, pages, page and content are followed by the images and
+	// the graphics states
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"", // the page is filled in once the resources are numbered
+	}
+	streams := map[int][]byte{}
+
+	addStream := func(dict string, data []byte) int {
+		objects = append(objects, fmt.Sprintf("<< %s /Length %d >>", dict, len(data)))
+		streams[len(objects)] = data
+		return len(objects)
+	}
+
+	contentRef := addStream("/Filter /FlateDecode", compressed)
+
+	var xObjects []string
+	for i, img := range p.images {
+		dict := img.dict
+		if img.mask != nil {
+			dict += fmt.Sprintf(" /SMask %d 0 R", addStream(img.mask.dict, img.mask.data))
+		}
+
+		xObjects = append(xObjects, fmt.Sprintf("/Im%d %d 0 R", i, addStream(dict, img.data)))
+	}
+
+	var states []string
+	for i, alpha := range p.alphas {
+		objects = append(objects, fmt.Sprintf("<< /Type /ExtGState /ca %s /CA %s >>", num(alpha), num(alpha)))
+		states = append(states, fmt.Sprintf("/GS%d %d 0 R", i, len(objects)))
+	}
+
+	page := fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s]", num(p.Width), num(p.Height))
+	if p.TrimBox.Width() > 0 && p.TrimBox.Height() > 0 {
+		page += " /TrimBox " + p.box(p.TrimBox)
+	}
+	if p.BleedBox.Width() > 0 && p.BleedBox.Height() > 0 {
+		page += " /BleedBox " + p.box(p.BleedBox)
+	}
+
+	page += fmt.Sprintf(" /Resources << /XObject << %s >> /ExtGState << %s >> >> /Contents %d 0 R >>",
+		strings.Join(xObjects, " "), strings.Join(states, " "), contentRef)
+	objects[2] = page
+
+	bw := bufio.NewWriter(w)
+	cw := &countingWriter{w: bw}
+	// the binary comment marks the file as binary for transfer programs
+	_, _ = io.WriteString(cw, "%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = cw.offset
+		_, _ = fmt.Fprintf(cw, "%d 0 obj\n%s\n", i+1, obj)
+		if data, ok := streams[i+1]; ok {
+			_, _ = io.WriteString(cw, "stream\n")
+			_, _ = cw.Write(data)
+			_, _ = io.WriteString(cw, "\nendstream\n")
+		}
+		_, _ = io.WriteString(cw, "endobj\n")
+	}
+
+	xref := cw.offset
+	_, _ = fmt.Fprintf(cw, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		_, _ = fmt.Fprintf(cw, "%010d 00000 n \n", offset)
+	}
+
+	_, _ = fmt.Fprintf(cw, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return bw.Flush()
+}
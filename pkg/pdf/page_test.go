@@ -0,0 +1,114 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"github.com/gieseladev/mosaic/pkg/geom"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+// checkXref checks that the cross reference table points to the objects.
+func checkXref(t *testing.T, data []byte) {
+	start := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(data)
+	if !assert.NotNil(t, start, "startxref") {
+		return
+	}
+
+	offset, _ := strconv.Atoi(string(start[1]))
+	if !assert.True(t, bytes.HasPrefix(data[offset:], []byte("xref\n"))) {
+		return
+	}
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n \n`).FindAllSubmatch(data[offset:], -1)
+	assert.NotEmpty(t, entries)
+	for i, entry := range entries {
+		pos, _ := strconv.Atoi(string(entry[1]))
+		assert.True(t, bytes.HasPrefix(data[pos:], []byte(strconv.Itoa(i+1)+" 0 obj\n")), "object %d", i+1)
+	}
+}
+
+// contentStream returns the decompressed content stream of the page.
+func contentStream(t *testing.T, data []byte) string {
+	match := regexp.MustCompile(`(?s)/Filter /FlateDecode /Length \d+ >>\nstream\n(.*?)\nendstream`).FindSubmatch(data)
+	if !assert.NotNil(t, match) {
+		return ""
+	}
+
+	r, err := zlib.NewReader(bytes.NewReader(match[1]))
+	if !assert.NoError(t, err) {
+		return ""
+	}
+
+	content, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	return string(content)
+}
+
+func TestPage_Encode(t *testing.T) {
+	page := NewPage(100, 50)
+	page.TrimBox = geom.Rect(10, 10, 90, 40)
+	page.BleedBox = geom.Rect(5, 5, 95, 45)
+
+	page.SetColor(color.NRGBA{R: 0xff, A: 0x80})
+	page.Rectangle(geom.Rect(0, 0, 100, 50))
+	page.Fill()
+
+	page.Rectangle(geom.Rect(0, 0, 10, 10))
+	page.Rectangle(geom.Rect(2, 2, 8, 8))
+	page.FillEvenOdd()
+
+	page.Push()
+	page.Circle(geom.Pt(25, 25), 20)
+	page.Clip()
+
+	opaque := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for i := range opaque.Pix {
+		opaque.Pix[i] = 0xff
+	}
+
+	assert.NoError(t, page.DrawImage(opaque, geom.Rect(5, 5, 45, 45)))
+	page.Pop()
+
+	assert.NoError(t, page.DrawImage(image.NewNRGBA(image.Rect(0, 0, 2, 2)), geom.Rect(50, 0, 100, 50)))
+
+	var buf bytes.Buffer
+	if !assert.NoError(t, page.Encode(&buf)) {
+		return
+	}
+
+	data := buf.Bytes()
+	assert.True(t, bytes.HasPrefix(data, []byte("%PDF-1.4\n")))
+	checkXref(t, data)
+
+	assert.Contains(t, buf.String(), "/MediaBox [0 0 100 50] /TrimBox [10 10 90 40] /BleedBox [5 5 95 45]")
+	assert.Contains(t, buf.String(), "/ExtGState << /GS0 ")
+	assert.Contains(t, buf.String(), "/ca 0.502 /CA 0.502")
+	assert.Contains(t, buf.String(), "/Filter /DCTDecode")
+	assert.Contains(t, buf.String(), "/SMask ")
+
+	content := contentStream(t, data)
+	assert.Contains(t, content, "1 0 0 -1 0 50 cm\n")
+	assert.Contains(t, content, "1 0 0 rg\n1 0 0 RG\n/GS0 gs\n0 0 100 50 re\nf\n")
+	assert.Contains(t, content, "re\nf*\n")
+	assert.Contains(t, content, "W n\n")
+	assert.Contains(t, content, "q\n40 0 0 -40 5 45 cm\n/Im0 Do\nQ\n")
+	assert.Contains(t, content, "/Im1 Do\n")
+}
+
+func TestPage_Arc(t *testing.T) {
+	page := NewPage(10, 10)
+	page.Arc(geom.Pt(0, 0), 1, 0, geom.HalfPi)
+
+	assert.Equal(t, "1 0 m\n1 0.5523 0.5523 1 0 1 c\n", page.content.String())
+}
+
+func TestPage_EncodeEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Equal(t, ErrEmptyPage, (&Page{}).Encode(&buf))
+}
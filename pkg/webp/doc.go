@@ -0,0 +1,4 @@
+// Package webp implements an encoder for lossless WebP images.
+//
+// The golang.org/x/image/webp package can only decode WebP images.
+package webp
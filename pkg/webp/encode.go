@@ -0,0 +1,554 @@
+package webp
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"io"
+	"math/bits"
+)
+
+const (
+	// maxDimension is the maximum width and height of a lossless WebP image.
+	maxDimension = 1 << 14
+
+	nLiteralCodes  = 256
+	nLengthCodes   = 24
+	nDistanceCodes = 40
+
+	// minMatch is the minimum length of a backward reference.
+	minMatch = 3
+	// maxLength is the maximum length of a backward reference.
+	maxLength = 4096
+	// distanceMapSize is the amount of distance codes which are mapped to
+	// neighbouring pixels.
+	distanceMapSize = 120
+	// maxDistance is the maximum distance of a backward reference.
+	maxDistance = 1<<20 - distanceMapSize
+
+	// maxCodeLength is the maximum length of a prefix code.
+	maxCodeLength = 15
+	// maxCodeLengthCodeLength is the maximum length of the prefix code used
+	// to encode code lengths.
+	maxCodeLengthCodeLength = 7
+
+	hashBits = 16
+)
+
+// codeLengthCodeOrder is the order in which the code lengths of the code
+// length code are written.
+var codeLengthCodeOrder = [19]int{
+	17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+}
+
+// ErrTooLarge is returned when the image exceeds the maximum dimensions of
+// the lossless WebP format.
+var ErrTooLarge = errors.New("webp: image too large")
+
+// bitWriter writes bits starting with the least significant bit.
+type bitWriter struct {
+	buf  []byte
+	acc  uint64
+	nAcc uint
+}
+
+func (b *bitWriter) write(v uint32, n uint) {
+	b.acc |= uint64(v) << b.nAcc
+	b.nAcc += n
+
+	for b.nAcc >= 8 {
+		b.buf = append(b.buf, byte(b.acc))
+		b.acc >>= 8
+		b.nAcc -= 8
+	}
+}
+
+func (b *bitWriter) bytes() []byte {
+	if b.nAcc > 0 {
+		b.buf = append(b.buf, byte(b.acc))
+		b.acc, b.nAcc = 0, 0
+	}
+
+	return b.buf
+}
+
+// prefixCode is a canonical prefix code.
+type prefixCode struct {
+	lengths []int
+	// codes contains the bit reversed codes ready to be written.
+	codes []uint32
+	// single is true if only a single symbol is used, in which case no bits
+	// are written at all.
+	single bool
+}
+
+func (c *prefixCode) write(b *bitWriter, symbol int) {
+	if !c.single {
+		b.write(c.codes[symbol], uint(c.lengths[symbol]))
+	}
+}
+
+// huffmanNode is a node of a Huffman tree.
+type huffmanNode struct {
+	freq        int
+	symbol      int
+	left, right *huffmanNode
+}
+
+// nodeHeap is a min heap of Huffman nodes.
+type nodeHeap []*huffmanNode
+
+func (h *nodeHeap) push(n *huffmanNode) {
+	*h = append(*h, n)
+	s := *h
+	for i := len(s) - 1; i > 0; {
+		parent := (i - 1) / 2
+		if s[parent].freq <= s[i].freq {
+			break
+		}
+
+		s[parent], s[i] = s[i], s[parent]
+		i = parent
+	}
+}
+
+func (h *nodeHeap) pop() *huffmanNode {
+	s := *h
+	n := s[0]
+	last := len(s) - 1
+	s[0] = s[last]
+	s = s[:last]
+
+	for i := 0; ; {
+		smallest := i
+		if l := 2*i + 1; l < len(s) && s[l].freq < s[smallest].freq {
+			smallest = l
+		}
+		if r := 2*i + 2; r < len(s) && s[r].freq < s[smallest].freq {
+			smallest = r
+		}
+		if smallest == i {
+			break
+		}
+
+		s[i], s[smallest] = s[smallest], s[i]
+		i = smallest
+	}
+
+	*h = s
+	return n
+}
+
+// codeLengths calculates the lengths of a Huffman code for the given
+// symbol frequencies which don't exceed maxLength.
+func codeLengths(freqs []int, maxLength int) []int {
+	freqs = append([]int(nil), freqs...)
+	lengths := make([]int, len(freqs))
+
+	for {
+		h := make(nodeHeap, 0, len(freqs))
+		for symbol, f := range freqs {
+			if f > 0 {
+				h.push(&huffmanNode{freq: f, symbol: symbol})
+			}
+		}
+
+		if len(h) < 2 {
+			// a single symbol doesn't need any bits, but it still has to
+			// have a non-zero length.
+			symbol := 0
+			if len(h) == 1 {
+				symbol = h[0].symbol
+			}
+
+			lengths[symbol] = 1
+			return lengths
+		}
+
+		for len(h) > 1 {
+			a, b := h.pop(), h.pop()
+			h.push(&huffmanNode{freq: a.freq + b.freq, left: a, right: b})
+		}
+
+		tooLong := false
+		var assign func(n *huffmanNode, depth int)
+		assign = func(n *huffmanNode, depth int) {
+			if n.left == nil {
+				lengths[n.symbol] = depth
+				tooLong = tooLong || depth > maxLength
+				return
+			}
+
+			assign(n.left, depth+1)
+			assign(n.right, depth+1)
+		}
+		assign(h[0], 0)
+
+		if !tooLong {
+			return lengths
+		}
+
+		// flatten the distribution and try again
+		for i, f := range freqs {
+			if f > 0 {
+				freqs[i] = (f + 1) / 2
+			}
+		}
+	}
+}
+
+// newPrefixCode creates a canonical prefix code for the given frequencies.
+func newPrefixCode(freqs []int, maxLength int) *prefixCode {
+	lengths := codeLengths(freqs, maxLength)
+
+	var used int
+	var blCount [maxCodeLength + 1]int
+	for _, l := range lengths {
+		if l > 0 {
+			blCount[l]++
+			used++
+		}
+	}
+
+	var nextCode [maxCodeLength + 2]uint32
+	var code uint32
+	for l := 1; l <= maxCodeLength+1; l++ {
+		code = (code + uint32(blCount[l-1])) << 1
+		nextCode[l] = code
+	}
+
+	codes := make([]uint32, len(lengths))
+	for symbol, l := range lengths {
+		if l > 0 {
+			codes[symbol] = bits.Reverse32(nextCode[l]) >> (32 - uint(l))
+			nextCode[l]++
+		}
+	}
+
+	return &prefixCode{lengths: lengths, codes: codes, single: used == 1}
+}
+
+// codeLengthToken is a symbol of the code length code with its extra bits.
+type codeLengthToken struct {
+	symbol    int
+	extra     uint32
+	extraBits uint
+}
+
+// codeLengthTokens run-length encodes the code lengths.
+func codeLengthTokens(lengths []int) []codeLengthToken {
+	var tokens []codeLengthToken
+
+	for i := 0; i < len(lengths); {
+		l := lengths[i]
+		run := 1
+		for i+run < len(lengths) && lengths[i+run] == l {
+			run++
+		}
+		i += run
+
+		if l == 0 {
+			for run >= 11 {
+				n := run
+				if n > 138 {
+					n = 138
+				}
+				tokens = append(tokens, codeLengthToken{18, uint32(n - 11), 7})
+				run -= n
+			}
+
+			if run >= 3 {
+				tokens = append(tokens, codeLengthToken{17, uint32(run - 3), 3})
+				run = 0
+			}
+		} else {
+			tokens = append(tokens, codeLengthToken{symbol: l})
+			run--
+
+			for run >= 3 {
+				n := run
+				if n > 6 {
+					n = 6
+				}
+				tokens = append(tokens, codeLengthToken{16, uint32(n - 3), 2})
+				run -= n
+			}
+		}
+
+		for ; run > 0; run-- {
+			tokens = append(tokens, codeLengthToken{symbol: l})
+		}
+	}
+
+	return tokens
+}
+
+// writePrefixCode writes the code lengths of the prefix code.
+func writePrefixCode(b *bitWriter, c *prefixCode) {
+	tokens := codeLengthTokens(c.lengths)
+
+	var freqs [len(codeLengthCodeOrder)]int
+	for _, t := range tokens {
+		freqs[t.symbol]++
+	}
+
+	clCode := newPrefixCode(freqs[:], maxCodeLengthCodeLength)
+
+	nCodes := 4
+	for i, symbol := range codeLengthCodeOrder {
+		if clCode.lengths[symbol] > 0 && i+1 > nCodes {
+			nCodes = i + 1
+		}
+	}
+
+	// normal code
+	b.write(0, 1)
+	b.write(uint32(nCodes-4), 4)
+	for _, symbol := range codeLengthCodeOrder[:nCodes] {
+		b.write(uint32(clCode.lengths[symbol]), 3)
+	}
+
+	// all symbols are written
+	b.write(0, 1)
+
+	for _, t := range tokens {
+		clCode.write(b, t.symbol)
+		if t.extraBits > 0 {
+			b.write(t.extra, t.extraBits)
+		}
+	}
+}
+
+// prefixEncode splits a length or distance value into its prefix symbol and
+// the extra bits.
+func prefixEncode(v int) (symbol int, extra uint32, extraBits uint) {
+	n := v - 1
+	if n < 4 {
+		return n, 0, 0
+	}
+
+	highest := uint(bits.Len(uint(n)) - 1)
+	second := (n >> (highest - 1)) & 1
+	extraBits = highest - 1
+
+	return int(2*highest) + second, uint32(n) & (1<<extraBits - 1), extraBits
+}
+
+// token is either a literal pixel or a backward reference.
+type token struct {
+	argb uint32
+	// length is 0 for literals.
+	length   int
+	distCode int
+}
+
+// distanceCode returns the distance code for the given distance.
+// The short codes for the pixel above and to the left are used if
+// possible.
+func distanceCode(dist, width int) int {
+	switch dist {
+	case width:
+		return 1
+	case 1:
+		return 2
+	default:
+		return dist + distanceMapSize
+	}
+}
+
+func hashAt(argb []uint32, i int) uint32 {
+	h := argb[i]*0x1e35a7bd ^ argb[i+1]*0x9e3779b1 ^ argb[i+2]*0x85ebca6b
+	return h >> (32 - hashBits)
+}
+
+// tokenize finds backward references in the pixels.
+func tokenize(argb []uint32, width int) []token {
+	n := len(argb)
+	tokens := make([]token, 0, n/2)
+
+	var head [1 << hashBits]int32
+	for i := range head {
+		head[i] = -1
+	}
+
+	insert := func(i int) {
+		if i+minMatch <= n {
+			head[hashAt(argb, i)] = int32(i)
+		}
+	}
+
+	matchLength := func(candidate, i int) int {
+		l := 0
+		for i+l < n && l < maxLength && argb[candidate+l] == argb[i+l] {
+			l++
+		}
+
+		return l
+	}
+
+	for i := 0; i < n; {
+		bestLength, bestDist := 0, 0
+
+		candidates := [3]int{i - 1, i - width, -1}
+		if i+minMatch <= n {
+			candidates[2] = int(head[hashAt(argb, i)])
+		}
+
+		for _, c := range candidates {
+			if c < 0 || c >= i || i-c > maxDistance {
+				continue
+			}
+
+			if l := matchLength(c, i); l > bestLength {
+				bestLength, bestDist = l, i-c
+			}
+		}
+
+		if bestLength >= minMatch {
+			tokens = append(tokens, token{length: bestLength, distCode: distanceCode(bestDist, width)})
+			for j := i; j < i+bestLength; j++ {
+				insert(j)
+			}
+
+			i += bestLength
+		} else {
+			tokens = append(tokens, token{argb: argb[i]})
+			insert(i)
+			i++
+		}
+	}
+
+	return tokens
+}
+
+// Encode writes the image to w in the lossless WebP format.
+func Encode(w io.Writer, m image.Image) error {
+	b := m.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width > maxDimension || height > maxDimension {
+		return ErrTooLarge
+	}
+
+	if width == 0 || height == 0 {
+		return errors.New("webp: empty image")
+	}
+
+	nrgba, ok := m.(*image.NRGBA)
+	if !ok {
+		nrgba = image.NewNRGBA(b)
+		draw.Draw(nrgba, b, m, b.Min, draw.Src)
+	}
+
+	// convert to ARGB and apply the subtract green transform
+	argb := make([]uint32, width*height)
+	hasAlpha := false
+	for y := 0; y < height; y++ {
+		row := nrgba.Pix[nrgba.PixOffset(b.Min.X, b.Min.Y+y):]
+		for x := 0; x < width; x++ {
+			r, g, bl, a := row[4*x], row[4*x+1], row[4*x+2], row[4*x+3]
+			if a != 0xff {
+				hasAlpha = true
+			}
+
+			argb[y*width+x] = uint32(a)<<24 | uint32(r-g)<<16 | uint32(g)<<8 | uint32(bl-g)
+		}
+	}
+
+	tokens := tokenize(argb, width)
+
+	green := make([]int, nLiteralCodes+nLengthCodes)
+	red := make([]int, nLiteralCodes)
+	blue := make([]int, nLiteralCodes)
+	alpha := make([]int, nLiteralCodes)
+	dist := make([]int, nDistanceCodes)
+
+	for _, t := range tokens {
+		if t.length == 0 {
+			alpha[t.argb>>24]++
+			red[t.argb>>16&0xff]++
+			green[t.argb>>8&0xff]++
+			blue[t.argb&0xff]++
+			continue
+		}
+
+		lengthSymbol, _, _ := prefixEncode(t.length)
+		green[nLiteralCodes+lengthSymbol]++
+
+		distSymbol, _, _ := prefixEncode(t.distCode)
+		dist[distSymbol]++
+	}
+
+	codes := [5]*prefixCode{
+		newPrefixCode(green, maxCodeLength),
+		newPrefixCode(red, maxCodeLength),
+		newPrefixCode(blue, maxCodeLength),
+		newPrefixCode(alpha, maxCodeLength),
+		newPrefixCode(dist, maxCodeLength),
+	}
+
+	bw := &bitWriter{}
+
+	// header
+	bw.write(0x2f, 8)
+	bw.write(uint32(width-1), 14)
+	bw.write(uint32(height-1), 14)
+	if hasAlpha {
+		bw.write(1, 1)
+	} else {
+		bw.write(0, 1)
+	}
+	bw.write(0, 3)
+
+	// subtract green transform
+	bw.write(1, 1)
+	bw.write(2, 2)
+	bw.write(0, 1)
+
+	// no color cache, no meta prefix codes
+	bw.write(0, 1)
+	bw.write(0, 1)
+
+	for _, c := range codes {
+		writePrefixCode(bw, c)
+	}
+
+	for _, t := range tokens {
+		if t.length == 0 {
+			codes[0].write(bw, int(t.argb>>8&0xff))
+			codes[1].write(bw, int(t.argb>>16&0xff))
+			codes[2].write(bw, int(t.argb&0xff))
+			codes[3].write(bw, int(t.argb>>24))
+			continue
+		}
+
+		symbol, extra, extraBits := prefixEncode(t.length)
+		codes[0].write(bw, nLiteralCodes+symbol)
+		bw.write(extra, extraBits)
+
+		symbol, extra, extraBits = prefixEncode(t.distCode)
+		codes[4].write(bw, symbol)
+		bw.write(extra, extraBits)
+	}
+
+	data := bw.bytes()
+	chunkSize := len(data)
+	padding := chunkSize & 1
+
+	header := make([]byte, 20)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(4+8+chunkSize+padding))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(chunkSize))
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	if padding != 0 {
+		data = append(data, 0)
+	}
+
+	_, err := w.Write(data)
+	return err
+}
@@ -0,0 +1,82 @@
+package webp
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/webp"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+func roundTrip(t *testing.T, img image.Image) {
+	var buf bytes.Buffer
+	if !assert.NoError(t, Encode(&buf, img)) {
+		return
+	}
+
+	decoded, err := webp.Decode(&buf)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	b := img.Bounds()
+	if !assert.Equal(t, b.Size(), decoded.Bounds().Size()) {
+		return
+	}
+
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			expected := color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y))
+			actual := color.NRGBAModel.Convert(decoded.At(x, y))
+			if !assert.Equal(t, expected, actual, "pixel (%d, %d)", x, y) {
+				return
+			}
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	noise := image.NewNRGBA(image.Rect(0, 0, 37, 21))
+	rng.Read(noise.Pix)
+	roundTrip(t, noise)
+
+	gradient := image.NewRGBA(image.Rect(5, 5, 133, 70))
+	for y := 5; y < 70; y++ {
+		for x := 5; x < 133; x++ {
+			gradient.Set(x, y, color.RGBA{uint8(x), uint8(y), uint8(x / 16 * 16), 0xff})
+		}
+	}
+	roundTrip(t, gradient)
+
+	roundTrip(t, image.NewNRGBA(image.Rect(0, 0, 1, 1)))
+
+	solid := image.NewNRGBA(image.Rect(0, 0, 300, 200))
+	for i := 0; i < len(solid.Pix); i += 4 {
+		copy(solid.Pix[i:], []byte{10, 20, 30, 40})
+	}
+	roundTrip(t, solid)
+}
+
+func TestEncode_TooLarge(t *testing.T) {
+	err := Encode(&bytes.Buffer{}, image.NewNRGBA(image.Rect(0, 0, maxDimension+1, 1)))
+	assert.Equal(t, ErrTooLarge, err)
+}
+
+func TestPrefixEncode(t *testing.T) {
+	for _, v := range []int{1, 2, 4, 5, 6, 7, 8, 9, 100, 4096, 1 << 20} {
+		symbol, extra, extraBits := prefixEncode(v)
+
+		// decode as described by the specification
+		decoded := symbol + 1
+		if symbol >= 4 {
+			assert.Equal(t, uint((symbol-2)>>1), extraBits, "value %d", v)
+			decoded = (2+symbol&1)<<extraBits + int(extra) + 1
+		}
+
+		assert.Equal(t, v, decoded, "value %d", v)
+	}
+}
@@ -0,0 +1,215 @@
+package mosaic
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// ErrEmptyComposerId is returned when a composer without an id is
+// registered.
+var ErrEmptyComposerId = errors.New("composer id must not be empty")
+
+// A DuplicateComposerError is returned when a composer is registered
+// using an id which is already taken.
+type DuplicateComposerError struct {
+	Id string
+}
+
+func (e *DuplicateComposerError) Error() string {
+	return fmt.Sprintf("composer %q already registered", e.Id)
+}
+
+// A Registry is a collection of composers with unique ids.
+// It's safe for concurrent use.
+type Registry struct {
+	mu        sync.RWMutex
+	composers []ComposerInfo
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// DefaultRegistry is the registry used by the package level functions.
+// The built-in composers are registered to it.
+var DefaultRegistry = NewRegistry()
+
+// index returns the index of the composer with the given id or -1.
+// The caller must hold the lock.
+func (r *Registry) index(id string) int {
+	for i, composer := range r.composers {
+		if composer.Id == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Register adds the composers to the registry. If any of the composers
+// has an empty or duplicate id, none of them are registered.
+func (r *Registry) Register(comps ...ComposerInfo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make(map[string]bool, len(comps))
+	for _, c := range comps {
+		if c.Id == "" {
+			return ErrEmptyComposerId
+		}
+
+		if ids[c.Id] || r.index(c.Id) >= 0 {
+			return &DuplicateComposerError{Id: c.Id}
+		}
+
+		ids[c.Id] = true
+	}
+
+	r.composers = append(r.composers, comps...)
+	return nil
+}
+
+// Unregister removes the composer with the given id and reports whether
+// it was registered.
+func (r *Registry) Unregister(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.index(id)
+	if i < 0 {
+		return false
+	}
+
+	r.composers = append(r.composers[:i:i], r.composers[i+1:]...)
+	return true
+}
+
+// Get returns the composer with the given id.
+func (r *Registry) Get(id string) (ComposerInfo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if i := r.index(id); i >= 0 {
+		return r.composers[i], true
+	}
+
+	return ComposerInfo{}, false
+}
+
+// Composers returns a slice containing all composers in the order they
+// were registered.
+func (r *Registry) Composers() []ComposerInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	composers := make([]ComposerInfo, len(r.composers))
+	copy(composers, r.composers)
+	return composers
+}
+
+// Clone returns a new registry containing the same composers. Changes to
+// the clone don't affect the original, which makes it useful for scoping
+// registrations to a test.
+func (r *Registry) Clone() *Registry {
+	return &Registry{composers: r.Composers()}
+}
+
+// Recommend returns a slice of composers which are suitable for the given
+// image count.
+func (r *Registry) Recommend(count int) []ComposerInfo {
+	type ComposerComp struct {
+		C             ComposerInfo
+		RecImageCount int
+	}
+
+	composerComparisons := make([]ComposerComp, 0)
+
+	for _, composer := range r.Composers() {
+		recommended := composer.RecommendImageCount(count)
+		if recommended != 0 {
+			composerComparisons = append(composerComparisons, ComposerComp{
+				composer,
+				recommended,
+			})
+		}
+
+	}
+
+	sort.Slice(composerComparisons, func(i, j int) bool {
+		return composerComparisons[i].RecImageCount < composerComparisons[j].RecImageCount
+	})
+
+	composers := make([]ComposerInfo, len(composerComparisons))
+	for i, cc := range composerComparisons {
+		composers[i] = cc.C
+	}
+
+	return composers
+}
+
+// Choose picks a random composer suitable for the image count using the
+// given source of randomness. Composers are weighted by how close their
+// recommended image count is to the image count, so composers using all of
+// the images are the most likely ones.
+func (r *Registry) Choose(count int, rng *rand.Rand) (ComposerInfo, bool) {
+	composers := r.Recommend(count)
+	if len(composers) == 0 {
+		return ComposerInfo{}, false
+	}
+
+	weights := make([]float64, len(composers))
+	var total float64
+	for i, composer := range composers {
+		unused := count - composer.RecommendImageCount(count)
+		weights[i] = 1 / float64(1+unused)
+		total += weights[i]
+	}
+
+	x := rng.Float64() * total
+	for i, w := range weights {
+		if x < w {
+			return composers[i], true
+		}
+
+		x -= w
+	}
+
+	return composers[len(composers)-1], true
+}
+
+// RegisterComposer registers the given composers to the DefaultRegistry.
+func RegisterComposer(comps ...ComposerInfo) error {
+	return DefaultRegistry.Register(comps...)
+}
+
+// UnregisterComposer removes the composer with the given id from the
+// DefaultRegistry.
+func UnregisterComposer(id string) bool {
+	return DefaultRegistry.Unregister(id)
+}
+
+// GetComposer returns the composer with the given id.
+func GetComposer(id string) (ComposerInfo, bool) {
+	return DefaultRegistry.Get(id)
+}
+
+// GetComposers returns a slice containing all composers.
+func GetComposers() []ComposerInfo {
+	return DefaultRegistry.Composers()
+}
+
+// RecommendComposers returns a slice of composers which are suitable
+// for the given image count.
+func RecommendComposers(count int) []ComposerInfo {
+	return DefaultRegistry.Recommend(count)
+}
+
+// ChooseComposer picks a random composer suitable for the image count from
+// the DefaultRegistry, see Registry.Choose.
+func ChooseComposer(count int, rng *rand.Rand) (ComposerInfo, bool) {
+	return DefaultRegistry.Choose(count, rng)
+}
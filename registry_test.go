@@ -0,0 +1,136 @@
+package mosaic
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func testComposerInfo(id string, counts ...int) ComposerInfo {
+	return ComposerInfo{
+		Composer:               LayoutFunc(TilesPerfect),
+		Id:                     id,
+		CheckImageCount:        func(count int) bool { return false },
+		RecommendedImageCounts: counts,
+	}
+}
+
+func TestRegistry_Register(t *testing.T) {
+	r := NewRegistry()
+
+	assert.NoError(t, r.Register(testComposerInfo("a"), testComposerInfo("b")))
+	assert.Equal(t, ErrEmptyComposerId, r.Register(testComposerInfo("")))
+	assert.Equal(t, &DuplicateComposerError{Id: "a"}, r.Register(testComposerInfo("c"), testComposerInfo("a")))
+	assert.Equal(t, &DuplicateComposerError{Id: "d"}, r.Register(testComposerInfo("d"), testComposerInfo("d")))
+
+	// failed registrations don't register anything
+	_, ok := r.Get("c")
+	assert.False(t, ok)
+	_, ok = r.Get("d")
+	assert.False(t, ok)
+
+	composer, ok := r.Get("b")
+	if assert.True(t, ok) {
+		assert.Equal(t, "b", composer.Id)
+	}
+
+	assert.Len(t, r.Composers(), 2)
+}
+
+func TestRegistry_Unregister(t *testing.T) {
+	r := NewRegistry()
+	assert.NoError(t, r.Register(testComposerInfo("a"), testComposerInfo("b"), testComposerInfo("c")))
+
+	composers := r.Composers()
+
+	assert.True(t, r.Unregister("b"))
+	assert.False(t, r.Unregister("b"))
+
+	_, ok := r.Get("b")
+	assert.False(t, ok)
+
+	ids := make([]string, 0)
+	for _, c := range r.Composers() {
+		ids = append(ids, c.Id)
+	}
+	assert.Equal(t, []string{"a", "c"}, ids)
+
+	// previously returned slices aren't affected
+	assert.Equal(t, "b", composers[1].Id)
+
+	assert.NoError(t, r.Register(testComposerInfo("b")))
+}
+
+func TestRegistry_Clone(t *testing.T) {
+	clone := DefaultRegistry.Clone()
+	assert.NoError(t, clone.Register(testComposerInfo("scoped")))
+	assert.True(t, clone.Unregister("tiles-perfect"))
+
+	_, ok := GetComposer("scoped")
+	assert.False(t, ok)
+	_, ok = GetComposer("tiles-perfect")
+	assert.True(t, ok)
+}
+
+func TestRegistry_Recommend(t *testing.T) {
+	r := NewRegistry()
+	assert.NoError(t, r.Register(testComposerInfo("two", 2), testComposerInfo("four", 4), testComposerInfo("eight", 8)))
+
+	recommended := r.Recommend(5)
+	if assert.Len(t, recommended, 2) {
+		assert.Equal(t, "two", recommended[0].Id)
+		assert.Equal(t, "four", recommended[1].Id)
+	}
+}
+
+func TestRegistry_Choose(t *testing.T) {
+	r := NewRegistry()
+
+	_, ok := r.Choose(4, rand.New(rand.NewSource(0)))
+	assert.False(t, ok)
+
+	assert.NoError(t, r.Register(testComposerInfo("one", 1), testComposerInfo("four", 4), testComposerInfo("eight", 8)))
+
+	chosen := make(map[string]int)
+	for seed := int64(0); seed < 1000; seed++ {
+		composer, ok := r.Choose(4, rand.New(rand.NewSource(seed)))
+		if !assert.True(t, ok) {
+			return
+		}
+
+		again, _ := r.Choose(4, rand.New(rand.NewSource(seed)))
+		assert.Equal(t, composer.Id, again.Id, "same seed must choose the same composer")
+
+		chosen[composer.Id]++
+	}
+
+	assert.Zero(t, chosen["eight"])
+	assert.NotZero(t, chosen["one"])
+	// "four" has weight 1 and "one" weight 1/4
+	assert.InDelta(t, 800, chosen["four"], 60)
+}
+
+func TestRegistry_Concurrent(t *testing.T) {
+	r := NewRegistry()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			id := fmt.Sprintf("composer-%d", i)
+			for j := 0; j < 100; j++ {
+				assert.NoError(t, r.Register(testComposerInfo(id, 1)))
+				r.Get(id)
+				r.Recommend(1)
+				assert.True(t, r.Unregister(id))
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	assert.Empty(t, r.Composers())
+}
@@ -0,0 +1,44 @@
+package mosaic
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"image"
+)
+
+// seedSamples is the maximum amount of pixels per axis hashed by
+// ImageSeed.
+const seedSamples = 32
+
+// ImageSeed derives a seed from the content of the images. The same images
+// in the same order always result in the same seed, so it can be used to
+// make random decisions reproducible.
+//
+// Only the bounds and a grid of at most 32x32 pixels of every image are
+// hashed.
+func ImageSeed(images ...image.Image) int64 {
+	h := fnv.New64a()
+	buf := make([]byte, 4)
+
+	write := func(values ...uint32) {
+		for _, v := range values {
+			binary.LittleEndian.PutUint32(buf, v)
+			_, _ = h.Write(buf)
+		}
+	}
+
+	for _, img := range images {
+		bounds := img.Bounds()
+		write(uint32(bounds.Dx()), uint32(bounds.Dy()))
+
+		stepX := bounds.Dx()/seedSamples + 1
+		stepY := bounds.Dy()/seedSamples + 1
+		for y := bounds.Min.Y; y < bounds.Max.Y; y += stepY {
+			for x := bounds.Min.X; x < bounds.Max.X; x += stepX {
+				write(img.At(x, y).RGBA())
+			}
+		}
+	}
+
+	return int64(h.Sum64())
+}
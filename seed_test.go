@@ -0,0 +1,21 @@
+package mosaic
+
+import (
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestImageSeed(t *testing.T) {
+	a := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	b := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	assert.Equal(t, ImageSeed(a, b), ImageSeed(b, a))
+
+	b.Set(0, 0, color.White)
+	assert.NotEqual(t, ImageSeed(a, a), ImageSeed(a, b))
+	assert.NotEqual(t, ImageSeed(a, b), ImageSeed(b, a))
+
+	c := image.NewRGBA(image.Rect(0, 0, 50, 100))
+	assert.NotEqual(t, ImageSeed(a), ImageSeed(c))
+}
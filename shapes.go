@@ -0,0 +1,226 @@
+package mosaic
+
+import (
+	"github.com/fogleman/gg"
+	"github.com/gieseladev/mosaic/pkg/geom"
+	"math"
+)
+
+// outlineSegments is the amount of line segments used to approximate a
+// full circle by Outline.
+const outlineSegments = 32
+
+// A Shape is an area of the canvas used to clip the image of a region.
+type Shape interface {
+	// Bounds returns the bounding rectangle of the shape.
+	Bounds() geom.Rectangle
+	// Contains checks whether the point lies inside the shape.
+	Contains(p geom.Point) bool
+	// Outline approximates the shape using a polygon.
+	Outline() geom.Polygon
+	// Path adds the shape as a new sub path to the context.
+	Path(dc *gg.Context)
+}
+
+// arcPoints returns the points along a circle arc including both end
+// points.
+func arcPoints(center geom.Point, radius, start, end float64) []geom.Point {
+	segments := int(math.Ceil(math.Abs(end-start) / geom.TwoPi * outlineSegments))
+	if segments < 1 {
+		segments = 1
+	}
+
+	points := make([]geom.Point, segments+1)
+	for i := range points {
+		angle := start + (end-start)*float64(i)/float64(segments)
+		points[i] = geom.PtFromPolar(radius, angle).Add(center)
+	}
+
+	return points
+}
+
+// A RectShape is an axis aligned rectangle with optionally rounded
+// corners.
+type RectShape struct {
+	Rect geom.Rectangle
+	// Radius is the radius of the corners. It's capped at half the shorter
+	// side of the rectangle.
+	Radius float64
+}
+
+// radius returns the effective corner radius.
+func (s RectShape) radius() float64 {
+	maxRadius := s.Rect.MinSide() / 2
+	if s.Radius > maxRadius {
+		return maxRadius
+	} else if s.Radius < 0 {
+		return 0
+	}
+
+	return s.Radius
+}
+
+// Bounds returns the rectangle.
+func (s RectShape) Bounds() geom.Rectangle {
+	return s.Rect
+}
+
+// Contains checks whether the point lies inside the rectangle and not in
+// one of the cut off corners.
+func (s RectShape) Contains(p geom.Point) bool {
+	if !s.Rect.Contains(p) {
+		return false
+	}
+
+	r := s.radius()
+	if r == 0 {
+		return true
+	}
+
+	inner := s.Rect.Inset(r)
+	nearest := geom.Pt(
+		math.Max(inner.Min.X, math.Min(p.X, inner.Max.X)),
+		math.Max(inner.Min.Y, math.Min(p.Y, inner.Max.Y)),
+	)
+
+	d, _ := p.Sub(nearest).Polar()
+	return d <= r
+}
+
+// Outline returns the vertices of the rectangle. Rounded corners are
+// approximated.
+func (s RectShape) Outline() geom.Polygon {
+	r := s.radius()
+	if r == 0 {
+		return geom.Poly(s.Rect.Vertices()...)
+	}
+
+	inner := s.Rect.Inset(r)
+	var vertices []geom.Point
+	vertices = append(vertices, arcPoints(inner.TopLeft(), r, math.Pi, 3*geom.HalfPi)...)
+	vertices = append(vertices, arcPoints(inner.TopRight(), r, 3*geom.HalfPi, geom.TwoPi)...)
+	vertices = append(vertices, arcPoints(inner.BottomRight(), r, 0, geom.HalfPi)...)
+	vertices = append(vertices, arcPoints(inner.BottomLeft(), r, geom.HalfPi, math.Pi)...)
+
+	return geom.Poly(vertices...)
+}
+
+// Path adds the rectangle to the context.
+func (s RectShape) Path(dc *gg.Context) {
+	r := s.Rect
+	if radius := s.radius(); radius > 0 {
+		dc.DrawRoundedRectangle(r.Min.X, r.Min.Y, r.Width(), r.Height(), radius)
+	} else {
+		dc.DrawRectangle(r.Min.X, r.Min.Y, r.Width(), r.Height())
+	}
+}
+
+// A PolygonShape is a shape described by a polygon.
+type PolygonShape struct {
+	geom.Polygon
+}
+
+// Bounds returns the bounding rectangle of the polygon.
+func (s PolygonShape) Bounds() geom.Rectangle {
+	return s.BoundingRect()
+}
+
+// Outline returns the polygon.
+func (s PolygonShape) Outline() geom.Polygon {
+	return s.Polygon
+}
+
+// Path adds the polygon to the context.
+func (s PolygonShape) Path(dc *gg.Context) {
+	drawPolygon(dc, s.Polygon)
+}
+
+// A CircleShape is a circle.
+type CircleShape struct {
+	Center geom.Point
+	Radius float64
+}
+
+// Bounds returns the square containing the circle.
+func (s CircleShape) Bounds() geom.Rectangle {
+	d := geom.Pt(s.Radius, s.Radius)
+	return geom.Rectangle{Min: s.Center.Sub(d), Max: s.Center.Add(d)}
+}
+
+// Contains checks whether the point lies inside the circle.
+func (s CircleShape) Contains(p geom.Point) bool {
+	d, _ := p.Sub(s.Center).Polar()
+	return d <= s.Radius
+}
+
+// Outline approximates the circle.
+func (s CircleShape) Outline() geom.Polygon {
+	return geom.RegularPoly(s.Center, s.Radius, outlineSegments, 0)
+}
+
+// Path adds the circle to the context.
+func (s CircleShape) Path(dc *gg.Context) {
+	dc.NewSubPath()
+	dc.DrawCircle(s.Center.X, s.Center.Y, s.Radius)
+}
+
+// A SliceShape is a slice of a circle, like a piece of a pie.
+type SliceShape struct {
+	Center geom.Point
+	Radius float64
+	// Start and End are the angles of the slice in radians. The slice
+	// goes clockwise from Start to End.
+	Start, End float64
+}
+
+// full checks whether the slice covers the entire circle.
+func (s SliceShape) full() bool {
+	return s.End-s.Start >= geom.TwoPi
+}
+
+// Bounds returns the smallest rectangle containing the slice.
+func (s SliceShape) Bounds() geom.Rectangle {
+	if s.full() {
+		return CircleShape{s.Center, s.Radius}.Bounds()
+	}
+
+	rect := geom.RectContainingPoints(
+		s.Center,
+		geom.PtFromPolar(s.Radius, s.Start).Add(s.Center),
+		geom.PtFromPolar(s.Radius, s.End).Add(s.Center),
+	)
+
+	// ensure the rect covers all of the arc
+	for i, angle := range circleCornerAngles {
+		if geom.AngleStrictlyBetween(angle, s.Start, s.End) {
+			rect = rect.GrowToContain(circleCornerPoints[i].Mul(s.Radius).Add(s.Center))
+		}
+	}
+
+	return rect
+}
+
+// Contains checks whether the point lies inside the slice.
+func (s SliceShape) Contains(p geom.Point) bool {
+	d, angle := p.Sub(s.Center).Polar()
+	if d > s.Radius {
+		return false
+	}
+
+	return s.full() || d == 0 || geom.AngleStrictlyBetween(angle, s.Start, s.End)
+}
+
+// Outline approximates the slice.
+func (s SliceShape) Outline() geom.Polygon {
+	arc := arcPoints(s.Center, s.Radius, s.Start, s.End)
+	if s.full() {
+		return geom.Poly(arc[:len(arc)-1]...)
+	}
+
+	return geom.Poly(append([]geom.Point{s.Center}, arc...)...)
+}
+
+// Path adds the slice to the context.
+func (s SliceShape) Path(dc *gg.Context) {
+	drawSlice(dc, s.Center.X, s.Center.Y, s.Radius, s.Start, s.End)
+}
@@ -0,0 +1,229 @@
+package mosaic
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/gieseladev/mosaic/pkg/geom"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// svgJPEGQuality is the quality of the opaque images embedded in SVGs.
+const svgJPEGQuality = 90
+
+// svgNum formats the number for use in an SVG document.
+func svgNum(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+}
+
+// svgColor returns the fill attributes for the color.
+func svgColor(c color.Color) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	attrs := fmt.Sprintf(`fill="#%02x%02x%02x"`, n.R, n.G, n.B)
+	if n.A != 0xff {
+		attrs += fmt.Sprintf(` fill-opacity="%s"`, svgNum(float64(n.A)/0xff))
+	}
+
+	return attrs
+}
+
+// svgPolygon returns the polygon element for the polygon.
+func svgPolygon(pg geom.Polygon) string {
+	points := make([]string, len(pg.Vertices))
+	for i, p := range pg.Vertices {
+		points[i] = svgNum(p.X) + "," + svgNum(p.Y)
+	}
+
+	return fmt.Sprintf(`<polygon points="%s"/>`, strings.Join(points, " "))
+}
+
+// svgShape returns the SVG element describing the same area as the shape.
+// Shapes other than the built-in ones are described by their outline.
+func svgShape(s Shape) string {
+	switch s := s.(type) {
+	case RectShape:
+		r := s.Rect
+		element := fmt.Sprintf(`<rect x="%s" y="%s" width="%s" height="%s"`,
+			svgNum(r.Min.X), svgNum(r.Min.Y), svgNum(r.Width()), svgNum(r.Height()))
+		if radius := s.radius(); radius > 0 {
+			element += fmt.Sprintf(` rx="%s" ry="%s"`, svgNum(radius), svgNum(radius))
+		}
+
+		return element + "/>"
+	case CircleShape:
+		return fmt.Sprintf(`<circle cx="%s" cy="%s" r="%s"/>`, svgNum(s.Center.X), svgNum(s.Center.Y), svgNum(s.Radius))
+	case SliceShape:
+		if s.full() {
+			return svgShape(CircleShape{s.Center, s.Radius})
+		}
+
+		// like drawSlice, the path goes from the center along the arc
+		start := geom.PtFromPolar(s.Radius, s.Start).Add(s.Center)
+		end := geom.PtFromPolar(s.Radius, s.End).Add(s.Center)
+		largeArc := 0
+		if s.End-s.Start > math.Pi {
+			largeArc = 1
+		}
+
+		return fmt.Sprintf(`<path d="M%s,%s L%s,%s A%s,%s 0 %d 1 %s,%s Z"/>`,
+			svgNum(s.Center.X), svgNum(s.Center.Y),
+			svgNum(start.X), svgNum(start.Y),
+			svgNum(s.Radius), svgNum(s.Radius), largeArc,
+			svgNum(end.X), svgNum(end.Y))
+	case PolygonShape:
+		return svgPolygon(s.Polygon)
+	default:
+		return svgPolygon(s.Outline())
+	}
+}
+
+// svgWriter writes an SVG document and keeps the first error.
+type svgWriter struct {
+	w *bufio.Writer
+}
+
+func (sw svgWriter) printf(format string, a ...interface{}) {
+	_, _ = fmt.Fprintf(sw.w, format, a...)
+}
+
+// image embeds the image stretched to the rectangle. Opaque images are
+// embedded as JPEG, all others as PNG.
+func (sw svgWriter) image(img image.Image, rect geom.Rectangle, attrs string) error {
+	mediaType := "image/png"
+	encode := func(w io.Writer) error { return png.Encode(w, img) }
+	if isOpaque(img) {
+		mediaType = "image/jpeg"
+		encode = func(w io.Writer) error { return jpeg.Encode(w, img, &jpeg.Options{Quality: svgJPEGQuality}) }
+	}
+
+	sw.printf(`<image x="%s" y="%s" width="%s" height="%s" preserveAspectRatio="none"%s xlink:href="data:%s;base64,`,
+		svgNum(rect.Min.X), svgNum(rect.Min.Y), svgNum(rect.Width()), svgNum(rect.Height()), attrs, mediaType)
+
+	enc := base64.NewEncoder(base64.StdEncoding, sw.w)
+	if err := encode(enc); err != nil {
+		return err
+	}
+
+	if err := enc.Close(); err != nil {
+		return err
+	}
+
+	sw.printf("\"/>\n")
+	return nil
+}
+
+// isOpaque checks whether all pixels of the image are opaque.
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xffff {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// renderSVG writes the layout as an SVG document. The background image is
+// drawn below the layout if it isn't nil.
+func renderSVG(ctx context.Context, w io.Writer, layout Layout, background image.Image, opts Options, images ...image.Image) error {
+	for _, region := range layout.Regions {
+		if region.Image < 0 || region.Image >= len(images) {
+			return ErrInvalidImageCount
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	sw := svgWriter{w: bufio.NewWriter(w)}
+	width, height := float64(layout.Width), float64(layout.Height)
+	bounds := geom.Rect(0, 0, width, height)
+
+	sw.printf(`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		layout.Width, layout.Height, layout.Width, layout.Height)
+
+	if background != nil {
+		if err := sw.image(background, bounds, ""); err != nil {
+			return err
+		}
+	}
+
+	if layout.Background != nil {
+		if _, _, _, a := layout.Background.RGBA(); a > 0 {
+			var attrs string
+			if background != nil {
+				// like Render, only the gaps between the regions are filled
+				sw.printf(`<mask id="gaps"><rect width="%d" height="%d" fill="white"/><g fill="black">`, layout.Width, layout.Height)
+				for _, region := range layout.Regions {
+					sw.printf("%s", svgShape(region.Shape))
+				}
+
+				sw.printf("</g></mask>\n")
+				attrs = ` mask="url(#gaps)"`
+			}
+
+			sw.printf(`<rect width="%d" height="%d" %s%s/>`+"\n", layout.Width, layout.Height, svgColor(layout.Background), attrs)
+		}
+	}
+
+	cropper := opts.Cropper()
+	for i, region := range layout.Regions {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		cropped, rect := cropRegion(region, cropper, images)
+		if cropped == nil {
+			continue
+		}
+
+		var attrs string
+		if region.needsMask() {
+			sw.printf(`<clipPath id="region-%d">%s</clipPath>`+"\n", i, svgShape(region.Shape))
+			attrs = fmt.Sprintf(` clip-path="url(#region-%d)"`, i)
+		}
+
+		if err := sw.image(cropped, rectFromPixels(rect), attrs); err != nil {
+			return err
+		}
+	}
+
+	sw.printf("</svg>\n")
+	return sw.w.Flush()
+}
+
+// RenderSVG writes the layout as an SVG document to w. The regions are
+// clipped by their shapes and show the cropped images in their original
+// resolution, so the document matches the output of Render geometrically.
+func RenderSVG(ctx context.Context, w io.Writer, layout Layout, opts Options, images ...image.Image) error {
+	return renderSVG(ctx, w, layout, nil, opts, images...)
+}
+
+// ComposeSVG writes the composition as an SVG document to w. Like
+// ComposeContext it validates the options and draws the background, which
+// is embedded as an image. ErrNoLayout is returned if the composer isn't a
+// LayoutComposer.
+func (ci ComposerInfo) ComposeSVG(ctx context.Context, w io.Writer, width, height int, opts Options, images ...image.Image) error {
+	layout, background, opts, err := ci.vectorLayout(width, height, opts, images)
+	if err != nil {
+		return err
+	}
+
+	return renderSVG(ctx, w, layout, background, opts, images...)
+}
@@ -0,0 +1,109 @@
+package mosaic
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"github.com/gieseladev/mosaic/pkg/geom"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"testing"
+)
+
+// svgElements returns the names of all elements in the SVG document.
+func svgElements(t *testing.T, data []byte) []string {
+	var names []string
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return names
+		} else if !assert.NoError(t, err) {
+			return nil
+		}
+
+		if start, ok := token.(xml.StartElement); ok {
+			names = append(names, start.Name.Local)
+		}
+	}
+}
+
+func TestSVGShape(t *testing.T) {
+	assert.Equal(t, `<rect x="1" y="2" width="3" height="4" rx="1.5" ry="1.5"/>`,
+		svgShape(RectShape{Rect: geom.Rect(1, 2, 4, 6), Radius: 2}))
+	assert.Equal(t, `<circle cx="5" cy="5" r="5"/>`,
+		svgShape(SliceShape{Center: geom.Pt(5, 5), Radius: 5, Start: 0, End: geom.TwoPi}))
+	assert.Equal(t, `<path d="M0,0 L10,0 A10,10 0 0 1 0,10 Z"/>`,
+		svgShape(SliceShape{Radius: 10, Start: 0, End: math.Pi / 2}))
+	assert.Equal(t, `<polygon points="0,0 1.5,0 0,2"/>`,
+		svgShape(PolygonShape{geom.Poly(geom.Pt(0, 0), geom.Pt(1.5, 0), geom.Pt(0, 2))}))
+}
+
+func TestRenderSVG(t *testing.T) {
+	left, right := geom.Rect(0, 0, 10, 20), geom.Rect(10, 0, 20, 20)
+	layout := Layout{
+		Width: 20, Height: 20,
+		Background: color.White,
+		Regions: []Region{
+			{Image: 0, Shape: RectShape{Rect: left}, Rect: left},
+			{Image: 1, Shape: CircleShape{Center: geom.Pt(15, 10), Radius: 5}, Rect: right},
+		},
+	}
+
+	images := []image.Image{uniformImage(color.Black), uniformImage(color.Transparent)}
+
+	var buf bytes.Buffer
+	if assert.NoError(t, RenderSVG(context.Background(), &buf, layout, nil, images...)) {
+		// only the circle needs to be clipped
+		assert.Equal(t, []string{"svg", "rect", "image", "clipPath", "circle", "image"}, svgElements(t, buf.Bytes()))
+		assert.Contains(t, buf.String(), "data:image/jpeg;base64,")
+		assert.Contains(t, buf.String(), "data:image/png;base64,")
+	}
+
+	assert.Equal(t, ErrInvalidImageCount, RenderSVG(context.Background(), &buf, layout, nil, images[0]))
+}
+
+func TestComposerInfo_ComposeSVG(t *testing.T) {
+	composer, ok := GetComposer("circles-pie")
+	if !assert.True(t, ok) {
+		return
+	}
+
+	images := []image.Image{uniformImage(color.Black), uniformImage(color.White), uniformImage(color.Gray{Y: 0x80})}
+
+	var buf bytes.Buffer
+	err := composer.ComposeSVG(context.Background(), &buf, 32, 32, Options{"background": BackgroundSolid}, images...)
+	if assert.NoError(t, err) {
+		elements := svgElements(t, buf.Bytes())
+		// the background and one image per slice
+		assert.Equal(t, "svg", elements[0])
+		assert.Equal(t, "image", elements[1])
+		assert.Len(t, elements, 2+3*3)
+	}
+}
+
+func TestComposerInfo_ComposeSVGGutters(t *testing.T) {
+	composer, ok := GetComposer("tiles-perfect")
+	if !assert.True(t, ok) {
+		return
+	}
+
+	images := []image.Image{uniformImage(color.Transparent), uniformImage(color.White)}
+
+	var buf bytes.Buffer
+	err := composer.ComposeSVG(context.Background(), &buf, 32, 32, Options{
+		"background":   BackgroundSolid,
+		"gutter":       4,
+		"gutter-color": "#00ff00",
+	}, images...)
+	if assert.NoError(t, err) {
+		// the gutter color is masked by the regions, so the background
+		// shows below them
+		elements := svgElements(t, buf.Bytes())
+		assert.Equal(t, []string{"svg", "image", "mask", "rect", "g", "rect", "rect", "rect"}, elements[:8])
+		assert.Contains(t, buf.String(), `mask="url(#gaps)"`)
+	}
+}
@@ -0,0 +1,372 @@
+package mosaic
+
+import (
+	"fmt"
+	"github.com/fogleman/gg"
+	"github.com/gieseladev/mosaic/pkg/geom"
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"math"
+	"strings"
+)
+
+// Placements of a TextOverlay.
+const (
+	// PlacementBanner draws the text centered on a band along the bottom
+	// edge.
+	PlacementBanner = "banner"
+	// PlacementBadge draws the text centered on a rounded box in the
+	// middle of the canvas.
+	PlacementBadge = "badge"
+	// PlacementCorner draws the text in the bottom left corner without a
+	// backdrop.
+	PlacementCorner = "corner"
+)
+
+// TextPlacements contains the names of all placements.
+var TextPlacements = []string{PlacementBanner, PlacementBadge, PlacementCorner}
+
+// Styles of a TextOverlay.
+const (
+	// TextStyleNone draws plain text.
+	TextStyleNone = "none"
+	// TextStyleShadow draws a soft shadow below the text.
+	TextStyleShadow = "shadow"
+	// TextStyleOutline draws an outline around the text.
+	TextStyleOutline = "outline"
+)
+
+// TextStyles contains the names of all text styles.
+var TextStyles = []string{TextStyleNone, TextStyleShadow, TextStyleOutline}
+
+const (
+	// textLineSpacing is the distance between two lines relative to the
+	// font size.
+	textLineSpacing = 1.2
+	// captionScale is the size of the caption relative to the title.
+	captionScale = .55
+	// maxTitleLines and maxCaptionLines limit the amount of lines before
+	// the text is shrunk.
+	maxTitleLines   = 2
+	maxCaptionLines = 2
+	// minFontSize is the smallest font size in pixels the text is shrunk
+	// to.
+	minFontSize = 6
+	// capHeight is the approximate height of capital letters relative to
+	// the font size.
+	capHeight = .7
+	// shrinkFactor is applied to the font size until the text fits.
+	shrinkFactor = .9
+)
+
+var (
+	regularFont = mustParseFont(goregular.TTF)
+	boldFont    = mustParseFont(gobold.TTF)
+)
+
+func mustParseFont(ttf []byte) *truetype.Font {
+	f, err := truetype.Parse(ttf)
+	if err != nil {
+		panic(fmt.Sprintf("couldn't parse embedded font: %v", err))
+	}
+
+	return f
+}
+
+// DefaultFont returns the embedded regular Go font.
+func DefaultFont() *truetype.Font {
+	return regularFont
+}
+
+// DefaultBoldFont returns the embedded bold Go font.
+func DefaultBoldFont() *truetype.Font {
+	return boldFont
+}
+
+// LoadFont loads a TrueType font from the given path.
+func LoadFont(path string) (*truetype.Font, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return truetype.Parse(data)
+}
+
+// A TextOverlay draws a title and a caption on top of a composition.
+type TextOverlay struct {
+	Title   string
+	Caption string
+
+	// Placement is one of the TextPlacements. Defaults to PlacementBanner.
+	Placement string
+	// Style is one of the TextStyles. Defaults to TextStyleShadow.
+	Style string
+
+	// Color of the text. If it's nil, black or white is chosen depending
+	// on what's behind the text.
+	Color color.Color
+
+	// TitleFont and CaptionFont default to DefaultBoldFont and
+	// DefaultFont.
+	TitleFont   *truetype.Font
+	CaptionFont *truetype.Font
+
+	// Size is the maximum font size of the title relative to the shorter
+	// side of the canvas. The text is shrunk until it fits. Defaults to
+	// 1/8.
+	Size float64
+}
+
+func checkName(kind, value string, names []string) error {
+	for _, name := range names {
+		if value == name {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown %s %q", kind, value)
+}
+
+// withDefaults returns a copy of the overlay with all unset fields set to
+// their default.
+func (t TextOverlay) withDefaults() TextOverlay {
+	if t.Placement == "" {
+		t.Placement = PlacementBanner
+	}
+	if t.Style == "" {
+		t.Style = TextStyleShadow
+	}
+	if t.TitleFont == nil {
+		t.TitleFont = boldFont
+	}
+	if t.CaptionFont == nil {
+		t.CaptionFont = regularFont
+	}
+	if t.Size <= 0 {
+		t.Size = 1. / 8
+	}
+
+	return t
+}
+
+// Validate checks the placement and style of the overlay.
+func (t TextOverlay) Validate() error {
+	t = t.withDefaults()
+	if err := checkName("placement", t.Placement, TextPlacements); err != nil {
+		return err
+	}
+
+	return checkName("text style", t.Style, TextStyles)
+}
+
+// textLine is a line of text with the face used to draw it.
+type textLine struct {
+	Text string
+	Face font.Face
+	Size float64
+}
+
+// textBlock is the text of an overlay wrapped to fit into a box.
+type textBlock struct {
+	Lines         []textLine
+	Width, Height float64
+}
+
+// wrap wraps the text using the given face and reports whether it fits into
+// the width using at most maxLines lines.
+func wrap(dc *gg.Context, s string, face font.Face, size, width float64, maxLines int) ([]textLine, bool) {
+	if s == "" {
+		return nil, true
+	}
+
+	dc.SetFontFace(face)
+
+	var lines []textLine
+	fits := true
+	for _, text := range dc.WordWrap(s, width) {
+		if w, _ := dc.MeasureString(text); w > width {
+			fits = false
+		}
+
+		lines = append(lines, textLine{Text: text, Face: face, Size: size})
+	}
+
+	return lines, fits && len(lines) <= maxLines
+}
+
+// layoutText shrinks the text until it fits into the box.
+func (t TextOverlay) layoutText(dc *gg.Context, maxWidth, maxHeight, size float64) textBlock {
+	for ; ; size *= shrinkFactor {
+		if size < minFontSize {
+			size = minFontSize
+		}
+
+		titleFace := truetype.NewFace(t.TitleFont, &truetype.Options{Size: size})
+		captionSize := size * captionScale
+		captionFace := truetype.NewFace(t.CaptionFont, &truetype.Options{Size: captionSize})
+
+		titleLines, titleFits := wrap(dc, t.Title, titleFace, size, maxWidth, maxTitleLines)
+		captionLines, captionFits := wrap(dc, t.Caption, captionFace, captionSize, maxWidth, maxCaptionLines)
+
+		block := textBlock{Lines: append(titleLines, captionLines...)}
+		for _, line := range block.Lines {
+			dc.SetFontFace(line.Face)
+			w, _ := dc.MeasureString(line.Text)
+			block.Width = math.Max(block.Width, w)
+			block.Height += line.Size * textLineSpacing
+		}
+
+		fits := titleFits && captionFits && block.Width <= maxWidth && block.Height <= maxHeight
+		if fits || size == minFontSize {
+			return block
+		}
+	}
+}
+
+// averageLuminance returns the mean relative luminance of the image inside
+// of the rectangle in the range [0, 1].
+func averageLuminance(img image.Image, r image.Rectangle) float64 {
+	r = r.Intersect(img.Bounds())
+	if r.Empty() {
+		return 0
+	}
+
+	step := int(math.Max(1, math.Sqrt(float64(r.Dx()*r.Dy())/4096)))
+
+	var sum float64
+	var n int
+	for y := r.Min.Y; y < r.Max.Y; y += step {
+		for x := r.Min.X; x < r.Max.X; x += step {
+			cr, cg, cb, _ := img.At(x, y).RGBA()
+			sum += (.2126*float64(cr) + .7152*float64(cg) + .0722*float64(cb)) / 0xffff
+			n++
+		}
+	}
+
+	return sum / float64(n)
+}
+
+// contrastColors returns the text color and the color of its shadow,
+// outline or backdrop for a background with the given luminance. White
+// text is preferred as it stays readable on busy backgrounds.
+func contrastColors(luminance float64) (text, contrast color.Color) {
+	if luminance > .6 {
+		return color.Black, color.White
+	}
+
+	return color.White, color.Black
+}
+
+func withAlpha(c color.Color, alpha float64) color.Color {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	n.A = uint8(float64(n.A) * alpha)
+	return n
+}
+
+// drawStyledString draws the string with the given style.
+func drawStyledString(dc *gg.Context, s string, x, y, ax float64, size float64, style string, text, contrast color.Color) {
+	switch style {
+	case TextStyleShadow:
+		// a ring of translucent copies around the offset softens the shadow
+		offset := math.Max(1, size/16)
+		dc.SetColor(withAlpha(contrast, .3))
+		for i := 0; i < 8; i++ {
+			angle := float64(i) * math.Pi / 4
+			dx, dy := offset*(1+math.Cos(angle)/2), offset*(1+math.Sin(angle)/2)
+			dc.DrawStringAnchored(s, x+dx, y+dy, ax, 0)
+		}
+	case TextStyleOutline:
+		width := math.Max(1, size/20)
+		dc.SetColor(contrast)
+		for i := 0; i < 8; i++ {
+			angle := float64(i) * math.Pi / 4
+			dc.DrawStringAnchored(s, x+width*math.Cos(angle), y+width*math.Sin(angle), ax, 0)
+		}
+	}
+
+	dc.SetColor(text)
+	dc.DrawStringAnchored(s, x, y, ax, 0)
+}
+
+// Draw draws the overlay on top of the context. Nothing is drawn if
+// neither the title nor the caption are set.
+func (t TextOverlay) Draw(dc *gg.Context) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+
+	t = t.withDefaults()
+	t.Title = strings.TrimSpace(t.Title)
+	t.Caption = strings.TrimSpace(t.Caption)
+	if t.Title == "" && t.Caption == "" {
+		return nil
+	}
+
+	w, h := float64(dc.Width()), float64(dc.Height())
+	minSide := math.Min(w, h)
+	pad := math.Max(2, minSide*.04)
+
+	var maxWidth, maxHeight float64
+	switch t.Placement {
+	case PlacementBanner:
+		maxWidth, maxHeight = w-2*pad, h*.25
+	case PlacementBadge:
+		maxWidth, maxHeight = w*.7-2*pad, h*.4
+	case PlacementCorner:
+		maxWidth, maxHeight = w*.6, h*.25
+	}
+
+	block := t.layoutText(dc, maxWidth, maxHeight, minSide*t.Size)
+
+	// the area covered by the text including its backdrop
+	var box geom.Rectangle
+	switch t.Placement {
+	case PlacementBanner:
+		box = geom.Rect(0, h-block.Height-2*pad, w, h)
+	case PlacementBadge:
+		boxW, boxH := block.Width+2*pad, block.Height+2*pad
+		box = geom.Rect((w-boxW)/2, (h-boxH)/2, (w+boxW)/2, (h+boxH)/2)
+	case PlacementCorner:
+		box = geom.Rect(pad, h-block.Height-pad, pad+block.Width, h-pad)
+	}
+
+	textColor, contrast := contrastColors(averageLuminance(dc.Image(), pixelRect(box)))
+
+	switch t.Placement {
+	case PlacementBanner:
+		dc.SetColor(withAlpha(contrast, .55))
+		dc.DrawRectangle(box.Min.X, box.Min.Y, box.Width(), box.Height())
+		dc.Fill()
+	case PlacementBadge:
+		dc.SetColor(withAlpha(contrast, .65))
+		dc.DrawRoundedRectangle(box.Min.X, box.Min.Y, box.Width(), box.Height(), pad)
+		dc.Fill()
+	}
+
+	if t.Color != nil {
+		textColor = t.Color
+	}
+
+	x, ax := box.Center().X, .5
+	if t.Placement == PlacementCorner {
+		x, ax = box.Min.X, 0
+	}
+
+	y := box.Center().Y - block.Height/2
+	for _, line := range block.Lines {
+		dc.SetFontFace(line.Face)
+		// center the capital letters vertically in the line
+		baseline := y + (line.Size*textLineSpacing+capHeight*line.Size)/2
+
+		drawStyledString(dc, line.Text, x, baseline, ax, line.Size, t.Style, textColor, contrast)
+		y += line.Size * textLineSpacing
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package mosaic
+
+import (
+	"github.com/fogleman/gg"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestTextOverlay_Validate(t *testing.T) {
+	assert.NoError(t, TextOverlay{}.Validate())
+	assert.NoError(t, TextOverlay{Placement: PlacementCorner, Style: TextStyleOutline}.Validate())
+	assert.Error(t, TextOverlay{Placement: "top"}.Validate())
+	assert.Error(t, TextOverlay{Style: "glow"}.Validate())
+}
+
+func TestTextOverlay_Draw(t *testing.T) {
+	dc := gg.NewContext(200, 100)
+	dc.SetColor(color.White)
+	dc.Clear()
+
+	assert.NoError(t, TextOverlay{}.Draw(dc))
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, dc.Image().At(100, 50), "empty overlay mustn't draw")
+
+	for _, placement := range TextPlacements {
+		dc := gg.NewContext(200, 100)
+		dc.SetColor(color.White)
+		dc.Clear()
+
+		overlay := TextOverlay{Title: "Playlist", Caption: "42 songs", Placement: placement, Style: TextStyleNone}
+		assert.NoError(t, overlay.Draw(dc), placement)
+
+		var dark int
+		img := dc.Image().(*image.RGBA)
+		for i := 0; i < len(img.Pix); i += 4 {
+			if img.Pix[i] < 128 {
+				dark++
+			}
+		}
+
+		// the text on white needs to be dark
+		assert.NotZero(t, dark, placement)
+	}
+}
+
+func TestTextOverlay_layoutText(t *testing.T) {
+	dc := gg.NewContext(200, 100)
+	overlay := TextOverlay{Title: strings.Repeat("very long title ", 10)}.withDefaults()
+
+	block := overlay.layoutText(dc, 180, 40, 25)
+	assert.True(t, block.Height <= 40)
+	assert.True(t, block.Width <= 180)
+	assert.True(t, len(block.Lines) <= maxTitleLines || block.Lines[0].Size == minFontSize)
+
+	block = overlay.layoutText(dc, 10, 10, 25)
+	assert.Equal(t, float64(minFontSize), block.Lines[0].Size)
+}
+
+func TestContrastColors(t *testing.T) {
+	text, contrast := contrastColors(averageLuminance(image.NewUniform(color.White), image.Rect(0, 0, 10, 10)))
+	assert.Equal(t, color.Black, text)
+	assert.Equal(t, color.White, contrast)
+
+	text, _ = contrastColors(averageLuminance(image.NewUniform(color.Black), image.Rect(0, 0, 10, 10)))
+	assert.Equal(t, color.White, text)
+}
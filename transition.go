@@ -0,0 +1,331 @@
+package mosaic
+
+import (
+	"context"
+	"errors"
+	"github.com/fogleman/gg"
+	"image"
+	"image/color"
+	"time"
+)
+
+// fadeScale is the size regions which only appear in one of the layouts of
+// a Transition shrink to while they fade.
+const fadeScale = .8
+
+// ErrTransitionSize is returned when the layouts of a transition don't
+// match the size of the canvas.
+var ErrTransitionSize = errors.New("layouts of the transition must match the canvas size")
+
+// EaseInOut starts and ends the transition slowly.
+func EaseInOut(t float64) float64 {
+	return t * t * (3 - 2*t)
+}
+
+// A Transition morphs one composition into another. Regions showing the
+// same image in both layouts slide and scale into their new place, the
+// other regions fade out or in.
+//
+// Images are matched by identity, so the same image.Image has to be
+// passed to both layouts for its region to move.
+type Transition struct {
+	From, To             Layout
+	FromImages, ToImages []image.Image
+	// FromOptions and ToOptions are the options of the compositions. They
+	// select the cropper and the background of the regions of each side.
+	FromOptions, ToOptions Options
+
+	// Easing maps the linear progress to the progress of the motion. It
+	// defaults to EaseInOut.
+	Easing func(t float64) float64
+}
+
+// tween is a region at a point in time of a transition.
+type tween struct {
+	Region
+	Img   image.Image
+	Opts  Options
+	Alpha float64
+}
+
+// lerpShape interpolates between two shapes. Shapes of different types are
+// interpolated using their outlines.
+func lerpShape(a, b Shape, t float64) Shape {
+	switch a := a.(type) {
+	case RectShape:
+		if b, ok := b.(RectShape); ok {
+			return RectShape{Rect: a.Rect.Lerp(b.Rect, t), Radius: a.radius() + (b.radius()-a.radius())*t}
+		}
+	case CircleShape:
+		if b, ok := b.(CircleShape); ok {
+			return CircleShape{Center: a.Center.Lerp(b.Center, t), Radius: a.Radius + (b.Radius-a.Radius)*t}
+		}
+	}
+
+	return PolygonShape{a.Outline().Lerp(b.Outline(), t)}
+}
+
+// lerpRegion interpolates between two regions.
+func lerpRegion(a, b Region, t float64) Region {
+	region := Region{
+		Shape: lerpShape(a.Shape, b.Shape, t),
+		Rect:  a.Rect.Lerp(b.Rect, t),
+	}
+
+	switch {
+	case a.Anchor != nil && b.Anchor != nil:
+		anchor := a.Anchor.Lerp(*b.Anchor, t)
+		region.Anchor = &anchor
+	case t < .5:
+		region.Anchor = a.Anchor
+	default:
+		region.Anchor = b.Anchor
+	}
+
+	return region
+}
+
+// fadeRegion shrinks the region around its center to the given scale.
+func fadeRegion(r Region, scale float64) Region {
+	center := r.Shape.Bounds().Center()
+	return Region{
+		Shape:  PolygonShape{r.Shape.Outline().ScaleFrom(scale, center)},
+		Rect:   r.Rect.ScaleFrom(scale, center),
+		Anchor: r.Anchor,
+	}
+}
+
+// firstRegions maps the images to the index of the first region showing
+// them.
+func firstRegions(layout Layout, images []image.Image) map[image.Image]int {
+	regions := make(map[image.Image]int, len(layout.Regions))
+	for i, region := range layout.Regions {
+		img := images[region.Image]
+		if _, ok := regions[img]; !ok {
+			regions[img] = i
+		}
+	}
+
+	return regions
+}
+
+// tweens returns the regions at the progress t. Only the first region of
+// an image moves, all others fade. Regions which fade out are drawn at the
+// bottom and moving regions at the top.
+func (tr Transition) tweens(t float64) []tween {
+	fromRegions := firstRegions(tr.From, tr.FromImages)
+	toRegions := firstRegions(tr.To, tr.ToImages)
+
+	var fadingOut, fadingIn, moving []tween
+	for i, region := range tr.From.Regions {
+		img := tr.FromImages[region.Image]
+		if _, ok := toRegions[img]; ok && fromRegions[img] == i {
+			continue
+		}
+
+		fadingOut = append(fadingOut, tween{
+			Region: fadeRegion(region, 1-(1-fadeScale)*t),
+			Img:    img,
+			Opts:   tr.FromOptions,
+			Alpha:  1 - t,
+		})
+	}
+
+	for i, region := range tr.To.Regions {
+		img := tr.ToImages[region.Image]
+		if from, ok := fromRegions[img]; ok && toRegions[img] == i {
+			opts := tr.ToOptions
+			if t < .5 {
+				opts = tr.FromOptions
+			}
+
+			moving = append(moving, tween{Region: lerpRegion(tr.From.Regions[from], region, t), Img: img, Opts: opts, Alpha: 1})
+			continue
+		}
+
+		fadingIn = append(fadingIn, tween{
+			Region: fadeRegion(region, fadeScale+(1-fadeScale)*t),
+			Img:    img,
+			Opts:   tr.ToOptions,
+			Alpha:  t,
+		})
+	}
+
+	return append(append(fadingOut, fadingIn...), moving...)
+}
+
+// lerpColor interpolates between two colors. Nil is treated as
+// transparent.
+func lerpColor(a, b color.Color, t float64) color.Color {
+	if a == nil {
+		a = color.Transparent
+	}
+	if b == nil {
+		b = color.Transparent
+	}
+
+	r0, g0, b0, a0 := a.RGBA()
+	r1, g1, b1, a1 := b.RGBA()
+	lerp := func(x, y uint32) uint16 {
+		return uint16(float64(x) + (float64(y)-float64(x))*t + .5)
+	}
+
+	return color.RGBA64{R: lerp(r0, r1), G: lerp(g0, g1), B: lerp(b0, b1), A: lerp(a0, a1)}
+}
+
+// drawFaded draws the image on top of the canvas with the given opacity.
+func drawFaded(dc *gg.Context, img image.Image, alpha float64) {
+	mask := image.NewAlpha(image.Rect(0, 0, dc.Width(), dc.Height()))
+	a := uint8(alpha*0xff + .5)
+	for i := range mask.Pix {
+		mask.Pix[i] = a
+	}
+
+	_ = dc.SetMask(mask)
+	dc.DrawImage(img, 0, 0)
+	dc.ResetClip()
+}
+
+// drawBackground draws the background selected by the options derived
+// from the images with the given opacity.
+func drawBackground(dc *gg.Context, opts Options, images []image.Image, alpha float64) error {
+	if !hasBackground(opts) || alpha <= 0 {
+		return nil
+	}
+
+	if alpha >= 1 {
+		return DrawBackground(dc, opts.Str("background"), images...)
+	}
+
+	layer := gg.NewContext(dc.Width(), dc.Height())
+	if err := DrawBackground(layer, opts.Str("background"), images...); err != nil {
+		return err
+	}
+
+	drawFaded(dc, layer.Image(), alpha)
+	return nil
+}
+
+// drawBackgrounds crossfades from the background of From to the one of To.
+func (tr Transition) drawBackgrounds(dc *gg.Context, t float64) error {
+	// the background of From only fades out if there's none to cover it
+	fromAlpha := 1.0
+	if !hasBackground(tr.ToOptions) {
+		fromAlpha = 1 - t
+	}
+
+	if err := drawBackground(dc, tr.FromOptions, tr.FromImages, fromAlpha); err != nil {
+		return err
+	}
+
+	return drawBackground(dc, tr.ToOptions, tr.ToImages, t)
+}
+
+// Render draws the transition at the progress t, which ranges from 0
+// (the From layout) to 1 (the To layout). The backgrounds selected by the
+// options crossfade like the regions.
+func (tr Transition) Render(ctx context.Context, dc *gg.Context, t float64) error {
+	for _, layout := range []Layout{tr.From, tr.To} {
+		if layout.Width != dc.Width() || layout.Height != dc.Height() {
+			return ErrTransitionSize
+		}
+	}
+
+	for _, region := range tr.From.Regions {
+		if region.Image < 0 || region.Image >= len(tr.FromImages) {
+			return ErrInvalidImageCount
+		}
+	}
+
+	for _, region := range tr.To.Regions {
+		if region.Image < 0 || region.Image >= len(tr.ToImages) {
+			return ErrInvalidImageCount
+		}
+	}
+
+	easing := tr.Easing
+	if easing == nil {
+		easing = EaseInOut
+	}
+
+	// the ends are rendered exactly like the compositions of the layouts
+	if t <= 0 {
+		if err := drawBackground(dc, tr.FromOptions, tr.FromImages, 1); err != nil {
+			return err
+		}
+
+		return Render(ctx, dc, tr.From, tr.FromOptions, tr.FromImages...)
+	} else if t >= 1 {
+		if err := drawBackground(dc, tr.ToOptions, tr.ToImages, 1); err != nil {
+			return err
+		}
+
+		return Render(ctx, dc, tr.To, tr.ToOptions, tr.ToImages...)
+	}
+
+	t = easing(t)
+	if err := tr.drawBackgrounds(dc, t); err != nil {
+		return err
+	}
+
+	tweens := tr.tweens(t)
+	regions := make([]Region, len(tweens))
+	for i, tw := range tweens {
+		regions[i] = tw.Region
+	}
+
+	gapsOnly := hasBackground(tr.FromOptions) || hasBackground(tr.ToOptions)
+	fillBackground(dc, lerpColor(tr.From.Background, tr.To.Background, t), regions, gapsOnly)
+
+	var layer *gg.Context
+	for _, tw := range tweens {
+		if tw.Alpha <= 0 {
+			continue
+		}
+
+		tw.Region.Image = 0
+		layout := Layout{Width: dc.Width(), Height: dc.Height(), Regions: []Region{tw.Region}}
+
+		if tw.Alpha >= 1 {
+			if err := Render(ctx, dc, layout, tw.Opts, tw.Img); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if layer == nil {
+			layer = gg.NewContext(dc.Width(), dc.Height())
+		}
+
+		layer.SetColor(color.Transparent)
+		layer.Clear()
+		if err := Render(ctx, layer, layout, tw.Opts, tw.Img); err != nil {
+			return err
+		}
+
+		drawFaded(dc, layer.Image(), tw.Alpha)
+	}
+
+	return nil
+}
+
+// Frames renders the transition as count frames spread over the duration.
+// The first frame shows the From layout and the last one the To layout.
+func (tr Transition) Frames(ctx context.Context, count int, duration time.Duration) ([]AnimationFrame, error) {
+	if count < 2 {
+		return nil, errors.New("transition needs at least 2 frames")
+	}
+
+	frames := make([]AnimationFrame, count)
+	for i := range frames {
+		dc := gg.NewContext(tr.To.Width, tr.To.Height)
+		if err := tr.Render(ctx, dc, float64(i)/float64(count-1)); err != nil {
+			return nil, err
+		}
+
+		frames[i] = AnimationFrame{Image: dc.Image(), Delay: duration / time.Duration(count)}
+	}
+
+	return frames, nil
+}
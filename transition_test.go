@@ -0,0 +1,170 @@
+package mosaic
+
+import (
+	"context"
+	"github.com/fogleman/gg"
+	"github.com/gieseladev/mosaic/pkg/geom"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+)
+
+func halfLayout(left, right int) Layout {
+	leftRect, rightRect := geom.Rect(0, 0, 10, 20), geom.Rect(10, 0, 20, 20)
+	return Layout{
+		Width: 20, Height: 20,
+		Regions: []Region{
+			{Image: left, Shape: RectShape{Rect: leftRect}, Rect: leftRect},
+			{Image: right, Shape: RectShape{Rect: rightRect}, Rect: rightRect},
+		},
+	}
+}
+
+func TestTransition_Render(t *testing.T) {
+	red := uniformImage(color.NRGBA{R: 0xff, A: 0xff})
+	green := uniformImage(color.NRGBA{G: 0xff, A: 0xff})
+	blue := uniformImage(color.NRGBA{B: 0xff, A: 0xff})
+
+	// green moves from the right to the left, red fades out and blue in
+	tr := Transition{
+		From:       halfLayout(0, 1),
+		To:         halfLayout(0, 1),
+		FromImages: []image.Image{red, green},
+		ToImages:   []image.Image{green, blue},
+	}
+
+	at := func(progress float64, x, y int) color.RGBA {
+		dc := gg.NewContext(20, 20)
+		if !assert.NoError(t, tr.Render(context.Background(), dc, progress)) {
+			return color.RGBA{}
+		}
+
+		return color.RGBAModel.Convert(dc.Image().At(x, y)).(color.RGBA)
+	}
+
+	assert.Equal(t, color.RGBA{R: 0xff, A: 0xff}, at(0, 5, 10))
+	assert.Equal(t, color.RGBA{G: 0xff, A: 0xff}, at(0, 15, 10))
+
+	// halfway the green region covers the center
+	assert.Equal(t, color.RGBA{G: 0xff, A: 0xff}, at(.5, 10, 10))
+	mid := at(.5, 17, 10)
+	assert.True(t, mid.B > 0x60 && mid.B < 0xa0, "blue is half faded in: %v", mid)
+
+	assert.Equal(t, color.RGBA{G: 0xff, A: 0xff}, at(1, 5, 10))
+	assert.Equal(t, color.RGBA{B: 0xff, A: 0xff}, at(1, 15, 10))
+
+	assert.Equal(t, ErrTransitionSize, tr.Render(context.Background(), gg.NewContext(10, 10), .5))
+}
+
+func TestTransition_Frames(t *testing.T) {
+	img := uniformImage(color.White)
+	tr := Transition{
+		From:       halfLayout(0, 0),
+		To:         halfLayout(0, 0),
+		FromImages: []image.Image{img},
+		ToImages:   []image.Image{img},
+	}
+
+	frames, err := tr.Frames(context.Background(), 4, time.Second)
+	if assert.NoError(t, err) && assert.Len(t, frames, 4) {
+		assert.Equal(t, 250*time.Millisecond, frames[0].Delay)
+	}
+
+	_, err = tr.Frames(context.Background(), 1, time.Second)
+	assert.Error(t, err)
+}
+
+func TestLerpShape(t *testing.T) {
+	a := RectShape{Rect: geom.Rect(0, 0, 10, 10)}
+	b := RectShape{Rect: geom.Rect(10, 10, 30, 30), Radius: 4}
+	assert.Equal(t, RectShape{Rect: geom.Rect(5, 5, 20, 20), Radius: 2}, lerpShape(a, b, .5))
+
+	circle := CircleShape{Center: geom.Pt(5, 5), Radius: 5}
+	morphed, ok := lerpShape(a, circle, .5).(PolygonShape)
+	if assert.True(t, ok) {
+		assert.True(t, morphed.Contains(geom.Pt(5, 5)))
+		assert.False(t, morphed.Contains(geom.Pt(.2, .2)))
+	}
+}
+
+func TestTransition_Background(t *testing.T) {
+	composer, ok := GetComposer("circles-pie")
+	if !assert.True(t, ok) {
+		return
+	}
+
+	opts, err := composer.ValidateOptions(Options{"background": BackgroundSolid})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	red := uniformImage(color.NRGBA{R: 0xff, A: 0xff})
+	green := uniformImage(color.NRGBA{G: 0xff, A: 0xff})
+	blue := uniformImage(color.NRGBA{B: 0xff, A: 0xff})
+	from, to := []image.Image{red, green}, []image.Image{green, blue}
+
+	compose := func(images []image.Image) (Layout, image.Image) {
+		layout, err := composer.Layout(32, 32, opts, len(images))
+		assert.NoError(t, err)
+
+		dc := gg.NewContext(32, 32)
+		assert.NoError(t, composer.ComposeContext(context.Background(), dc, opts, images...))
+		return layout, dc.Image()
+	}
+
+	fromLayout, fromImage := compose(from)
+	toLayout, toImage := compose(to)
+
+	tr := Transition{
+		From: fromLayout, To: toLayout,
+		FromImages: from, ToImages: to,
+		FromOptions: opts, ToOptions: opts,
+	}
+	frames, err := tr.Frames(context.Background(), 3, time.Second)
+	if assert.NoError(t, err) && assert.Len(t, frames, 3) {
+		assert.Equal(t, fromImage, frames[0].Image)
+		assert.Equal(t, toImage, frames[2].Image)
+
+		// the corners only show the background
+		_, _, _, a := frames[1].Image.At(0, 0).RGBA()
+		assert.Equal(t, uint32(0xffff), a)
+	}
+}
+
+func TestTransition_SideOptions(t *testing.T) {
+	composer, ok := GetComposer("circles-pie")
+	if !assert.True(t, ok) {
+		return
+	}
+
+	red := uniformImage(color.NRGBA{R: 0xff, A: 0xff})
+	green := uniformImage(color.NRGBA{G: 0xff, A: 0xff})
+	images := []image.Image{red, green}
+
+	layout, err := composer.Layout(32, 32, nil, len(images))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// only the composition transitioned from has a background
+	tr := Transition{
+		From: layout, To: layout,
+		FromImages: images, ToImages: images,
+		FromOptions: Options{"background": BackgroundSolid},
+		ToOptions:   Options{"background": BackgroundNone},
+	}
+
+	frames, err := tr.Frames(context.Background(), 3, time.Second)
+	if assert.NoError(t, err) && assert.Len(t, frames, 3) {
+		alpha := func(frame int) uint32 {
+			_, _, _, a := frames[frame].Image.At(0, 0).RGBA()
+			return a
+		}
+
+		assert.Equal(t, uint32(0xffff), alpha(0))
+		assert.True(t, alpha(1) > 0 && alpha(1) < 0xffff, "background is half faded out: %d", alpha(1))
+		assert.Zero(t, alpha(2))
+	}
+}